@@ -2,34 +2,176 @@ package cmd
 
 import (
 	"context"
+	"errors"
+	"net/http"
+	"net/url"
 	"os"
 
+	"github.com/go-git/go-git/v5/plumbing/transport"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
 
 	"golang.org/x/oauth2"
 )
 
-// ConfigureGithubClient creates a Github API client using the user-supplied GITHUB_OAUTH_TOKEN and return the configured Github client
-func ConfigureGithubClient() *github.Client {
+const (
+	// GitProtocolHTTPS clones and pushes over HTTPS, authenticating with the Github token
+	GitProtocolHTTPS = "https"
+	// GitProtocolSSH clones and pushes over SSH, authenticating with the keys loaded into the running SSH agent
+	GitProtocolSSH = "ssh"
+)
+
+// githubAppGitUsername is the username Github expects alongside an installation token when cloning or pushing over HTTPS
+const githubAppGitUsername = "x-access-token"
+
+// RepoHostCredentials are where the token used for API calls to the repo host, and for cloning and pushing over HTTPS,
+// comes from
+type RepoHostCredentials struct {
+	TokenSource oauth2.TokenSource
+	// GitUsername, when set, is the username presented alongside the token over HTTPS. Otherwise, the login of the
+	// owner of each repo is used, which Github accepts alongside a personal access token
+	GitUsername string
+}
+
+// activeRepoHostCredentials are the credentials configured by ConfigureGithubClient or ConfigureGitlabHost, which the
+// git operations of the run share. When nil, GITHUB_OAUTH_TOKEN is used as is
+var activeRepoHostCredentials *RepoHostCredentials
+
+// isValidGitProtocol returns true if the supplied value is one of the git protocols git-xargs can clone and push over
+func isValidGitProtocol(protocol string) bool {
+	return protocol == GitProtocolHTTPS || protocol == GitProtocolSSH
+}
+
+// configureGithubCredentials returns the credentials of the Github App passed via --github-app-id if there is one, or
+// otherwise the personal access token in GITHUB_OAUTH_TOKEN. A Github App's installation tokens are requested from the
+// supplied API URL, or from api.github.com if it's nil
+func configureGithubCredentials(apiURL *url.URL) (*RepoHostCredentials, error) {
+	if GithubAppID != 0 || GithubAppInstallationID != 0 || GithubAppPrivateKeyFile != "" {
+		tokenSource, err := newGithubAppTokenSource(GithubAppID, GithubAppInstallationID, GithubAppPrivateKeyFile, apiURL)
+		if err != nil {
+			return nil, err
+		}
+		return &RepoHostCredentials{TokenSource: tokenSource, GitUsername: githubAppGitUsername}, nil
+	}
+
 	// Ensure user provided a GITHUB_OAUTH_TOKEN
 	GithubOauthToken := os.Getenv("GITHUB_OAUTH_TOKEN")
 	if GithubOauthToken == "" {
+		return nil, errors.New("you must set a Github personal access token with access to Gruntwork repos via the Env var GITHUB_OAUTH_TOKEN, or pass the --github-app-* flags")
+	}
+
+	return &RepoHostCredentials{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: GithubOauthToken}),
+	}, nil
+}
+
+// ConfigureGithubClient creates a Github API client using the user-supplied GITHUB_OAUTH_TOKEN, or the Github App passed
+// via the --github-app-* flags, and return the configured Github client. The same credentials are used for cloning
+// and pushing over HTTPS for the rest of the run. Calls made by the client wait out rate limits and retry server errors.
+// The client calls api.github.com, unless --github-base-url or GITHUB_BASE_URL points it at Github Enterprise Server
+func ConfigureGithubClient(ctx context.Context) *github.Client {
+	apiURL, err := githubEnterpriseAPIURL()
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":           err,
+			"Github base URL": githubBaseURL(),
+		}).Debug("Invalid Github base URL")
+		os.Exit(1)
+	}
+
+	credentials, err := configureGithubCredentials(apiURL)
+	if err != nil {
 		log.WithFields(logrus.Fields{
-			"Error": "You must set a Github personal access token with access to Gruntwork repos via the Env var GITHUB_OAUTH_TOKEN",
-		}).Debug("Missing GITHUB_OAUTH_TOKEN")
+			"Error": err,
+		}).Debug("Error configuring Github credentials")
 		os.Exit(1)
 	}
 
-	ts := oauth2.StaticTokenSource(
-		&oauth2.Token{AccessToken: GithubOauthToken},
-	)
+	activeRepoHostCredentials = credentials
 
-	tc := oauth2.NewClient(context.Background(), ts)
+	tc := &http.Client{
+		Transport: newRateLimitTransport(ctx, &oauth2.Transport{
+			Source: oauth2.ReuseTokenSource(nil, credentials.TokenSource),
+			Base:   http.DefaultTransport,
+		}, MaxAPIRetries, MaxRateLimitWait, repoHostAPICallStats),
+	}
 
 	client := github.NewClient(tc)
+	if apiURL != nil {
+		// The uploads API is not used, but is pointed at the same instance regardless
+		client, err = github.NewEnterpriseClient(apiURL.String(), apiURL.String(), tc)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error":           err,
+				"Github base URL": apiURL.String(),
+			}).Debug("Error configuring Github Enterprise Server client")
+			os.Exit(1)
+		}
+	}
 
-	log.Debug("Github client instantiated!")
+	log.WithFields(logrus.Fields{
+		"API URL": client.BaseURL.String(),
+	}).Debug("Github client instantiated!")
 
 	return client
 }
+
+// githubHTTPSAuth returns the credentials for cloning or pushing the repo over HTTPS with the Github token. A Github App's
+// token is fetched afresh each time, so that an expired installation token is never handed to git
+func githubHTTPSAuth(repo *github.Repository) (transport.AuthMethod, error) {
+	if activeRepoHostCredentials == nil {
+		return &githttp.BasicAuth{
+			Username: repo.GetOwner().GetLogin(),
+			Password: os.Getenv("GITHUB_OAUTH_TOKEN"),
+		}, nil
+	}
+
+	token, err := activeRepoHostCredentials.TokenSource.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	username := activeRepoHostCredentials.GitUsername
+	if username == "" {
+		username = repo.GetOwner().GetLogin()
+	}
+
+	return &githttp.BasicAuth{Username: username, Password: token.AccessToken}, nil
+}
+
+// gitAuth returns the URL of the repo for the supplied protocol, along with the credentials to use with it
+func gitAuth(protocol string, repo *github.Repository) (string, transport.AuthMethod, error) {
+	if protocol == GitProtocolSSH {
+		auth, err := ssh.NewSSHAgentAuth("git")
+		return repoSSHURL(repo), auth, err
+	}
+
+	auth, err := githubHTTPSAuth(repo)
+	return repoCloneURL(repo), auth, err
+}
+
+// gitCloneAuth returns the URL to clone and fetch the repo from, and the credentials to do so with, according to --git-protocol
+func gitCloneAuth(repo *github.Repository) (string, transport.AuthMethod, error) {
+	return gitAuth(GitProtocol, repo)
+}
+
+// gitPushAuth returns the URL to push the repo's branch to, and the credentials to do so with, according to
+// --push-git-protocol, which defaults to --git-protocol. When pushing over HTTPS, GITHUB_PUSH_TOKEN, if set, is used
+// rather than the token the repo was cloned with, so that reading and pushing can be done as different identities
+func gitPushAuth(repo *github.Repository) (string, transport.AuthMethod, error) {
+	protocol := PushGitProtocol
+	if protocol == "" {
+		protocol = GitProtocol
+	}
+
+	if pushToken := os.Getenv("GITHUB_PUSH_TOKEN"); pushToken != "" && protocol != GitProtocolSSH {
+		return repoCloneURL(repo), &githttp.BasicAuth{
+			Username: repo.GetOwner().GetLogin(),
+			Password: pushToken,
+		}, nil
+	}
+
+	return gitAuth(protocol, repo)
+}
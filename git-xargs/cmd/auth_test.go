@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	githttp "github.com/go-git/go-git/v5/plumbing/transport/http"
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// makeTestAuthRepo returns a repo with both an HTTPS and an SSH clone URL
+func makeTestAuthRepo() *github.Repository {
+	repo := makeTestRepo()
+	repo.CloneURL = github.String("https://github.com/gruntwork-io/cloud-nuke.git")
+	repo.SSHURL = github.String("git@github.com:gruntwork-io/cloud-nuke.git")
+	return repo
+}
+
+func TestGitCloneAuthUsesGithubAppTokenOverHTTPS(t *testing.T) {
+	originalCredentials, originalProtocol := activeRepoHostCredentials, GitProtocol
+	defer func() { activeRepoHostCredentials, GitProtocol = originalCredentials, originalProtocol }()
+
+	GitProtocol = GitProtocolHTTPS
+	activeRepoHostCredentials = &RepoHostCredentials{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "ghs_installation"}),
+		GitUsername: githubAppGitUsername,
+	}
+
+	cloneURL, auth, err := gitCloneAuth(makeTestAuthRepo())
+
+	require.NoError(t, err)
+	assert.Equal(t, cloneURL, "https://github.com/gruntwork-io/cloud-nuke.git")
+	assert.Equal(t, auth, &githttp.BasicAuth{Username: "x-access-token", Password: "ghs_installation"})
+}
+
+func TestGitPushAuthPrefersPushToken(t *testing.T) {
+	originalCredentials, originalProtocol, originalPushProtocol := activeRepoHostCredentials, GitProtocol, PushGitProtocol
+	defer func() {
+		activeRepoHostCredentials, GitProtocol, PushGitProtocol = originalCredentials, originalProtocol, originalPushProtocol
+	}()
+
+	GitProtocol, PushGitProtocol = GitProtocolHTTPS, ""
+	activeRepoHostCredentials = &RepoHostCredentials{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: "read-only-token"}),
+	}
+
+	originalPushToken, hadPushToken := os.LookupEnv("GITHUB_PUSH_TOKEN")
+	defer func() {
+		if hadPushToken {
+			os.Setenv("GITHUB_PUSH_TOKEN", originalPushToken)
+		} else {
+			os.Unsetenv("GITHUB_PUSH_TOKEN")
+		}
+	}()
+	os.Setenv("GITHUB_PUSH_TOKEN", "push-token")
+
+	pushURL, auth, err := gitPushAuth(makeTestAuthRepo())
+
+	require.NoError(t, err)
+	assert.Equal(t, pushURL, "https://github.com/gruntwork-io/cloud-nuke.git")
+	assert.Equal(t, auth, &githttp.BasicAuth{Username: "gruntwork-io", Password: "push-token"})
+}
+
+func TestPushRemoteNameSetsUpRemoteForDifferentPushURL(t *testing.T) {
+	repositoryDir, err := ioutil.TempDir("", "git-xargs-cloud-nuke")
+	require.NoError(t, err)
+	defer os.RemoveAll(repositoryDir)
+
+	localRepository, err := git.PlainInit(repositoryDir, false)
+	require.NoError(t, err)
+	require.NoError(t, setRemoteURL(localRepository, "origin", "https://github.com/gruntwork-io/cloud-nuke.git"))
+
+	remoteName, err := pushRemoteName(localRepository, "https://github.com/gruntwork-io/cloud-nuke.git")
+	require.NoError(t, err)
+	assert.Equal(t, remoteName, "origin")
+
+	remoteName, err = pushRemoteName(localRepository, "git@github.com:gruntwork-io/cloud-nuke.git")
+	require.NoError(t, err)
+	assert.Equal(t, remoteName, pushRemote)
+
+	remote, err := localRepository.Remote(pushRemote)
+	require.NoError(t, err)
+	assert.Equal(t, remote.Config().URLs, []string{"git@github.com:gruntwork-io/cloud-nuke.git"})
+}
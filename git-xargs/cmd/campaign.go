@@ -0,0 +1,313 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"strconv"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// pullRequestURLRegex matches the URL of a pull request, e.g., https://github.com/gruntwork-io/cloud-nuke/pull/7. Any
+// host is accepted, so Github Enterprise URLs work too
+var pullRequestURLRegex = regexp.MustCompile(`^https?://[^/]+/([\w.-]+)/([\w.-]+)/pull/(\d+)/?$`)
+
+// CampaignPullRequest is a pull request opened by a previous run of git-xargs - a campaign - which the subcommands that
+// follow up on a campaign, such as merge, operate on
+type CampaignPullRequest struct {
+	Owner  string
+	Repo   string
+	Number int
+	URL    string
+}
+
+// repository returns the repo the pull request was opened against, in the form tracked by RunStats. Its HTML URL is
+// the pull request's URL rather than the repo's, so that the tables of the report link straight to each pull request
+func (c CampaignPullRequest) repository() *github.Repository {
+	return &github.Repository{
+		Name:     github.String(c.Repo),
+		FullName: github.String(fmt.Sprintf("%s/%s", c.Owner, c.Repo)),
+		Owner:    &github.User{Login: github.String(c.Owner)},
+		HTMLURL:  github.String(c.URL),
+	}
+}
+
+// parsePullRequestURL extracts the owner, repo and number of a pull request from its URL. It returns false if the URL
+// isn't a pull request URL
+func parsePullRequestURL(prURL string) (CampaignPullRequest, bool) {
+	matches := pullRequestURLRegex.FindStringSubmatch(prURL)
+	if matches == nil {
+		return CampaignPullRequest{}, false
+	}
+
+	number, err := strconv.Atoi(matches[3])
+	if err != nil {
+		return CampaignPullRequest{}, false
+	}
+
+	return CampaignPullRequest{
+		Owner:  matches[1],
+		Repo:   matches[2],
+		Number: number,
+		URL:    prURL,
+	}, true
+}
+
+// loadRunReport reads a JSON run report saved via --report-file
+func loadRunReport(path string) (*RunReport, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var report RunReport
+	if err := json.Unmarshal(contents, &report); err != nil {
+		return nil, err
+	}
+
+	if report.SchemaVersion != RunReportSchemaVersion {
+		return nil, fmt.Errorf("run report %s has schema version %d, but this version of git-xargs only understands version %d", path, report.SchemaVersion, RunReportSchemaVersion)
+	}
+
+	return &report, nil
+}
+
+// loadRunReportPullRequests returns the pull requests listed in a JSON run report saved via --report-file
+func loadRunReportPullRequests(path string) ([]CampaignPullRequest, error) {
+	report, err := loadRunReport(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var prs []CampaignPullRequest
+	for _, reportPR := range report.PullRequests {
+		pr, ok := parsePullRequestURL(reportPR.URL)
+		if !ok {
+			return nil, fmt.Errorf("run report %s lists %q, which is not a pull request URL", path, reportPR.URL)
+		}
+		prs = append(prs, pr)
+	}
+
+	return prs, nil
+}
+
+// findCampaignPullRequests looks up the pull requests of the supplied branch in every repo selected via
+// --allowed-repos-filepath (or --repos), or, failing that, across the organization passed via --github-org or the
+// account passed via --user. Only open pull requests are returned if openOnly is set
+func findCampaignPullRequests(githubClient *github.Client, branchName string, openOnly bool) ([]CampaignPullRequest, error) {
+	if AllowedReposFile != "" {
+		allowedRepos, err := loadCampaignAllowedRepos()
+		if err != nil {
+			return nil, err
+		}
+		return listRepoPullRequests(githubClient, allowedRepos, branchName, openOnly)
+	}
+
+	var scope string
+	switch {
+	case GithubOrg != "":
+		scope = fmt.Sprintf("org:%s", GithubOrg)
+	case GithubUser != "":
+		scope = fmt.Sprintf("user:%s", GithubUser)
+	default:
+		return nil, errors.New("pass --run-report, --allowed-repos-filepath, --github-org or --user to select the pull requests of the campaign")
+	}
+
+	query := fmt.Sprintf("is:pr head:%s %s", branchName, scope)
+	if openOnly {
+		query += " is:open"
+	}
+	return searchPullRequests(githubClient, query)
+}
+
+// stdinCampaignRepos are the repos that were piped in via STDIN, which can only be read once
+var stdinCampaignRepos []*AllowedRepo
+
+// loadCampaignAllowedRepos reads the repos selected via --allowed-repos-filepath (or --repos). Repos read from STDIN are
+// remembered, since the cleanup subcommand looks up both the pull requests and the branches of the campaign in them
+func loadCampaignAllowedRepos() ([]*AllowedRepo, error) {
+	if AllowedReposFile == StdinRepoSource && stdinCampaignRepos != nil {
+		return stdinCampaignRepos, nil
+	}
+
+	allowedRepos, malformedLines, err := processAllowedRepos(AllowedReposFile)
+	if err != nil {
+		return nil, err
+	}
+	if len(malformedLines) > 0 {
+		return nil, fmt.Errorf("could not parse %q as org/repo, a repo URL or an SSH remote", malformedLines[0])
+	}
+
+	if AllowedReposFile == StdinRepoSource {
+		stdinCampaignRepos = allowedRepos
+	}
+	return allowedRepos, nil
+}
+
+// listRepoPullRequests lists the pull requests of the branch in each of the repos
+func listRepoPullRequests(githubClient *github.Client, allowedRepos []*AllowedRepo, branchName string, openOnly bool) ([]CampaignPullRequest, error) {
+	state := "all"
+	if openOnly {
+		state = "open"
+	}
+
+	var prs []CampaignPullRequest
+
+	for _, allowedRepo := range allowedRepos {
+		opts := &github.PullRequestListOptions{
+			State:       state,
+			Head:        fmt.Sprintf("%s:%s", allowedRepo.Organization, branchName),
+			ListOptions: github.ListOptions{PerPage: 100},
+		}
+
+		for {
+			repoPRs, resp, err := githubClient.PullRequests.List(context.Background(), allowedRepo.Organization, allowedRepo.Name, opts)
+			if err != nil {
+				log.WithFields(logrus.Fields{
+					"Error": err,
+					"Repo":  allowedRepo.Name,
+				}).Debug("Error listing pull requests of the campaign branch")
+				return nil, err
+			}
+
+			for _, repoPR := range repoPRs {
+				if pr, ok := parsePullRequestURL(repoPR.GetHTMLURL()); ok {
+					prs = append(prs, pr)
+				}
+			}
+
+			if resp.NextPage == 0 {
+				break
+			}
+			opts.Page = resp.NextPage
+		}
+	}
+
+	return prs, nil
+}
+
+// searchPullRequests pages through every pull request matching the Github issue search query
+func searchPullRequests(githubClient *github.Client, query string) ([]CampaignPullRequest, error) {
+	opts := &github.SearchOptions{
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	var prs []CampaignPullRequest
+
+	for {
+		result, resp, err := githubClient.Search.Issues(context.Background(), query, opts)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+				"Query": query,
+			}).Debug("Error searching for pull requests of the campaign branch")
+			return nil, err
+		}
+
+		for _, issue := range result.Issues {
+			if pr, ok := parsePullRequestURL(issue.GetHTMLURL()); ok {
+				prs = append(prs, pr)
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return prs, nil
+}
+
+// selectCampaignPullRequests returns the pull requests of the campaign, read from the run report passed via
+// --run-report if there is one, or otherwise looked up by --branch-name, and tracks them as found
+func selectCampaignPullRequests(githubClient *github.Client, openOnly bool, stats *RunStats) ([]CampaignPullRequest, error) {
+	var prs []CampaignPullRequest
+	var err error
+
+	if CampaignRunReport != "" {
+		prs, err = loadRunReportPullRequests(CampaignRunReport)
+	} else {
+		prs, err = findCampaignPullRequests(githubClient, BranchName, openOnly)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var repos []*github.Repository
+	for _, pr := range prs {
+		repos = append(repos, pr.repository())
+	}
+	stats.TrackMultiple(CampaignPullRequestFound, repos)
+
+	log.WithFields(logrus.Fields{
+		"Pull request count": len(prs),
+		"Branch":             BranchName,
+		"Run report":         CampaignRunReport,
+	}).Debug("Found the pull requests of the campaign")
+
+	return prs, nil
+}
+
+// forEachCampaignPullRequest hands each pull request to a bounded pool of workers, as for the repos of a run, calling
+// process on each. Pull requests that were never handed out because the run was cancelled are tracked as such
+func forEachCampaignPullRequest(ctx context.Context, prs []CampaignPullRequest, stats *RunStats, process func(CampaignPullRequest, *github.Repository)) {
+	var repos []*github.Repository
+	prsByRepo := make(map[*github.Repository]CampaignPullRequest)
+	for _, pr := range prs {
+		repo := pr.repository()
+		repos = append(repos, repo)
+		prsByRepo[repo] = pr
+	}
+
+	unprocessed := runWorkerPool(ctx, MaxConcurrentRepos, repos, func(repo *github.Repository) {
+		process(prsByRepo[repo], repo)
+	})
+
+	if len(unprocessed) > 0 {
+		stats.TrackMultiple(RepoProcessingCancelled, unprocessed)
+	}
+}
+
+// campaignPreRun replaces the root command's persistentPreRun for the subcommands that follow up on a campaign, since
+// those don't run any scripts, and select pull requests rather than repos
+func campaignPreRun(cmd *cobra.Command, args []string) {
+	if !isValidOutputFormat(OutputFormat) {
+		log.WithFields(logrus.Fields{
+			"Output format": OutputFormat,
+		}).Fatal("Invalid --output-format. Must be one of: table, json")
+	}
+
+	// Merging, status checks and cleanup rely on Github's pull request, review and checks APIs
+	if RepoHostName != RepoHostGithub {
+		log.WithFields(logrus.Fields{
+			"Repo host": RepoHostName,
+		}).Fatal("This subcommand only supports repos hosted on Github")
+	}
+
+	// The campaign's branch is merged from, or closed and deleted, so it must never be the branch pull requests are opened against
+	if BaseBranch != "" && BranchName == BaseBranch {
+		log.WithFields(logrus.Fields{
+			"Branch": BranchName,
+		}).Fatal("--branch-name must not be the same as --base-branch")
+	}
+
+	if CampaignRunReport != "" {
+		return
+	}
+
+	if !ensureValidOptionsPassed(AllowedReposFile, GithubOrg, GithubUser) && stdinIsPiped() {
+		log.Debug("No repo selection flags passed, but STDIN is piped. Reading repos from STDIN")
+		AllowedReposFile = StdinRepoSource
+	}
+
+	if !ensureValidOptionsPassed(AllowedReposFile, GithubOrg, GithubUser) {
+		log.Fatal("You must provide --run-report, or an AllowedReposFile path, a GithubOrg or a Github user to look up the pull requests of --branch-name in. See ./git-xargs help")
+	}
+}
@@ -0,0 +1,68 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePullRequestURL(t *testing.T) {
+	pr, ok := parsePullRequestURL("https://github.com/gruntwork-io/cloud-nuke/pull/7")
+
+	assert.True(t, ok)
+	assert.Equal(t, pr, CampaignPullRequest{Owner: "gruntwork-io", Repo: "cloud-nuke", Number: 7, URL: "https://github.com/gruntwork-io/cloud-nuke/pull/7"})
+
+	// Github Enterprise hosts are accepted too
+	_, ok = parsePullRequestURL("https://github.example.com/platform/infra.live/pull/12")
+	assert.True(t, ok)
+
+	_, ok = parsePullRequestURL("https://github.com/gruntwork-io/cloud-nuke/issues/7")
+	assert.False(t, ok)
+}
+
+func TestLoadRunReportPullRequests(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-xargs-campaign-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	stats := NewStatsTracker()
+	stats.TrackPullRequest(makeTestRepo(), "https://github.com/gruntwork-io/cloud-nuke/pull/7")
+
+	reportPath := filepath.Join(dir, "report.json")
+	require.NoError(t, writeJSONReportFile(reportPath, buildRunReport(allEvents, stats)))
+
+	prs, err := loadRunReportPullRequests(reportPath)
+
+	require.NoError(t, err)
+	assert.Equal(t, prs, []CampaignPullRequest{{Owner: "gruntwork-io", Repo: "cloud-nuke", Number: 7, URL: "https://github.com/gruntwork-io/cloud-nuke/pull/7"}})
+}
+
+func TestFindCampaignPullRequestsSearchesOrgByBranch(t *testing.T) {
+	originalOrg, originalFile := GithubOrg, AllowedReposFile
+	defer func() { GithubOrg, AllowedReposFile = originalOrg, originalFile }()
+	GithubOrg, AllowedReposFile = "gruntwork-io", ""
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/search/issues", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.URL.Query().Get("q"), "is:pr head:git-xargs org:gruntwork-io is:open")
+		fmt.Fprint(w, `{"total_count": 2, "items": [
+			{"number": 7, "html_url": "https://github.com/gruntwork-io/cloud-nuke/pull/7"},
+			{"number": 3, "html_url": "https://github.com/gruntwork-io/terratest/pull/3"}
+		]}`)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	prs, err := findCampaignPullRequests(client, "git-xargs", true)
+
+	require.NoError(t, err)
+	assert.Equal(t, len(prs), 2)
+	assert.Equal(t, prs[1].Repo, "terratest")
+	assert.Equal(t, prs[1].Number, 3)
+}
@@ -0,0 +1,290 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// CleanupComment is left on each pull request before it's closed, to explain why
+	CleanupComment string
+	// CleanupDeleteBranch deletes the branch of each pull request once it's closed
+	CleanupDeleteBranch bool
+)
+
+func init() {
+	cleanupCmd.Flags().StringVar(&CampaignRunReport, "run-report", "", "The path of a JSON run report saved via --report-file, whose pull requests should be closed and branches deleted. Otherwise, the pull requests and branches named --branch-name are looked up")
+
+	cleanupCmd.Flags().StringVar(&CleanupComment, "comment", "", "A comment to leave on each pull request before closing it, e.g., to explain why the campaign was abandoned")
+
+	cleanupCmd.Flags().BoolVar(&CleanupDeleteBranch, "delete-branch", true, "Delete the branch of each pull request once it's closed, along with the branches of pull requests that were already closed and branches that never had a pull request")
+
+	rootCmd.AddCommand(cleanupCmd)
+}
+
+var cleanupCmd = &cobra.Command{
+	Use:              "cleanup",
+	Short:            "Close the pull requests opened by a previous run and delete their branches",
+	Long:             "Close the pull requests opened by a previous run, read from a saved run report or looked up by branch name, optionally leaving a comment on each, then delete their branches, and any other branch the run pushed that has no open pull request",
+	PersistentPreRun: campaignPreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := cancelOnInterrupt()
+		defer cancel()
+
+		GithubClient := ConfigureGithubClient(ctx)
+
+		stats := NewStatsTracker()
+
+		// Closed pull requests are looked up too, since their branches may still need deleting
+		prs, err := selectCampaignPullRequests(GithubClient, false, stats)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+			}).Fatal("Error looking up the pull requests to close")
+		}
+
+		forEachCampaignPullRequest(ctx, prs, stats, func(pr CampaignPullRequest, repo *github.Repository) {
+			if cleanupErr := cleanupCampaignPullRequest(DryRun, GithubClient, pr, repo, stats); cleanupErr != nil {
+				log.WithFields(logrus.Fields{
+					"Pull Request URL": pr.URL,
+					"Error":            cleanupErr,
+				}).Debug("Pull request was not cleaned up")
+			}
+		})
+
+		if CleanupDeleteBranch && ctx.Err() == nil {
+			repos, reposErr := selectCampaignBranchRepos(GithubClient, prs)
+			if reposErr != nil {
+				log.WithFields(logrus.Fields{
+					"Error": reposErr,
+				}).Fatal("Error looking up the repos the campaign's branch may have been pushed to")
+			}
+
+			runWorkerPool(ctx, MaxConcurrentRepos, repos, func(repo *github.Repository) {
+				if deleteErr := deleteLeftoverCampaignBranch(DryRun, GithubClient, repo, BranchName, stats); deleteErr != nil {
+					log.WithFields(logrus.Fields{
+						"Repo":  repo.GetFullName(),
+						"Error": deleteErr,
+					}).Debug("Branch was not cleaned up")
+				}
+			})
+		}
+
+		stats.printReport(cleanupEvents)
+	},
+}
+
+// cleanupCampaignPullRequest closes the pull request and deletes its branch. The branch of a pull request that was already
+// closed without being merged is deleted too, if it's still around. Merged pull requests are left alone along with their
+// branches, which the merge subcommand deletes unless told not to
+func cleanupCampaignPullRequest(dryRun bool, githubClient *github.Client, pr CampaignPullRequest, repo *github.Repository, stats *RunStats) error {
+	ghPR, _, err := githubClient.PullRequests.Get(context.Background(), pr.Owner, pr.Repo, pr.Number)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":            err,
+			"Pull Request URL": pr.URL,
+		}).Debug("Error looking up pull request")
+
+		stats.TrackError(PullRequestLookupErr, repo, err)
+		return err
+	}
+
+	if ghPR.GetState() != "open" {
+		stats.TrackSingle(PullRequestNotOpen, repo)
+		if ghPR.GetMerged() || !CleanupDeleteBranch || openedFromFork(ghPR) {
+			return nil
+		}
+		return deleteLeftoverCampaignBranch(dryRun, githubClient, repo, ghPR.GetHead().GetRef(), stats)
+	}
+
+	if dryRun {
+		log.WithFields(logrus.Fields{
+			"Pull Request URL": pr.URL,
+		}).Debug("dryRun is set to true, so skipping closing the pull request and deleting its branch!")
+
+		stats.TrackSingle(PullRequestReadyToClose, repo)
+		return nil
+	}
+
+	if err := closeCampaignPullRequest(githubClient, pr, repo, stats); err != nil {
+		return err
+	}
+
+	if CleanupDeleteBranch {
+		return deleteCampaignBranch(githubClient, ghPR, repo, stats)
+	}
+	return nil
+}
+
+// closeCampaignPullRequest leaves --comment on the pull request, if set, and then closes it. A pull request whose comment
+// could not be left is not closed, so that nobody is left wondering why it was
+func closeCampaignPullRequest(githubClient *github.Client, pr CampaignPullRequest, repo *github.Repository, stats *RunStats) error {
+	if CleanupComment != "" {
+		comment := &github.IssueComment{Body: github.String(CleanupComment)}
+		if _, _, err := githubClient.Issues.CreateComment(context.Background(), pr.Owner, pr.Repo, pr.Number, comment); err != nil {
+			log.WithFields(logrus.Fields{
+				"Error":            err,
+				"Pull Request URL": pr.URL,
+			}).Debug("Error commenting on pull request")
+
+			stats.TrackError(PullRequestCommentErr, repo, err)
+			return err
+		}
+	}
+
+	edit := &github.PullRequest{State: github.String("closed")}
+	if _, _, err := githubClient.PullRequests.Edit(context.Background(), pr.Owner, pr.Repo, pr.Number, edit); err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":            err,
+			"Pull Request URL": pr.URL,
+		}).Debug("Error closing pull request")
+
+		stats.TrackError(PullRequestCloseErr, repo, err)
+		return err
+	}
+
+	log.WithFields(logrus.Fields{
+		"Pull Request URL": pr.URL,
+	}).Debug("Successfully closed pull request")
+
+	stats.TrackSingle(PullRequestClosed, repo)
+	return nil
+}
+
+// selectCampaignBranchRepos returns the repos the campaign's branch may have been pushed to without a pull request being
+// opened from it, e.g., because opening the pull request failed. Those are the repos the run report says the branch was
+// pushed to, or otherwise every repo selected via --allowed-repos-filepath, --github-org or --user. The repos of the
+// supplied pull requests are left out, since their branches were already dealt with
+func selectCampaignBranchRepos(githubClient *github.Client, prs []CampaignPullRequest) ([]*github.Repository, error) {
+	var repos []*github.Repository
+
+	switch {
+	case CampaignRunReport != "":
+		report, err := loadRunReport(CampaignRunReport)
+		if err != nil {
+			return nil, err
+		}
+		for _, reportEvent := range report.Events {
+			if reportEvent.Event != PushBranchSucceeded {
+				continue
+			}
+			for _, reportRepo := range reportEvent.Repos {
+				if owner, name, ok := splitRepoFullName(reportRepo.FullName); ok {
+					repos = append(repos, campaignBranchRepo(owner, name))
+				}
+			}
+		}
+	case AllowedReposFile != "":
+		allowedRepos, err := loadCampaignAllowedRepos()
+		if err != nil {
+			return nil, err
+		}
+		for _, allowedRepo := range allowedRepos {
+			repos = append(repos, campaignBranchRepo(allowedRepo.Organization, allowedRepo.Name))
+		}
+	default:
+		host := &githubHost{client: githubClient}
+		var listed []*github.Repository
+		var err error
+		if GithubOrg != "" {
+			listed, err = host.ListOrgRepos(GithubOrg)
+		} else {
+			listed, err = host.ListUserRepos(GithubUser)
+		}
+		if err != nil {
+			return nil, err
+		}
+		for _, repo := range listed {
+			// The default branch is kept, so that deleteLeftoverCampaignBranch can refuse to delete it
+			branchRepo := campaignBranchRepo(repo.GetOwner().GetLogin(), repo.GetName())
+			branchRepo.DefaultBranch = repo.DefaultBranch
+			repos = append(repos, branchRepo)
+		}
+	}
+
+	withPullRequests := make(map[string]bool)
+	for _, pr := range prs {
+		withPullRequests[strings.ToLower(pr.Owner+"/"+pr.Repo)] = true
+	}
+
+	var withoutPullRequests []*github.Repository
+	for _, repo := range repos {
+		if !withPullRequests[strings.ToLower(repo.GetFullName())] {
+			withoutPullRequests = append(withoutPullRequests, repo)
+		}
+	}
+	return withoutPullRequests, nil
+}
+
+// splitRepoFullName splits a full name, such as gruntwork-io/cloud-nuke, into the repo's owner and name
+func splitRepoFullName(fullName string) (string, string, bool) {
+	parts := strings.Split(fullName, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", false
+	}
+	return parts[0], parts[1], true
+}
+
+// campaignBranchRepo returns the repo whose branch is being cleaned up, in the form tracked by RunStats. Its HTML URL is
+// the branch's URL, so that the tables of the report link straight to each branch
+func campaignBranchRepo(owner, name string) *github.Repository {
+	repo := &github.Repository{
+		Name:     github.String(name),
+		FullName: github.String(fmt.Sprintf("%s/%s", owner, name)),
+		Owner:    &github.User{Login: github.String(owner)},
+	}
+
+	if webURL, err := githubWebURL(); err == nil {
+		repo.HTMLURL = github.String(fmt.Sprintf("%s%s/%s/tree/%s", webURL.String(), owner, name, BranchName))
+	}
+	return repo
+}
+
+// deleteLeftoverCampaignBranch deletes the branch from the repo, if it still exists there. Branches that are already gone,
+// e.g., because Github deleted them when their pull request was closed, are left be. So is the repo's default branch, if
+// known, and --base-branch, so that a mistyped --branch-name can't wipe out long-lived branches across every repo
+func deleteLeftoverCampaignBranch(dryRun bool, githubClient *github.Client, repo *github.Repository, branch string, stats *RunStats) error {
+	if branch == repo.GetDefaultBranch() || branch == BaseBranch {
+		log.WithFields(logrus.Fields{
+			"Branch": branch,
+			"Repo":   repo.GetFullName(),
+		}).Debug("Not deleting the repo's default branch or --base-branch")
+
+		stats.TrackSingle(CampaignBranchIsBaseBranch, repo)
+		return nil
+	}
+
+	_, resp, err := githubClient.Repositories.GetBranch(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), branch)
+	if err != nil {
+		if resp != nil && resp.StatusCode == http.StatusNotFound {
+			return nil
+		}
+
+		log.WithFields(logrus.Fields{
+			"Error":  err,
+			"Branch": branch,
+			"Repo":   repo.GetName(),
+		}).Debug("Error looking up branch")
+
+		stats.TrackError(CampaignBranchLookupErr, repo, err)
+		return err
+	}
+
+	if dryRun {
+		log.WithFields(logrus.Fields{
+			"Branch": branch,
+			"Repo":   repo.GetName(),
+		}).Debug("dryRun is set to true, so skipping deleting the branch!")
+
+		stats.TrackSingle(CampaignBranchReadyToDelete, repo)
+		return nil
+	}
+
+	return deleteBranch(githubClient, repo, branch, stats)
+}
@@ -0,0 +1,238 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newCleanupPullRequestMux serves a pull request in the supplied state, recording the state it's edited to
+func newCleanupPullRequestMux(t *testing.T, state string, merged bool, editedState *string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPatch {
+			var edit map[string]interface{}
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&edit))
+			*editedState = fmt.Sprint(edit["state"])
+		}
+		fmt.Fprintf(w, `{"number": 7, "state": %q, "merged": %t, "html_url": "https://github.com/gruntwork-io/cloud-nuke/pull/7",
+			"head": {"ref": "git-xargs", "sha": "abc123", "repo": {"full_name": "gruntwork-io/cloud-nuke"}},
+			"base": {"ref": "master", "repo": {"full_name": "gruntwork-io/cloud-nuke"}}}`, state, merged)
+	})
+	return mux
+}
+
+func TestCleanupCampaignPullRequestCommentsClosesAndDeletesBranch(t *testing.T) {
+	originalComment, originalDelete := CleanupComment, CleanupDeleteBranch
+	defer func() { CleanupComment, CleanupDeleteBranch = originalComment, originalDelete }()
+	CleanupComment, CleanupDeleteBranch = "This campaign was abandoned", true
+
+	var editedState, comment string
+	branchDeleted := false
+
+	mux := newCleanupPullRequestMux(t, "open", false, &editedState)
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.Empty(t, editedState, "The comment must be left before the pull request is closed")
+		var body map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		comment = body["body"]
+		fmt.Fprint(w, `{}`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/git/refs/heads/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodDelete)
+		branchDeleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := cleanupCampaignPullRequest(false, client, pr, pr.repository(), stats)
+
+	require.NoError(t, err)
+	assert.Equal(t, comment, "This campaign was abandoned")
+	assert.Equal(t, editedState, "closed")
+	assert.True(t, branchDeleted)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestClosed)), 1)
+	assert.Equal(t, len(stats.GetMultiple(CampaignBranchDeleted)), 1)
+	assert.Equal(t, stats.CountFailedRepos(cleanupEvents), 0)
+}
+
+func TestCleanupCampaignPullRequestLeavesPullRequestOpenWhenCommentFails(t *testing.T) {
+	originalComment := CleanupComment
+	defer func() { CleanupComment = originalComment }()
+	CleanupComment = "This campaign was abandoned"
+
+	var editedState string
+	mux := newCleanupPullRequestMux(t, "open", false, &editedState)
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/issues/7/comments", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := cleanupCampaignPullRequest(false, client, pr, pr.repository(), stats)
+
+	assert.Error(t, err)
+	assert.Empty(t, editedState)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestCommentErr)), 1)
+	assert.Equal(t, stats.CountFailedRepos(cleanupEvents), 1)
+}
+
+func TestCleanupCampaignPullRequestLeavesMergedPullRequestsAlone(t *testing.T) {
+	var editedState string
+	client, closeServer := newTestGithubClient(t, newCleanupPullRequestMux(t, "closed", true, &editedState))
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := cleanupCampaignPullRequest(false, client, pr, pr.repository(), stats)
+
+	assert.NoError(t, err)
+	assert.Empty(t, editedState)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestNotOpen)), 1)
+	assert.Empty(t, stats.GetMultiple(CampaignBranchDeleted))
+}
+
+// handleCampaignBranch serves the git-xargs branch of cloud-nuke if it exists, recording whether it was deleted
+func handleCampaignBranch(t *testing.T, mux *http.ServeMux, exists bool, branchDeleted *bool) {
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/branches/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		if !exists {
+			w.WriteHeader(http.StatusNotFound)
+			fmt.Fprint(w, `{"message": "Branch not found"}`)
+			return
+		}
+		fmt.Fprint(w, `{"name": "git-xargs"}`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/git/refs/heads/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodDelete)
+		*branchDeleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+func TestCleanupCampaignPullRequestDeletesBranchOfClosedPullRequest(t *testing.T) {
+	originalDelete := CleanupDeleteBranch
+	defer func() { CleanupDeleteBranch = originalDelete }()
+	CleanupDeleteBranch = true
+
+	var editedState string
+	branchDeleted := false
+	mux := newCleanupPullRequestMux(t, "closed", false, &editedState)
+	handleCampaignBranch(t, mux, true, &branchDeleted)
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := cleanupCampaignPullRequest(false, client, pr, pr.repository(), stats)
+
+	require.NoError(t, err)
+	assert.Empty(t, editedState)
+	assert.True(t, branchDeleted)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestNotOpen)), 1)
+	assert.Equal(t, len(stats.GetMultiple(CampaignBranchDeleted)), 1)
+}
+
+func TestDeleteLeftoverCampaignBranchSkipsBranchesThatAreGone(t *testing.T) {
+	branchDeleted := false
+	mux := http.NewServeMux()
+	handleCampaignBranch(t, mux, false, &branchDeleted)
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	err := deleteLeftoverCampaignBranch(false, client, campaignBranchRepo("gruntwork-io", "cloud-nuke"), "git-xargs", stats)
+
+	assert.NoError(t, err)
+	assert.False(t, branchDeleted)
+	assert.Equal(t, stats.CountFailedRepos(cleanupEvents), 0)
+	assert.Empty(t, stats.GetMultiple(CampaignBranchDeleted))
+}
+
+func TestDeleteLeftoverCampaignBranchLeavesDefaultAndBaseBranchesAlone(t *testing.T) {
+	originalBaseBranch := BaseBranch
+	defer func() { BaseBranch = originalBaseBranch }()
+
+	branchDeleted := false
+	mux := http.NewServeMux()
+	handleCampaignBranch(t, mux, true, &branchDeleted)
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	// A mistyped --branch-name that happens to be the default branch of a repo listed via --github-org
+	BaseBranch = ""
+	repo := campaignBranchRepo("gruntwork-io", "cloud-nuke")
+	repo.DefaultBranch = github.String("git-xargs")
+	stats := NewStatsTracker()
+	require.NoError(t, deleteLeftoverCampaignBranch(false, client, repo, "git-xargs", stats))
+
+	BaseBranch = "git-xargs"
+	require.NoError(t, deleteLeftoverCampaignBranch(false, client, campaignBranchRepo("gruntwork-io", "cloud-nuke"), "git-xargs", stats))
+
+	assert.False(t, branchDeleted)
+	assert.Equal(t, len(stats.GetMultiple(CampaignBranchIsBaseBranch)), 1)
+	assert.Equal(t, len(stats.GetTimeline("gruntwork-io/cloud-nuke")), 2)
+	assert.Empty(t, stats.GetMultiple(CampaignBranchDeleted))
+}
+
+func TestDeleteLeftoverCampaignBranchOnlyReportsOnDryRun(t *testing.T) {
+	branchDeleted := false
+	mux := http.NewServeMux()
+	handleCampaignBranch(t, mux, true, &branchDeleted)
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	err := deleteLeftoverCampaignBranch(true, client, campaignBranchRepo("gruntwork-io", "cloud-nuke"), "git-xargs", stats)
+
+	assert.NoError(t, err)
+	assert.False(t, branchDeleted)
+	assert.Equal(t, len(stats.GetMultiple(CampaignBranchReadyToDelete)), 1)
+}
+
+func TestSelectCampaignBranchReposLeavesOutReposWithPullRequests(t *testing.T) {
+	reposFile, err := ioutil.TempFile("", "git-xargs-repos")
+	require.NoError(t, err)
+	defer os.Remove(reposFile.Name())
+	_, err = reposFile.WriteString("gruntwork-io/cloud-nuke\ngruntwork-io/terratest\n")
+	require.NoError(t, err)
+	require.NoError(t, reposFile.Close())
+
+	originalReposFile, originalRunReport := AllowedReposFile, CampaignRunReport
+	defer func() { AllowedReposFile, CampaignRunReport = originalReposFile, originalRunReport }()
+	AllowedReposFile, CampaignRunReport = reposFile.Name(), ""
+
+	// The terratest branch was pushed, but its pull request failed to open
+	repos, err := selectCampaignBranchRepos(nil, []CampaignPullRequest{makeTestCampaignPullRequest()})
+	require.NoError(t, err)
+	require.Equal(t, len(repos), 1)
+	assert.Equal(t, repos[0].GetFullName(), "gruntwork-io/terratest")
+	assert.Equal(t, repos[0].GetHTMLURL(), "https://github.com/gruntwork-io/terratest/tree/git-xargs")
+}
+
+func TestCleanupCampaignPullRequestOnlyReportsOnDryRun(t *testing.T) {
+	var editedState string
+	client, closeServer := newTestGithubClient(t, newCleanupPullRequestMux(t, "open", false, &editedState))
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := cleanupCampaignPullRequest(true, client, pr, pr.repository(), stats)
+
+	assert.NoError(t, err)
+	assert.Empty(t, editedState)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestReadyToClose)), 1)
+	assert.Equal(t, stats.CountHeadlineRepos(cleanupEvents)[1], HeadlineCount{Headline: "Pull requests ready to close", Count: 1})
+}
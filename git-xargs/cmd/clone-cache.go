@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// mirrorRefSpec fetches every branch of the remote into the branch of the same name in the mirror, as `git clone
+// --mirror` does, so that clones of the mirror see the same branches as clones of Github
+const mirrorRefSpec = "+refs/heads/*:refs/heads/*"
+
+// cloneCachePath returns the path of the bare mirror of the repo in the clone cache directory
+func cloneCachePath(cacheDir string, repo *github.Repository) string {
+	return filepath.Join(cacheDir, filepath.FromSlash(journalKey(repo))+".git")
+}
+
+// updateCloneCache brings the mirror of the repo in the clone cache directory up to date with Github, creating it first
+// if this is the first run to cache the repo, and returns its path. Mirrors are only ever fetched into, so that the
+// network cost of a run is just whatever changed since the last one. Each repo is only processed by one worker at a
+// time, so no locking is needed within a run, but two runs must not share a cache directory at the same time
+func updateCloneCache(cacheDir string, repo *github.Repository) (string, error) {
+	mirrorDir := cloneCachePath(cacheDir, repo)
+
+	// The directory is created up front, since opening a repo beneath a path that isn't a directory panics
+	if err := os.MkdirAll(mirrorDir, 0755); err != nil {
+		return "", err
+	}
+
+	fetchURL, auth, err := gitCloneAuth(repo)
+	if err != nil {
+		return "", err
+	}
+
+	mirror, err := git.PlainOpen(mirrorDir)
+	if err == git.ErrRepositoryNotExists {
+		mirror, err = git.PlainInit(mirrorDir, true)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	// The remote is pointed at Github afresh each time, in case the mirror was created over a different --git-protocol
+	if err := setRemoteURL(mirror, "origin", fetchURL, mirrorRefSpec); err != nil {
+		return "", err
+	}
+
+	fetchErr := mirror.Fetch(&git.FetchOptions{
+		RemoteName: "origin",
+		RefSpecs:   []config.RefSpec{mirrorRefSpec},
+		Tags:       git.AllTags,
+		Force:      true,
+		Progress:   os.Stderr,
+		Auth:       auth,
+	})
+	if fetchErr != nil && fetchErr != git.NoErrAlreadyUpToDate {
+		return "", fetchErr
+	}
+
+	log.WithFields(logrus.Fields{
+		"Repo":       repo.GetName(),
+		"Mirror dir": mirrorDir,
+	}).Debug("Clone cache mirror of repo is up to date")
+
+	return mirrorDir, nil
+}
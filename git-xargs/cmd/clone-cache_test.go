@@ -0,0 +1,172 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTestUpstreamRepo creates a bare repo on the local filesystem to stand in for the Github remote, with a single
+// commit on master, and returns a repo whose clone URL is its path along with a function that adds further commits
+func makeTestUpstreamRepo(t *testing.T) (*github.Repository, func(message string) plumbing.Hash) {
+	upstreamDir, err := ioutil.TempDir("", "git-xargs-upstream")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(upstreamDir) })
+
+	_, err = git.PlainInit(upstreamDir, true)
+	require.NoError(t, err)
+
+	authorDir, err := ioutil.TempDir("", "git-xargs-author")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(authorDir) })
+
+	author, err := git.PlainInit(authorDir, false)
+	require.NoError(t, err)
+	_, err = author.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{upstreamDir}})
+	require.NoError(t, err)
+	worktree, err := author.Worktree()
+	require.NoError(t, err)
+
+	commit := func(message string) plumbing.Hash {
+		require.NoError(t, ioutil.WriteFile(filepath.Join(authorDir, "README.md"), []byte(message), 0644))
+		_, err := worktree.Add("README.md")
+		require.NoError(t, err)
+		hash, err := worktree.Commit(message, &git.CommitOptions{
+			Author: &object.Signature{Name: "git-xargs", Email: "git-xargs@example.com", When: time.Now()},
+		})
+		require.NoError(t, err)
+		require.NoError(t, author.Push(&git.PushOptions{RemoteName: "origin"}))
+		return hash
+	}
+	commit("first")
+
+	repo := &github.Repository{
+		Name:     github.String("cloud-nuke"),
+		FullName: github.String("gruntwork-io/cloud-nuke"),
+		Owner:    &github.User{Login: github.String("gruntwork-io")},
+		CloneURL: github.String(upstreamDir),
+	}
+	return repo, commit
+}
+
+func TestCloneLocalRepositoryFetchesIntoCloneCache(t *testing.T) {
+	cacheDir, err := ioutil.TempDir("", "git-xargs-clone-cache")
+	require.NoError(t, err)
+	defer os.RemoveAll(cacheDir)
+
+	originalCacheDir := CloneCacheDir
+	defer func() { CloneCacheDir = originalCacheDir }()
+	CloneCacheDir = cacheDir
+
+	repo, commit := makeTestUpstreamRepo(t)
+	stats := NewStatsTracker()
+
+	firstDir, _, err := cloneLocalRepository(repo, "master", stats)
+	require.NoError(t, err)
+	defer os.RemoveAll(firstDir)
+	assert.DirExists(t, cloneCachePath(cacheDir, repo))
+
+	// A commit pushed after the mirror was created must be fetched into it, rather than the stale mirror being cloned
+	latest := commit("second")
+
+	secondDir, localRepository, err := cloneLocalRepository(repo, "master", stats)
+	require.NoError(t, err)
+	defer os.RemoveAll(secondDir)
+
+	head, err := localRepository.Head()
+	require.NoError(t, err)
+	assert.Equal(t, head.Hash(), latest)
+
+	origin, err := localRepository.Remote("origin")
+	require.NoError(t, err)
+	assert.Equal(t, origin.Config().URLs, []string{repo.GetCloneURL()})
+	assert.Empty(t, stats.GetMultiple(CloneCacheUpdateFailed))
+}
+
+func TestCloneLocalRepositoryFallsBackToGithubWhenCloneCacheFails(t *testing.T) {
+	cacheFile, err := ioutil.TempFile("", "git-xargs-clone-cache")
+	require.NoError(t, err)
+	cacheFile.Close()
+	defer os.Remove(cacheFile.Name())
+
+	originalCacheDir := CloneCacheDir
+	defer func() { CloneCacheDir = originalCacheDir }()
+	// A file can't hold the mirror, so the cache can't be updated
+	CloneCacheDir = cacheFile.Name()
+
+	repo, _ := makeTestUpstreamRepo(t)
+	stats := NewStatsTracker()
+
+	repositoryDir, _, err := cloneLocalRepository(repo, "master", stats)
+	defer os.RemoveAll(repositoryDir)
+
+	require.NoError(t, err)
+	assert.Equal(t, len(stats.GetMultiple(CloneCacheUpdateFailed)), 1)
+	assert.Equal(t, len(stats.GetMultiple(RepoSuccessfullyCloned)), 1)
+}
+
+func TestCloneLocalRepositoryLimitsHistoryToCloneDepthAndStaysPushable(t *testing.T) {
+	originalDepth := CloneDepth
+	defer func() { CloneDepth = originalDepth }()
+	CloneDepth = 1
+
+	repo, commit := makeTestUpstreamRepo(t)
+	commit("second")
+
+	repositoryDir, localRepository, err := cloneLocalRepository(repo, "master", NewStatsTracker())
+	require.NoError(t, err)
+	defer os.RemoveAll(repositoryDir)
+
+	commits, err := localRepository.Log(&git.LogOptions{})
+	require.NoError(t, err)
+	count := 0
+	_ = commits.ForEach(func(*object.Commit) error {
+		count++
+		return nil
+	})
+	assert.Equal(t, count, 1)
+
+	// A branch committed to on top of a shallow clone must still be pushable
+	worktree, err := localRepository.Worktree()
+	require.NoError(t, err)
+	require.NoError(t, worktree.Checkout(&git.CheckoutOptions{Branch: plumbing.NewBranchReferenceName("git-xargs"), Create: true}))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(repositoryDir, "LICENSE"), []byte("MIT"), 0644))
+	_, err = worktree.Add("LICENSE")
+	require.NoError(t, err)
+	_, err = worktree.Commit("Add license", &git.CommitOptions{
+		Author: &object.Signature{Name: "git-xargs", Email: "git-xargs@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	stats := NewStatsTracker()
+	require.NoError(t, pushLocalBranch(false, repo, localRepository, stats))
+	assert.Equal(t, len(stats.GetMultiple(PushBranchSucceeded)), 1)
+}
+
+func TestCleanupLocalRepositoryHonorsKeepClones(t *testing.T) {
+	originalKeep := KeepClones
+	defer func() { KeepClones = originalKeep }()
+
+	repositoryDir, err := ioutil.TempDir("", "git-xargs-cloud-nuke")
+	require.NoError(t, err)
+	defer os.RemoveAll(repositoryDir)
+
+	KeepClones = true
+	cleanupLocalRepository(repositoryDir, makeTestRepo())
+	assert.DirExists(t, repositoryDir)
+
+	KeepClones = false
+	cleanupLocalRepository(repositoryDir, makeTestRepo())
+	_, statErr := os.Stat(repositoryDir)
+	assert.True(t, os.IsNotExist(statErr))
+}
@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5/plumbing/object"
+)
+
+// coAuthorRegex matches a co-author in the form git expects in a Co-authored-by trailer, e.g., "Jane Doe <jane@example.com>"
+var coAuthorRegex = regexp.MustCompile(`^[^<>]+ <[^<>\s]+@[^<>\s]+>$`)
+
+// isValidCoAuthor returns true if the co-author is a name followed by an email address in angle brackets
+func isValidCoAuthor(coAuthor string) bool {
+	return coAuthorRegex.MatchString(strings.TrimSpace(coAuthor))
+}
+
+// commitAuthor returns the author passed via --author-name and --author-email, who is also recorded as the committer, or
+// nil to have go-git fall back to the user.name and user.email of the git config
+func commitAuthor() *object.Signature {
+	if AuthorName == "" && AuthorEmail == "" {
+		return nil
+	}
+
+	return &object.Signature{
+		Name:  AuthorName,
+		Email: AuthorEmail,
+		When:  time.Now(),
+	}
+}
+
+// withCoAuthorTrailers appends a Co-authored-by trailer to the commit message for each co-author, which Github and
+// GitLab credit as authors of the commit alongside its author
+func withCoAuthorTrailers(commitMessage string, coAuthors []string) string {
+	if len(coAuthors) == 0 {
+		return commitMessage
+	}
+
+	trailers := make([]string, len(coAuthors))
+	for i, coAuthor := range coAuthors {
+		trailers[i] = "Co-authored-by: " + strings.TrimSpace(coAuthor)
+	}
+
+	return strings.TrimRight(commitMessage, "\n") + "\n\n" + strings.Join(trailers, "\n") + "\n"
+}
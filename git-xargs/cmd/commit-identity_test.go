@@ -0,0 +1,29 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitLocalChangesUsesAuthorAndCoAuthors(t *testing.T) {
+	defer setCommitIdentityFlags(t, "git-xargs bot", "bot@example.com", []string{"Jane Doe <jane@example.com>", "John Roe <john@example.com>"}, SigningFormatGPG, "")()
+
+	localRepository, worktree := makeTestWorktreeWithChanges(t)
+	require.NoError(t, commitLocalChanges("Add license\n", worktree, makeTestRepo(), localRepository, NewStatsTracker()))
+
+	commit := headCommit(t, localRepository)
+	assert.Equal(t, commit.Author.Name, "git-xargs bot")
+	assert.Equal(t, commit.Author.Email, "bot@example.com")
+	assert.Equal(t, commit.Committer.Email, "bot@example.com")
+	assert.Equal(t, commit.Message, "Add license\n\nCo-authored-by: Jane Doe <jane@example.com>\nCo-authored-by: John Roe <john@example.com>\n")
+	assert.Empty(t, commit.PGPSignature)
+}
+
+func TestIsValidCoAuthor(t *testing.T) {
+	assert.True(t, isValidCoAuthor("Jane Doe <jane@example.com>"))
+	assert.False(t, isValidCoAuthor("Jane Doe"))
+	assert.False(t, isValidCoAuthor("jane@example.com"))
+	assert.False(t, isValidCoAuthor("<jane@example.com>"))
+}
@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"golang.org/x/crypto/ssh"
+)
+
+const (
+	// SigningFormatGPG signs commits with an OpenPGP key, as `git commit -S` does by default
+	SigningFormatGPG = "gpg"
+	// SigningFormatSSH signs commits with an SSH key, as `git commit -S` does when gpg.format is ssh
+	SigningFormatSSH = "ssh"
+)
+
+// sshSignatureNamespace is the namespace git signs commits in, which verifiers check the signature was made for
+const sshSignatureNamespace = "git"
+
+// sshSignatureHashAlgorithm is the algorithm the commit is hashed with before the hash is signed
+const sshSignatureHashAlgorithm = "sha512"
+
+// activeCommitSigner is the signer configured by ConfigureCommitSigner, which every commit of the run is signed with. It's
+// nil when commits are not signed
+var activeCommitSigner *CommitSigner
+
+// CommitSigner holds the key commits are signed with, which is either an OpenPGP or an SSH key depending on the format
+type CommitSigner struct {
+	Format string
+	gpgKey *openpgp.Entity
+	sshKey ssh.Signer
+}
+
+func isValidSigningFormat(format string) bool {
+	return format == SigningFormatGPG || format == SigningFormatSSH
+}
+
+// ConfigureCommitSigner loads the key passed via --signing-key, decrypting it with GIT_XARGS_SIGNING_KEY_PASSPHRASE if
+// it's protected, so that every commit of the run is signed with it. Commits are not signed if no key was passed
+func ConfigureCommitSigner(format, keyPath, passphrase string) (*CommitSigner, error) {
+	if keyPath == "" {
+		activeCommitSigner = nil
+		return nil, nil
+	}
+
+	keyBytes, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	signer := &CommitSigner{Format: format}
+	switch format {
+	case SigningFormatGPG:
+		signer.gpgKey, err = readGPGSigningKey(keyBytes, passphrase)
+	case SigningFormatSSH:
+		signer.sshKey, err = readSSHSigningKey(keyBytes, passphrase)
+	default:
+		err = fmt.Errorf("unknown signing format %q", format)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	activeCommitSigner = signer
+	return signer, nil
+}
+
+// readGPGSigningKey returns the first private key in the armored OpenPGP key ring, along with its subkeys, decrypted
+// with the supplied passphrase if they're encrypted
+func readGPGSigningKey(keyBytes []byte, passphrase string) (*openpgp.Entity, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(keyBytes))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entity := range entities {
+		if entity.PrivateKey == nil {
+			continue
+		}
+
+		if entity.PrivateKey.Encrypted {
+			if passphrase == "" {
+				return nil, errors.New("the GPG signing key is passphrase protected, but GIT_XARGS_SIGNING_KEY_PASSPHRASE is not set")
+			}
+			if err := entity.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+				return nil, err
+			}
+		}
+		for _, subkey := range entity.Subkeys {
+			if subkey.PrivateKey != nil && subkey.PrivateKey.Encrypted {
+				if err := subkey.PrivateKey.Decrypt([]byte(passphrase)); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return entity, nil
+	}
+
+	return nil, errors.New("no private key found in the GPG signing key file")
+}
+
+// readSSHSigningKey parses the SSH private key, decrypting it with the supplied passphrase if it's protected
+func readSSHSigningKey(keyBytes []byte, passphrase string) (ssh.Signer, error) {
+	signer, err := ssh.ParsePrivateKey(keyBytes)
+
+	var passphraseErr *ssh.PassphraseMissingError
+	if errors.As(err, &passphraseErr) {
+		if passphrase == "" {
+			return nil, errors.New("the SSH signing key is passphrase protected, but GIT_XARGS_SIGNING_KEY_PASSPHRASE is not set")
+		}
+		return ssh.ParsePrivateKeyWithPassphrase(keyBytes, []byte(passphrase))
+	}
+
+	return signer, err
+}
+
+// applyToCommitOptions has go-git sign the commit itself, which it only supports doing with OpenPGP keys
+func (s *CommitSigner) applyToCommitOptions(commitOps *git.CommitOptions) {
+	if s != nil && s.Format == SigningFormatGPG {
+		commitOps.SignKey = s.gpgKey
+	}
+}
+
+// signHead signs the commit at HEAD with the SSH key, when that's what commits are signed with, and moves the current
+// branch to the signed commit. go-git has no support for SSH signatures, so the commit is made unsigned and then
+// rewritten with its signature, the same way git itself inserts the signature into the commit
+func (s *CommitSigner) signHead(localRepository *git.Repository) error {
+	if s == nil || s.Format != SigningFormatSSH {
+		return nil
+	}
+
+	head, err := localRepository.Head()
+	if err != nil {
+		return err
+	}
+	commit, err := localRepository.CommitObject(head.Hash())
+	if err != nil {
+		return err
+	}
+
+	unsigned := &plumbing.MemoryObject{}
+	if err := commit.EncodeWithoutSignature(unsigned); err != nil {
+		return err
+	}
+	reader, err := unsigned.Reader()
+	if err != nil {
+		return err
+	}
+	message, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	signature, err := sshSignature(s.sshKey, message)
+	if err != nil {
+		return err
+	}
+	commit.PGPSignature = signature
+
+	signed := localRepository.Storer.NewEncodedObject()
+	if err := commit.Encode(signed); err != nil {
+		return err
+	}
+	signedHash, err := localRepository.Storer.SetEncodedObject(signed)
+	if err != nil {
+		return err
+	}
+
+	return localRepository.Storer.SetReference(plumbing.NewHashReference(head.Name(), signedHash))
+}
+
+// sshSignature returns the armored SSH signature of the message, in the format `ssh-keygen -Y sign` produces and
+// `git verify-commit` checks. See https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+func sshSignature(signer ssh.Signer, message []byte) (string, error) {
+	hash := sha512.Sum512(message)
+
+	signedData := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{sshSignatureNamespace, "", sshSignatureHashAlgorithm, hash[:]})...)
+
+	var signature *ssh.Signature
+	var err error
+	// RSA keys must sign with SHA-512 rather than the SHA-1 ssh-rsa signatures are made with by default
+	if algorithmSigner, ok := signer.(ssh.AlgorithmSigner); ok && signer.PublicKey().Type() == ssh.KeyAlgoRSA {
+		signature, err = algorithmSigner.SignWithAlgorithm(rand.Reader, signedData, ssh.SigAlgoRSASHA2512)
+	} else {
+		signature, err = signer.Sign(rand.Reader, signedData)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	blob := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}{1, signer.PublicKey().Marshal(), sshSignatureNamespace, "", sshSignatureHashAlgorithm, ssh.Marshal(signature)})...)
+
+	encoded := base64.StdEncoding.EncodeToString(blob)
+	var armored strings.Builder
+	armored.WriteString("-----BEGIN SSH SIGNATURE-----\n")
+	for len(encoded) > 70 {
+		armored.WriteString(encoded[:70] + "\n")
+		encoded = encoded[70:]
+	}
+	armored.WriteString(encoded + "\n")
+	armored.WriteString("-----END SSH SIGNATURE-----\n")
+	return armored.String(), nil
+}
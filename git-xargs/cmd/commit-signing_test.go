@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+)
+
+// makeTestWorktreeWithChanges creates a local repo with a single commit, and then stages a new file in it, as the scripts
+// run against a repo would have
+func makeTestWorktreeWithChanges(t *testing.T) (*git.Repository, *git.Worktree) {
+	dir, err := ioutil.TempDir("", "git-xargs-commit")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	localRepository, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := localRepository.Worktree()
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "README.md"), []byte("# repo\n"), 0644))
+	_, err = worktree.Add("README.md")
+	require.NoError(t, err)
+	_, err = worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "upstream", Email: "upstream@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "LICENSE"), []byte("MIT\n"), 0644))
+	_, err = worktree.Add("LICENSE")
+	require.NoError(t, err)
+
+	return localRepository, worktree
+}
+
+// setCommitIdentityFlags sets the author and co-authors, and configures the signing key, returning a function that
+// restores them
+func setCommitIdentityFlags(t *testing.T, name, email string, coAuthors []string, format, keyPath string) func() {
+	originalName, originalEmail, originalCoAuthors, originalSigner := AuthorName, AuthorEmail, CoAuthors, activeCommitSigner
+	AuthorName, AuthorEmail, CoAuthors = name, email, coAuthors
+	_, err := ConfigureCommitSigner(format, keyPath, "")
+	require.NoError(t, err)
+
+	return func() {
+		AuthorName, AuthorEmail, CoAuthors, activeCommitSigner = originalName, originalEmail, originalCoAuthors, originalSigner
+	}
+}
+
+// writeTestKeyFile writes the key to a temp file, returning its path
+func writeTestKeyFile(t *testing.T, key []byte) string {
+	keyFile, err := ioutil.TempFile("", "git-xargs-signing-key")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.Remove(keyFile.Name()) })
+
+	_, err = keyFile.Write(key)
+	require.NoError(t, err)
+	require.NoError(t, keyFile.Close())
+	return keyFile.Name()
+}
+
+func headCommit(t *testing.T, localRepository *git.Repository) *object.Commit {
+	head, err := localRepository.Head()
+	require.NoError(t, err)
+	commit, err := localRepository.CommitObject(head.Hash())
+	require.NoError(t, err)
+	return commit
+}
+
+// verifyTestSSHSignature checks the armored SSH signature of the commit was made over it by the supplied key, returning
+// the algorithm it was made with
+func verifyTestSSHSignature(t *testing.T, commit *object.Commit, publicKey ssh.PublicKey) string {
+	armored := strings.TrimSpace(commit.PGPSignature)
+	require.True(t, strings.HasPrefix(armored, "-----BEGIN SSH SIGNATURE-----\n"))
+	require.True(t, strings.HasSuffix(armored, "\n-----END SSH SIGNATURE-----"))
+
+	lines := strings.Split(armored, "\n")
+	blob, err := base64.StdEncoding.DecodeString(strings.Join(lines[1:len(lines)-1], ""))
+	require.NoError(t, err)
+	require.Equal(t, string(blob[:6]), "SSHSIG")
+
+	var parsed struct {
+		Version       uint32
+		PublicKey     []byte
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Signature     []byte
+	}
+	require.NoError(t, ssh.Unmarshal(blob[6:], &parsed))
+	assert.Equal(t, parsed.Version, uint32(1))
+	assert.Equal(t, parsed.PublicKey, publicKey.Marshal())
+	assert.Equal(t, parsed.Namespace, "git")
+	assert.Equal(t, parsed.HashAlgorithm, "sha512")
+
+	var signature ssh.Signature
+	require.NoError(t, ssh.Unmarshal(parsed.Signature, &signature))
+
+	unsigned := &plumbing.MemoryObject{}
+	require.NoError(t, commit.EncodeWithoutSignature(unsigned))
+	reader, err := unsigned.Reader()
+	require.NoError(t, err)
+	message, err := ioutil.ReadAll(reader)
+	require.NoError(t, err)
+	hash := sha512.Sum512(message)
+
+	signedData := append([]byte("SSHSIG"), ssh.Marshal(struct {
+		Namespace     string
+		Reserved      string
+		HashAlgorithm string
+		Hash          []byte
+	}{"git", "", "sha512", hash[:]})...)
+	assert.NoError(t, publicKey.Verify(signedData, &signature))
+
+	return signature.Format
+}
+
+func TestCommitLocalChangesSignsWithGPGKey(t *testing.T) {
+	entity, err := openpgp.NewEntity("git-xargs bot", "", "bot@example.com", nil)
+	require.NoError(t, err)
+
+	var privateKey, publicKey bytes.Buffer
+	privateWriter, err := armor.Encode(&privateKey, openpgp.PrivateKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.SerializePrivate(privateWriter, nil))
+	require.NoError(t, privateWriter.Close())
+	publicWriter, err := armor.Encode(&publicKey, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, entity.Serialize(publicWriter))
+	require.NoError(t, publicWriter.Close())
+
+	defer setCommitIdentityFlags(t, "git-xargs bot", "bot@example.com", nil, SigningFormatGPG, writeTestKeyFile(t, privateKey.Bytes()))()
+
+	localRepository, worktree := makeTestWorktreeWithChanges(t)
+	require.NoError(t, commitLocalChanges("Add license", worktree, makeTestRepo(), localRepository, NewStatsTracker()))
+
+	commit := headCommit(t, localRepository)
+	assert.Contains(t, commit.PGPSignature, "-----BEGIN PGP SIGNATURE-----")
+	signer, err := commit.Verify(publicKey.String())
+	require.NoError(t, err)
+	assert.Equal(t, signer.PrimaryKey.KeyId, entity.PrimaryKey.KeyId)
+}
+
+func TestCommitLocalChangesSignsWithSSHKey(t *testing.T) {
+	_, ed25519Key, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	ed25519DER, err := x509.MarshalPKCS8PrivateKey(ed25519Key)
+	require.NoError(t, err)
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		name              string
+		key               interface{}
+		pemBlock          *pem.Block
+		expectedAlgorithm string
+	}{
+		{"ed25519", ed25519Key, &pem.Block{Type: "PRIVATE KEY", Bytes: ed25519DER}, ssh.KeyAlgoED25519},
+		{"rsa", rsaKey, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(rsaKey)}, ssh.SigAlgoRSASHA2512},
+	}
+
+	for _, testCase := range testCases {
+		t.Run(testCase.name, func(t *testing.T) {
+			defer setCommitIdentityFlags(t, "git-xargs bot", "bot@example.com", nil, SigningFormatSSH, writeTestKeyFile(t, pem.EncodeToMemory(testCase.pemBlock)))()
+
+			signer, err := ssh.NewSignerFromKey(testCase.key)
+			require.NoError(t, err)
+
+			localRepository, worktree := makeTestWorktreeWithChanges(t)
+			require.NoError(t, commitLocalChanges("Add license", worktree, makeTestRepo(), localRepository, NewStatsTracker()))
+
+			// The branch was moved to the signed commit, which has the unsigned commit's content
+			commit := headCommit(t, localRepository)
+			assert.Equal(t, commit.Message, "Add license")
+			assert.Equal(t, verifyTestSSHSignature(t, commit, signer.PublicKey()), testCase.expectedAlgorithm)
+
+			file, err := commit.File("LICENSE")
+			require.NoError(t, err)
+			contents, err := file.Contents()
+			require.NoError(t, err)
+			assert.Equal(t, contents, "MIT\n")
+		})
+	}
+}
+
+func TestConfigureCommitSignerRequiresPassphraseForProtectedKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	block, err := x509.EncryptPEMBlock(rand.Reader, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(rsaKey), []byte("hunter2"), x509.PEMCipherAES256)
+	require.NoError(t, err)
+	keyPath := writeTestKeyFile(t, pem.EncodeToMemory(block))
+
+	defer func(original *CommitSigner) { activeCommitSigner = original }(activeCommitSigner)
+
+	_, err = ConfigureCommitSigner(SigningFormatSSH, keyPath, "")
+	assert.Error(t, err)
+
+	signer, err := ConfigureCommitSigner(SigningFormatSSH, keyPath, "hunter2")
+	require.NoError(t, err)
+	assert.Equal(t, activeCommitSigner, signer)
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupEndToEndRun points git-xargs at the fake Github Enterprise Server, as --github-base-url would, and sets the
+// flags a run depends on to their defaults. Everything is restored once the test completes. Commits are made as the
+// identity in a throwaway global git config, since go-git reads the author from there. That config is found via
+// XDG_CONFIG_HOME rather than HOME, which go-git only looks up once per process
+func setupEndToEndRun(t *testing.T, fake *fakeGithub) {
+	configDir, err := ioutil.TempDir("", "git-xargs-config")
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Join(configDir, "git"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(configDir, "git", "config"), []byte("[user]\n\tname = git-xargs\n\temail = git-xargs@example.com\n"), 0644))
+
+	logDir, err := ioutil.TempDir("", "git-xargs-script-logs")
+	require.NoError(t, err)
+
+	originalConfigDir, originalToken := os.Getenv("XDG_CONFIG_HOME"), os.Getenv("GITHUB_OAUTH_TOKEN")
+	originalBaseURL, originalBranch, originalCommitMessage := GithubBaseURL, BranchName, CommitMessage
+	originalTitle, originalDescription, originalLogDir := PullRequestTitle, PullRequestDescription, ScriptLogDir
+	originalRetries, originalUpdate, originalCredentials := MaxAPIRetries, UpdateExisting, activeRepoHostCredentials
+	t.Cleanup(func() {
+		os.Setenv("XDG_CONFIG_HOME", originalConfigDir)
+		os.Setenv("GITHUB_OAUTH_TOKEN", originalToken)
+		GithubBaseURL, BranchName, CommitMessage = originalBaseURL, originalBranch, originalCommitMessage
+		PullRequestTitle, PullRequestDescription, ScriptLogDir = originalTitle, originalDescription, originalLogDir
+		MaxAPIRetries, UpdateExisting, activeRepoHostCredentials = originalRetries, originalUpdate, originalCredentials
+		os.RemoveAll(configDir)
+		os.RemoveAll(logDir)
+	})
+
+	os.Setenv("XDG_CONFIG_HOME", configDir)
+	os.Setenv("GITHUB_OAUTH_TOKEN", fake.token)
+	GithubBaseURL = fake.URL()
+	BranchName = "git-xargs"
+	CommitMessage = "Add license to {{.RepoName}}"
+	PullRequestTitle = "Add license"
+	PullRequestDescription = "Adds a license to {{.RepoFullName}}"
+	ScriptLogDir = logDir
+	MaxAPIRetries = 0
+	UpdateExisting = false
+}
+
+// addLicenseToCloudNuke writes the supplied license to the cloud-nuke repo only, leaving every other repo unchanged
+func addLicenseToCloudNuke(license string) ScriptCollection {
+	scripts := ScriptCollection{}
+	scripts.Add(Script{Command: fmt.Sprintf(`if [ "$XARGS_REPO_NAME" = "cloud-nuke" ]; then echo %s > LICENSE; fi`, license)})
+	return scripts
+}
+
+// runEndToEnd runs git-xargs against every repo of the fake's organization, as the root command would, adding the
+// supplied license to cloud-nuke
+func runEndToEnd(t *testing.T, fake *fakeGithub, dryRun bool, license string) *RunStats {
+	host := ConfigureRepoHost(context.Background())
+	filters, err := NewRepoFilters(nil, "", false, false, "", "")
+	require.NoError(t, err)
+
+	originalDryRun := DryRun
+	defer func() { DryRun = originalDryRun }()
+	DryRun = dryRun
+
+	stats := NewStatsTracker()
+	OperateOnRepos(context.Background(), host, fake.org, nil, filters, addLicenseToCloudNuke(license), stats)
+	return stats
+}
+
+// upstreamBranchFile returns the contents of the file on the branch of the bare repo, failing the test if either is missing
+func upstreamBranchFile(t *testing.T, upstreamDir, branch, path string) string {
+	upstream, err := git.PlainOpen(upstreamDir)
+	require.NoError(t, err)
+	ref, err := upstream.Reference(plumbing.NewBranchReferenceName(branch), false)
+	require.NoError(t, err)
+	commit, err := upstream.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	file, err := commit.File(path)
+	require.NoError(t, err)
+	contents, err := file.Contents()
+	require.NoError(t, err)
+	return contents
+}
+
+func TestEndToEndRunAgainstGithubEnterpriseServer(t *testing.T) {
+	fake := newFakeGithub(t, "gruntwork-io", "ghe-token")
+	_, cloudNukeDir := fake.addRepo("cloud-nuke")
+	_, fetchDir := fake.addRepo("fetch")
+	setupEndToEndRun(t, fake)
+
+	stats := runEndToEnd(t, fake, false, "MIT")
+
+	// Both repos were listed, a page at a time, from the instance rather than from github.com
+	assert.Equal(t, fake.callCount("GET orgs/gruntwork-io/repos"), 2)
+	assert.Equal(t, len(stats.GetMultiple(ReposSelected)), 2)
+
+	// The changed repo had its branch pushed and a pull request opened against its default branch
+	assert.Equal(t, upstreamBranchFile(t, cloudNukeDir, "git-xargs", "LICENSE"), "MIT\n")
+	pulls := fake.pullRequests("cloud-nuke")
+	require.Equal(t, len(pulls), 1)
+	assert.Equal(t, pulls[0].GetTitle(), "Add license")
+	assert.Contains(t, pulls[0].GetBody(), "Adds a license to gruntwork-io/cloud-nuke")
+	assert.Equal(t, pulls[0].GetHead().GetRef(), "git-xargs")
+	assert.Equal(t, pulls[0].GetBase().GetRef(), "master")
+	assert.Equal(t, stats.GetPullRequests()["cloud-nuke"], fake.URL()+"/gruntwork-io/cloud-nuke/pull/1")
+
+	upstream, err := git.PlainOpen(cloudNukeDir)
+	require.NoError(t, err)
+	ref, err := upstream.Reference(plumbing.NewBranchReferenceName("git-xargs"), false)
+	require.NoError(t, err)
+	commit, err := upstream.CommitObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, commit.Message, "Add license to cloud-nuke")
+
+	// The unchanged repo was left alone
+	assert.Equal(t, len(stats.GetMultiple(NoChangesRequired)), 1)
+	assert.Empty(t, fake.pullRequests("fetch"))
+	fetchUpstream, err := git.PlainOpen(fetchDir)
+	require.NoError(t, err)
+	_, err = fetchUpstream.Reference(plumbing.NewBranchReferenceName("git-xargs"), false)
+	assert.Equal(t, err, plumbing.ErrReferenceNotFound)
+
+	assert.Empty(t, stats.GetFailedRepoNames())
+}
+
+func TestEndToEndRunSkipsExistingBranchUnlessUpdatingIt(t *testing.T) {
+	fake := newFakeGithub(t, "gruntwork-io", "ghe-token")
+	_, cloudNukeDir := fake.addRepo("cloud-nuke")
+	setupEndToEndRun(t, fake)
+
+	runEndToEnd(t, fake, false, "MIT")
+	require.Equal(t, len(fake.pullRequests("cloud-nuke")), 1)
+
+	// A second run finds the branch pushed by the first, and leaves it be
+	stats := runEndToEnd(t, fake, false, "MIT")
+	assert.Equal(t, len(stats.GetMultiple(TargetBranchExistsNotUpdated)), 1)
+	assert.Equal(t, len(fake.pullRequests("cloud-nuke")), 1)
+
+	// Updating the campaign adds to the branch and edits the pull request already open for it, rather than opening another
+	UpdateExisting = true
+	PullRequestTitle = "Add Apache license"
+	stats = runEndToEnd(t, fake, false, "Apache-2.0")
+
+	assert.Equal(t, len(stats.GetMultiple(PullRequestUpdated)), 1)
+	pulls := fake.pullRequests("cloud-nuke")
+	require.Equal(t, len(pulls), 1)
+	assert.Equal(t, pulls[0].GetTitle(), "Add Apache license")
+	assert.Equal(t, upstreamBranchFile(t, cloudNukeDir, "git-xargs", "LICENSE"), "Apache-2.0\n")
+	assert.Equal(t, fake.callCount("PATCH repos/gruntwork-io/cloud-nuke/pulls/1"), 1)
+}
+
+func TestEndToEndDryRunLeavesGithubEnterpriseServerUntouched(t *testing.T) {
+	fake := newFakeGithub(t, "gruntwork-io", "ghe-token")
+	_, cloudNukeDir := fake.addRepo("cloud-nuke")
+	setupEndToEndRun(t, fake)
+
+	stats := runEndToEnd(t, fake, true, "MIT")
+
+	assert.Equal(t, len(stats.GetMultiple(PushBranchSkipped)), 1)
+	assert.Empty(t, fake.pullRequests("cloud-nuke"))
+
+	upstream, err := git.PlainOpen(cloudNukeDir)
+	require.NoError(t, err)
+	_, err = upstream.Reference(plumbing.NewBranchReferenceName("git-xargs"), false)
+	assert.Equal(t, err, plumbing.ErrReferenceNotFound)
+}
+
+func TestEndToEndRunAsGithubAppAgainstGithubEnterpriseServer(t *testing.T) {
+	fake := newFakeGithub(t, "gruntwork-io", "ghs_installation")
+	_, cloudNukeDir := fake.addRepo("cloud-nuke")
+	setupEndToEndRun(t, fake)
+	os.Setenv("GITHUB_OAUTH_TOKEN", "")
+
+	keyFile, err := ioutil.TempFile("", "git-xargs-app-key")
+	require.NoError(t, err)
+	defer os.Remove(keyFile.Name())
+	require.NoError(t, pem.Encode(keyFile, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(makeTestGithubAppKey(t))}))
+	require.NoError(t, keyFile.Close())
+
+	originalAppID, originalInstallationID, originalKeyFile := GithubAppID, GithubAppInstallationID, GithubAppPrivateKeyFile
+	defer func() {
+		GithubAppID, GithubAppInstallationID, GithubAppPrivateKeyFile = originalAppID, originalInstallationID, originalKeyFile
+	}()
+	GithubAppID, GithubAppInstallationID, GithubAppPrivateKeyFile = 42, 7, keyFile.Name()
+
+	stats := runEndToEnd(t, fake, false, "MIT")
+
+	// The installation token was minted by the instance, and the API calls that followed were authenticated with it
+	assert.Equal(t, fake.callCount("POST app/installations/7/access_tokens"), 1)
+	assert.Equal(t, len(fake.pullRequests("cloud-nuke")), 1)
+	assert.Equal(t, upstreamBranchFile(t, cloudNukeDir, "git-xargs", "LICENSE"), "MIT\n")
+	assert.Empty(t, stats.GetFailedRepoNames())
+}
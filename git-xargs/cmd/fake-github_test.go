@@ -0,0 +1,265 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/google/go-github/v32/github"
+)
+
+// fakeGithub is a fake Github Enterprise Server, serving the REST API under /api/v3/ for the repos of a single
+// organization. Each repo is backed by a bare repo on the local filesystem, which is what its clone URL points at, so
+// that git-xargs can clone it and push branches to it. Pull requests opened and edited via the API are recorded
+type fakeGithub struct {
+	t      *testing.T
+	server *httptest.Server
+	org    string
+	// token is the token every API call must be authenticated with, unless it's an app authenticating with its JWT
+	token string
+
+	mu    sync.Mutex
+	repos []*github.Repository
+	// pulls are the pull requests opened against each repo, by repo name
+	pulls map[string][]*github.PullRequest
+	// calls are the method and path, relative to /api/v3/, of every API call made
+	calls []string
+}
+
+// newFakeGithub starts a fake Github Enterprise Server whose API calls must be authenticated with the supplied token
+func newFakeGithub(t *testing.T, org, token string) *fakeGithub {
+	fake := &fakeGithub{
+		t:     t,
+		org:   org,
+		token: token,
+		pulls: map[string][]*github.PullRequest{},
+	}
+	fake.server = httptest.NewServer(http.HandlerFunc(fake.serveHTTP))
+	t.Cleanup(fake.server.Close)
+	return fake
+}
+
+// URL returns the URL of the fake instance, which is what --github-base-url is set to
+func (f *fakeGithub) URL() string {
+	return f.server.URL
+}
+
+// addRepo adds a repo, with a single commit on master, to the fake's organization and returns it along with the path of
+// the bare repo backing it
+func (f *fakeGithub) addRepo(name string) (*github.Repository, string) {
+	repo, _ := makeTestUpstreamRepo(f.t)
+	upstreamDir := repo.GetCloneURL()
+
+	repo.Name = github.String(name)
+	repo.FullName = github.String(fmt.Sprintf("%s/%s", f.org, name))
+	repo.Owner = &github.User{Login: github.String(f.org)}
+	repo.DefaultBranch = github.String("master")
+	repo.HTMLURL = github.String(fmt.Sprintf("%s/%s/%s", f.server.URL, f.org, name))
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.repos = append(f.repos, repo)
+	return repo, upstreamDir
+}
+
+// pullRequests returns the pull requests opened against the named repo
+func (f *fakeGithub) pullRequests(name string) []*github.PullRequest {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]*github.PullRequest(nil), f.pulls[name]...)
+}
+
+// callCount returns how many API calls were made with the supplied method and path, relative to /api/v3/
+func (f *fakeGithub) callCount(call string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	count := 0
+	for _, made := range f.calls {
+		if made == call {
+			count++
+		}
+	}
+	return count
+}
+
+func (f *fakeGithub) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	// Only the API is served, so any call made to github.com's API paths rather than the instance's is caught here
+	if !strings.HasPrefix(r.URL.Path, githubEnterpriseAPIPath) {
+		f.t.Errorf("call made outside of the Github Enterprise Server API: %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+		return
+	}
+	path := strings.TrimPrefix(r.URL.Path, githubEnterpriseAPIPath)
+
+	f.mu.Lock()
+	f.calls = append(f.calls, r.Method+" "+path)
+	f.mu.Unlock()
+
+	parts := strings.Split(path, "/")
+
+	// Github Apps authenticate with a JWT to mint installation tokens, which are then used like any other token
+	if r.Method == http.MethodPost && len(parts) == 4 && parts[0] == "app" && parts[1] == "installations" && parts[3] == "access_tokens" {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "Bearer ") {
+			http.Error(w, `{"message": "A JSON web token could not be decoded"}`, http.StatusUnauthorized)
+			return
+		}
+		writeFakeGithubJSON(w, http.StatusCreated, map[string]string{"token": f.token, "expires_at": "2099-01-01T00:00:00Z"})
+		return
+	}
+
+	if auth := r.Header.Get("Authorization"); auth != "Bearer "+f.token && auth != "token "+f.token {
+		http.Error(w, `{"message": "Bad credentials"}`, http.StatusUnauthorized)
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodGet && len(parts) == 3 && parts[0] == "orgs" && parts[2] == "repos":
+		f.listOrgRepos(w, r, parts[1])
+	case len(parts) >= 3 && parts[0] == "repos" && parts[1] == f.org:
+		repo := f.findRepo(parts[2])
+		if repo == nil {
+			http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+			return
+		}
+		f.serveRepo(w, r, repo, parts[3:])
+	default:
+		f.t.Errorf("unexpected Github API call: %s %s", r.Method, path)
+		http.NotFound(w, r)
+	}
+}
+
+// listOrgRepos lists the organization's repos a page at a time, one repo per page, so that paging is exercised too
+func (f *fakeGithub) listOrgRepos(w http.ResponseWriter, r *http.Request, org string) {
+	if org != f.org {
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+		return
+	}
+
+	f.mu.Lock()
+	repos := append([]*github.Repository(nil), f.repos...)
+	f.mu.Unlock()
+
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+	if page < len(repos) {
+		w.Header().Set("Link", fmt.Sprintf(`<%s%sorgs/%s/repos?page=%d>; rel="next"`, f.server.URL, githubEnterpriseAPIPath, org, page+1))
+	}
+
+	var pageRepos []*github.Repository
+	if page <= len(repos) {
+		pageRepos = repos[page-1 : page]
+	}
+	writeFakeGithubJSON(w, http.StatusOK, pageRepos)
+}
+
+func (f *fakeGithub) findRepo(name string) *github.Repository {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, repo := range f.repos {
+		if repo.GetName() == name {
+			return repo
+		}
+	}
+	return nil
+}
+
+// serveRepo serves the calls about a single repo, where rest is the path following /repos/:owner/:repo
+func (f *fakeGithub) serveRepo(w http.ResponseWriter, r *http.Request, repo *github.Repository, rest []string) {
+	switch {
+	case r.Method == http.MethodGet && len(rest) == 0:
+		writeFakeGithubJSON(w, http.StatusOK, repo)
+	case r.Method == http.MethodGet && len(rest) == 2 && rest[0] == "branches":
+		// Branches exist on the fake exactly when they were pushed to the bare repo backing it
+		upstream, err := git.PlainOpen(repo.GetCloneURL())
+		if err != nil {
+			f.t.Errorf("could not open the bare repo backing %s: %v", repo.GetFullName(), err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if _, err := upstream.Reference(plumbing.NewBranchReferenceName(rest[1]), false); err != nil {
+			http.Error(w, `{"message": "Branch not found"}`, http.StatusNotFound)
+			return
+		}
+		writeFakeGithubJSON(w, http.StatusOK, map[string]string{"name": rest[1]})
+	case r.Method == http.MethodPost && len(rest) == 1 && rest[0] == "pulls":
+		var newPR github.NewPullRequest
+		if !f.decodeBody(w, r, &newPR) {
+			return
+		}
+
+		f.mu.Lock()
+		number := len(f.pulls[repo.GetName()]) + 1
+		pr := &github.PullRequest{
+			Number:  github.Int(number),
+			State:   github.String("open"),
+			Title:   newPR.Title,
+			Body:    newPR.Body,
+			Draft:   newPR.Draft,
+			HTMLURL: github.String(fmt.Sprintf("%s/pull/%d", repo.GetHTMLURL(), number)),
+			Head:    &github.PullRequestBranch{Ref: github.String(strings.TrimPrefix(newPR.GetHead(), "refs/heads/"))},
+			Base:    &github.PullRequestBranch{Ref: newPR.Base},
+		}
+		f.pulls[repo.GetName()] = append(f.pulls[repo.GetName()], pr)
+		f.mu.Unlock()
+
+		writeFakeGithubJSON(w, http.StatusCreated, pr)
+	case r.Method == http.MethodGet && len(rest) == 1 && rest[0] == "pulls":
+		// Pull requests are filtered by their head, which is given as owner:branch
+		head := strings.TrimPrefix(r.URL.Query().Get("head"), f.org+":")
+
+		var open []*github.PullRequest
+		for _, pr := range f.pullRequests(repo.GetName()) {
+			if pr.GetState() == "open" && pr.GetHead().GetRef() == head && pr.GetBase().GetRef() == r.URL.Query().Get("base") {
+				open = append(open, pr)
+			}
+		}
+		writeFakeGithubJSON(w, http.StatusOK, open)
+	case r.Method == http.MethodPatch && len(rest) == 2 && rest[0] == "pulls":
+		number, _ := strconv.Atoi(rest[1])
+		var edit github.PullRequest
+		if !f.decodeBody(w, r, &edit) {
+			return
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, pr := range f.pulls[repo.GetName()] {
+			if pr.GetNumber() == number {
+				pr.Title, pr.Body = edit.Title, edit.Body
+				writeFakeGithubJSON(w, http.StatusOK, pr)
+				return
+			}
+		}
+		http.Error(w, `{"message": "Not Found"}`, http.StatusNotFound)
+	default:
+		f.t.Errorf("unexpected Github API call: %s /repos/%s/%s", r.Method, repo.GetFullName(), strings.Join(rest, "/"))
+		http.NotFound(w, r)
+	}
+}
+
+// decodeBody decodes the JSON body of the call into v, failing the call and the test if it's malformed
+func (f *fakeGithub) decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		f.t.Errorf("malformed body in call to %s %s: %v", r.Method, r.URL.Path, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return false
+	}
+	return true
+}
+
+func writeFakeGithubJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
@@ -0,0 +1,119 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// RepoFilters narrows down the repos selected via any of the selection methods (file, search query, user or org) before
+// any of them are processed. The zero value lets every repo through
+type RepoFilters struct {
+	// Topics lists the topics a repo must have ALL of
+	Topics []string
+	// Language is the primary language, as detected by Github, that a repo must have. Matched case-insensitively
+	Language        string
+	ExcludeArchived bool
+	ExcludeForks    bool
+	// IncludeNameRegex, when set, must match the repo's name
+	IncludeNameRegex *regexp.Regexp
+	// ExcludeNameRegex, when set, must NOT match the repo's name
+	ExcludeNameRegex *regexp.Regexp
+}
+
+// NewRepoFilters builds the RepoFilters for a run from the user-supplied flag values, compiling the name regexes
+func NewRepoFilters(topics []string, language string, excludeArchived, excludeForks bool, includeNameRegex, excludeNameRegex string) (*RepoFilters, error) {
+	filters := &RepoFilters{
+		Language:        strings.TrimSpace(language),
+		ExcludeArchived: excludeArchived,
+		ExcludeForks:    excludeForks,
+	}
+
+	for _, topic := range topics {
+		if topic = strings.TrimSpace(topic); topic != "" {
+			filters.Topics = append(filters.Topics, topic)
+		}
+	}
+
+	if includeNameRegex != "" {
+		re, err := regexp.Compile(includeNameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --include-repo-regex: %s", err)
+		}
+		filters.IncludeNameRegex = re
+	}
+
+	if excludeNameRegex != "" {
+		re, err := regexp.Compile(excludeNameRegex)
+		if err != nil {
+			return nil, fmt.Errorf("invalid --exclude-repo-regex: %s", err)
+		}
+		filters.ExcludeNameRegex = re
+	}
+
+	return filters, nil
+}
+
+// exclusionReason returns a short human-legible reason the repo is filtered out, or an empty string if it passes every filter
+func (f *RepoFilters) exclusionReason(repo *github.Repository) string {
+	if f.ExcludeArchived && repo.GetArchived() {
+		return "repo is archived"
+	}
+
+	if f.ExcludeForks && repo.GetFork() {
+		return "repo is a fork"
+	}
+
+	if f.Language != "" && !strings.EqualFold(f.Language, repo.GetLanguage()) {
+		return fmt.Sprintf("repo language %q is not %q", repo.GetLanguage(), f.Language)
+	}
+
+	for _, topic := range f.Topics {
+		if !hasTopic(repo, topic) {
+			return fmt.Sprintf("repo does not have topic %q", topic)
+		}
+	}
+
+	if f.IncludeNameRegex != nil && !f.IncludeNameRegex.MatchString(repo.GetName()) {
+		return fmt.Sprintf("repo name does not match %s", f.IncludeNameRegex)
+	}
+
+	if f.ExcludeNameRegex != nil && f.ExcludeNameRegex.MatchString(repo.GetName()) {
+		return fmt.Sprintf("repo name matches %s", f.ExcludeNameRegex)
+	}
+
+	return ""
+}
+
+// hasTopic returns true if the repo is tagged with the topic. Github always lowercases topics, so this is case-insensitive
+func hasTopic(repo *github.Repository, topic string) bool {
+	for _, repoTopic := range repo.Topics {
+		if strings.EqualFold(repoTopic, topic) {
+			return true
+		}
+	}
+	return false
+}
+
+// filterRepos returns only the repos that pass every filter, tracking the rest as excluded and logging the reason why
+func filterRepos(repos []*github.Repository, filters *RepoFilters, stats *RunStats) []*github.Repository {
+	var filtered []*github.Repository
+
+	for _, repo := range repos {
+		if reason := filters.exclusionReason(repo); reason != "" {
+			log.WithFields(logrus.Fields{
+				"Repo":   repo.GetName(),
+				"Reason": reason,
+			}).Debug("Excluding repo from processing")
+
+			stats.TrackSingle(RepoExcludedByFilter, repo)
+			continue
+		}
+		filtered = append(filtered, repo)
+	}
+
+	return filtered
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeFilterTestRepos() []*github.Repository {
+	return []*github.Repository{
+		{Name: github.String("terraform-aws-eks"), Language: github.String("HCL"), Topics: []string{"terraform", "aws"}},
+		{Name: github.String("cloud-nuke"), Language: github.String("Go"), Topics: []string{"aws"}},
+		{Name: github.String("old-thing"), Language: github.String("Go"), Archived: github.Bool(true)},
+		{Name: github.String("forked-thing"), Language: github.String("Go"), Fork: github.Bool(true)},
+	}
+}
+
+func repoNames(repos []*github.Repository) []string {
+	var names []string
+	for _, repo := range repos {
+		names = append(names, repo.GetName())
+	}
+	return names
+}
+
+func TestZeroValueRepoFiltersLetEveryRepoThrough(t *testing.T) {
+	stats := NewStatsTracker()
+
+	filtered := filterRepos(makeFilterTestRepos(), &RepoFilters{}, stats)
+
+	assert.Equal(t, len(filtered), 4)
+	assert.Empty(t, stats.GetMultiple(RepoExcludedByFilter))
+}
+
+func TestRepoFiltersExcludeArchivedAndForks(t *testing.T) {
+	stats := NewStatsTracker()
+	filters, err := NewRepoFilters(nil, "", true, true, "", "")
+	require.NoError(t, err)
+
+	filtered := filterRepos(makeFilterTestRepos(), filters, stats)
+
+	assert.Equal(t, repoNames(filtered), []string{"terraform-aws-eks", "cloud-nuke"})
+	assert.Equal(t, len(stats.GetMultiple(RepoExcludedByFilter)), 2)
+}
+
+func TestRepoFiltersRequireEveryTopicAndLanguage(t *testing.T) {
+	filters, err := NewRepoFilters([]string{"aws", "Terraform"}, "hcl", false, false, "", "")
+	require.NoError(t, err)
+
+	filtered := filterRepos(makeFilterTestRepos(), filters, NewStatsTracker())
+
+	assert.Equal(t, repoNames(filtered), []string{"terraform-aws-eks"})
+}
+
+func TestRepoFiltersApplyNameRegexes(t *testing.T) {
+	filters, err := NewRepoFilters(nil, "", false, false, "thing$", "^old-")
+	require.NoError(t, err)
+
+	filtered := filterRepos(makeFilterTestRepos(), filters, NewStatsTracker())
+
+	assert.Equal(t, repoNames(filtered), []string{"forked-thing"})
+}
+
+func TestNewRepoFiltersRejectsInvalidRegex(t *testing.T) {
+	_, err := NewRepoFilters(nil, "", false, false, "(unclosed", "")
+
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package cmd
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+// githubAppTokenExpiryMargin is how long before an installation token actually expires that it's treated as expired,
+// so that a token handed to a long clone or push doesn't expire halfway through it
+const githubAppTokenExpiryMargin = 5 * time.Minute
+
+// githubAppTokenSource mints installation access tokens for a Github App. Installation tokens expire after an hour, so
+// it's wrapped in an oauth2.ReuseTokenSource, which hands out the same token until it's about to expire and then mints
+// a new one, keeping long runs authenticated
+type githubAppTokenSource struct {
+	appID          int64
+	installationID int64
+	privateKey     *rsa.PrivateKey
+	// baseURL, when set, is the Github API the installation token is requested from, rather than api.github.com
+	baseURL *url.URL
+}
+
+// newGithubAppTokenSource reads the Github App's private key from the PEM file at keyPath and returns a token source
+// that mints installation tokens for the installation as they're needed, from the Github Enterprise Server API at
+// baseURL, or from api.github.com if it's nil
+func newGithubAppTokenSource(appID, installationID int64, keyPath string, baseURL *url.URL) (oauth2.TokenSource, error) {
+	if appID == 0 || installationID == 0 || keyPath == "" {
+		return nil, errors.New("--github-app-id, --github-app-installation-id and --github-app-private-key-file must be passed together")
+	}
+
+	pemBytes, err := ioutil.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, err := parseGithubAppPrivateKey(pemBytes)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse the Github App private key in %s: %v", keyPath, err)
+	}
+
+	return oauth2.ReuseTokenSource(nil, &githubAppTokenSource{
+		appID:          appID,
+		installationID: installationID,
+		privateKey:     privateKey,
+		baseURL:        baseURL,
+	}), nil
+}
+
+// Token mints a new installation access token, authenticating as the Github App via a freshly signed JWT
+func (s *githubAppTokenSource) Token() (*oauth2.Token, error) {
+	jwt, err := signGithubAppJWT(s.appID, s.privateKey, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	appClient := github.NewClient(oauth2.NewClient(context.Background(), oauth2.StaticTokenSource(&oauth2.Token{AccessToken: jwt})))
+	if s.baseURL != nil {
+		appClient.BaseURL = s.baseURL
+	}
+
+	installationToken, _, err := appClient.Apps.CreateInstallationToken(context.Background(), s.installationID, nil)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":           err,
+			"App ID":          s.appID,
+			"Installation ID": s.installationID,
+		}).Debug("Error minting Github App installation token")
+		return nil, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"App ID":          s.appID,
+		"Installation ID": s.installationID,
+		"Expires at":      installationToken.GetExpiresAt(),
+	}).Debug("Minted Github App installation token")
+
+	return &oauth2.Token{
+		AccessToken: installationToken.GetToken(),
+		Expiry:      installationToken.GetExpiresAt().Add(-githubAppTokenExpiryMargin),
+	}, nil
+}
+
+// signGithubAppJWT returns a JWT, signed with the Github App's private key, that authenticates as the app for the next
+// few minutes. The issued-at time is backdated a minute to allow for clock drift between this machine and Github
+func signGithubAppJWT(appID int64, privateKey *rsa.PrivateKey, now time.Time) (string, error) {
+	header, err := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT"})
+	if err != nil {
+		return "", err
+	}
+
+	claims, err := json.Marshal(map[string]int64{
+		"iat": now.Add(-time.Minute).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": appID,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, privateKey, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+// parseGithubAppPrivateKey parses the PEM encoded RSA private key Github generates for an app, which is PKCS#1, also
+// accepting PKCS#8 in case the key was converted
+func parseGithubAppPrivateKey(pemBytes []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("no PEM encoded key found")
+	}
+
+	if privateKey, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return privateKey, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	privateKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("the key is not an RSA key")
+	}
+	return privateKey, nil
+}
@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/oauth2"
+)
+
+// makeTestGithubAppKey generates an RSA key small enough to keep the tests fast
+func makeTestGithubAppKey(t *testing.T) *rsa.PrivateKey {
+	privateKey, err := rsa.GenerateKey(rand.Reader, 1024)
+	require.NoError(t, err)
+	return privateKey
+}
+
+func TestSignGithubAppJWTIsVerifiableWithThePublicKey(t *testing.T) {
+	privateKey := makeTestGithubAppKey(t)
+	now := time.Unix(1600000000, 0)
+
+	jwt, err := signGithubAppJWT(42, privateKey, now)
+	require.NoError(t, err)
+
+	parts := strings.Split(jwt, ".")
+	require.Equal(t, len(parts), 3)
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	assert.NoError(t, rsa.VerifyPKCS1v15(&privateKey.PublicKey, crypto.SHA256, digest[:], signature))
+
+	claimsJSON, err := base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	var claims map[string]int64
+	require.NoError(t, json.Unmarshal(claimsJSON, &claims))
+	assert.Equal(t, claims, map[string]int64{"iat": now.Unix() - 60, "exp": now.Unix() + 540, "iss": 42})
+}
+
+func TestParseGithubAppPrivateKeyAcceptsPKCS1AndPKCS8(t *testing.T) {
+	privateKey := makeTestGithubAppKey(t)
+
+	pkcs1 := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privateKey)})
+	parsed, err := parseGithubAppPrivateKey(pkcs1)
+	require.NoError(t, err)
+	assert.Equal(t, parsed.D, privateKey.D)
+
+	pkcs8Bytes, err := x509.MarshalPKCS8PrivateKey(privateKey)
+	require.NoError(t, err)
+	parsed, err = parseGithubAppPrivateKey(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8Bytes}))
+	require.NoError(t, err)
+	assert.Equal(t, parsed.D, privateKey.D)
+
+	_, err = parseGithubAppPrivateKey([]byte("not a key"))
+	assert.Error(t, err)
+}
+
+func TestGithubAppTokenSourceMintsAndReusesInstallationTokens(t *testing.T) {
+	privateKey := makeTestGithubAppKey(t)
+	mints := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/installations/7/access_tokens", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPost)
+		assert.True(t, strings.HasPrefix(r.Header.Get("Authorization"), "Bearer "), "The app must authenticate with its JWT")
+		mints++
+		fmt.Fprintf(w, `{"token": "ghs_%d", "expires_at": %q}`, mints, time.Now().Add(time.Hour).Format(time.RFC3339))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+
+	tokenSource := oauth2.ReuseTokenSource(nil, &githubAppTokenSource{appID: 42, installationID: 7, privateKey: privateKey, baseURL: baseURL})
+
+	first, err := tokenSource.Token()
+	require.NoError(t, err)
+	second, err := tokenSource.Token()
+	require.NoError(t, err)
+
+	assert.Equal(t, first.AccessToken, "ghs_1")
+	assert.Equal(t, second.AccessToken, "ghs_1")
+	assert.Equal(t, mints, 1)
+	assert.True(t, first.Expiry.Before(time.Now().Add(time.Hour-githubAppTokenExpiryMargin+time.Minute)))
+}
+
+func TestNewGithubAppTokenSourceRequiresEveryFlag(t *testing.T) {
+	_, err := newGithubAppTokenSource(42, 0, "key.pem", nil)
+	assert.Error(t, err)
+}
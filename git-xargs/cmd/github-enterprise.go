@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+)
+
+// githubEnterpriseAPIPath is the path the REST API of a Github Enterprise Server instance is served under
+const githubEnterpriseAPIPath = "/api/v3/"
+
+// githubDotComWebURL is the root of the repos on github.com
+const githubDotComWebURL = "https://github.com/"
+
+// githubBaseURL returns the base URL of the Github Enterprise Server instance passed via --github-base-url, or failing
+// that via the GITHUB_BASE_URL Env var. It's empty when repos are hosted on github.com
+func githubBaseURL() string {
+	if GithubBaseURL != "" {
+		return GithubBaseURL
+	}
+	return os.Getenv("GITHUB_BASE_URL")
+}
+
+// githubEnterpriseAPIURL returns the root of the REST API of the Github Enterprise Server instance at githubBaseURL, or
+// nil if repos are hosted on github.com. Either the instance's own URL, e.g., https://github.example.com, or the URL of
+// its API, https://github.example.com/api/v3/, may be passed
+func githubEnterpriseAPIURL() (*url.URL, error) {
+	baseURL := githubBaseURL()
+	if baseURL == "" {
+		return nil, nil
+	}
+
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("the Github base URL %q must include a scheme and host, e.g., https://github.example.com", baseURL)
+	}
+
+	parsed.Path = strings.TrimSuffix(strings.TrimSuffix(parsed.Path, "/"), strings.TrimSuffix(githubEnterpriseAPIPath, "/")) + githubEnterpriseAPIPath
+	return parsed, nil
+}
+
+// githubWebURL returns the root under which repos are browsed and cloned: github.com, or the Github Enterprise Server
+// instance at githubBaseURL
+func githubWebURL() (*url.URL, error) {
+	apiURL, err := githubEnterpriseAPIURL()
+	if err != nil {
+		return nil, err
+	}
+	if apiURL == nil {
+		return url.Parse(githubDotComWebURL)
+	}
+
+	webURL := *apiURL
+	webURL.Path = strings.TrimSuffix(webURL.Path, githubEnterpriseAPIPath) + "/"
+	return &webURL, nil
+}
+
+// repoCloneURL returns the URL to clone the repo from over HTTPS. That's the clone URL the API reported for the repo,
+// which is on the instance's own host for Github Enterprise Server. Repos the API didn't report one for, such as those
+// named in a run report rather than looked up, are cloned from under githubWebURL
+func repoCloneURL(repo *github.Repository) string {
+	if repo.GetCloneURL() != "" {
+		return repo.GetCloneURL()
+	}
+
+	webURL, err := githubWebURL()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s%s/%s.git", webURL.String(), repo.GetOwner().GetLogin(), repo.GetName())
+}
+
+// repoSSHURL returns the URL to clone the repo from over SSH. As with repoCloneURL, the URL reported by the API is
+// preferred, falling back to the host of githubWebURL
+func repoSSHURL(repo *github.Repository) string {
+	if repo.GetSSHURL() != "" {
+		return repo.GetSSHURL()
+	}
+
+	webURL, err := githubWebURL()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("git@%s:%s/%s.git", webURL.Hostname(), repo.GetOwner().GetLogin(), repo.GetName())
+}
@@ -0,0 +1,104 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setGithubBaseURL sets --github-base-url and GITHUB_BASE_URL, returning a function that restores both
+func setGithubBaseURL(flag, env string) func() {
+	originalFlag, originalEnv := GithubBaseURL, os.Getenv("GITHUB_BASE_URL")
+	GithubBaseURL = flag
+	os.Setenv("GITHUB_BASE_URL", env)
+	return func() {
+		GithubBaseURL = originalFlag
+		os.Setenv("GITHUB_BASE_URL", originalEnv)
+	}
+}
+
+func TestGithubEnterpriseAPIURLIsNilForGithubDotCom(t *testing.T) {
+	defer setGithubBaseURL("", "")()
+
+	apiURL, err := githubEnterpriseAPIURL()
+	require.NoError(t, err)
+	assert.Nil(t, apiURL)
+
+	webURL, err := githubWebURL()
+	require.NoError(t, err)
+	assert.Equal(t, webURL.String(), "https://github.com/")
+}
+
+func TestGithubEnterpriseAPIURLAcceptsInstanceOrAPIURL(t *testing.T) {
+	for _, baseURL := range []string{
+		"https://github.example.com",
+		"https://github.example.com/",
+		"https://github.example.com/api/v3",
+		"https://github.example.com/api/v3/",
+	} {
+		restore := setGithubBaseURL(baseURL, "")
+
+		apiURL, err := githubEnterpriseAPIURL()
+		require.NoError(t, err, baseURL)
+		assert.Equal(t, apiURL.String(), "https://github.example.com/api/v3/", baseURL)
+
+		webURL, err := githubWebURL()
+		require.NoError(t, err, baseURL)
+		assert.Equal(t, webURL.String(), "https://github.example.com/", baseURL)
+
+		restore()
+	}
+}
+
+func TestGithubEnterpriseAPIURLFallsBackToEnv(t *testing.T) {
+	defer setGithubBaseURL("", "https://ghe.example.com/git")()
+
+	apiURL, err := githubEnterpriseAPIURL()
+	require.NoError(t, err)
+	assert.Equal(t, apiURL.String(), "https://ghe.example.com/git/api/v3/")
+
+	// The flag takes precedence over the env var
+	GithubBaseURL = "https://github.example.com"
+	apiURL, err = githubEnterpriseAPIURL()
+	require.NoError(t, err)
+	assert.Equal(t, apiURL.String(), "https://github.example.com/api/v3/")
+}
+
+func TestGithubEnterpriseAPIURLRejectsURLWithoutHost(t *testing.T) {
+	defer setGithubBaseURL("github.example.com", "")()
+
+	_, err := githubEnterpriseAPIURL()
+	assert.Error(t, err)
+}
+
+func TestRepoCloneURLsPreferThoseReportedByTheAPI(t *testing.T) {
+	defer setGithubBaseURL("https://github.example.com", "")()
+
+	repo := &github.Repository{
+		Name:     github.String("cloud-nuke"),
+		Owner:    &github.User{Login: github.String("gruntwork-io")},
+		CloneURL: github.String("https://ghe-clone.example.com/gruntwork-io/cloud-nuke.git"),
+		SSHURL:   github.String("git@ghe-clone.example.com:gruntwork-io/cloud-nuke.git"),
+	}
+	assert.Equal(t, repoCloneURL(repo), "https://ghe-clone.example.com/gruntwork-io/cloud-nuke.git")
+	assert.Equal(t, repoSSHURL(repo), "git@ghe-clone.example.com:gruntwork-io/cloud-nuke.git")
+}
+
+func TestRepoCloneURLsAreDerivedFromTheInstance(t *testing.T) {
+	repo := &github.Repository{
+		Name:  github.String("cloud-nuke"),
+		Owner: &github.User{Login: github.String("gruntwork-io")},
+	}
+
+	restore := setGithubBaseURL("", "")
+	assert.Equal(t, repoCloneURL(repo), "https://github.com/gruntwork-io/cloud-nuke.git")
+	assert.Equal(t, repoSSHURL(repo), "git@github.com:gruntwork-io/cloud-nuke.git")
+	restore()
+
+	defer setGithubBaseURL("https://github.example.com/api/v3/", "")()
+	assert.Equal(t, repoCloneURL(repo), "https://github.example.com/gruntwork-io/cloud-nuke.git")
+	assert.Equal(t, repoSSHURL(repo), "git@github.example.com:gruntwork-io/cloud-nuke.git")
+}
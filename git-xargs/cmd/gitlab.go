@@ -0,0 +1,495 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// DefaultGitlabURL is the GitLab instance repos are looked up on unless --gitlab-url is passed
+	DefaultGitlabURL = "https://gitlab.com"
+	// gitlabGitUsername is the username presented alongside a GitLab access token when cloning or pushing over HTTPS
+	gitlabGitUsername = "oauth2"
+	// gitlabDraftPrefix marks a merge request as a draft, which GitLab does via its title
+	gitlabDraftPrefix = "Draft: "
+)
+
+// GitlabAPIError is returned when a GitLab API call doesn't succeed
+type GitlabAPIError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	Message    string
+}
+
+func (err GitlabAPIError) Error() string {
+	return fmt.Sprintf("%s %s: %d %s", err.Method, err.URL, err.StatusCode, err.Message)
+}
+
+// isGitlabNotFound returns true if the error is a GitLab API call that 404'd
+func isGitlabNotFound(err error) bool {
+	var apiErr GitlabAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// gitlabProject is the subset of a GitLab project that git-xargs uses
+type gitlabProject struct {
+	ID                int64    `json:"id"`
+	Path              string   `json:"path"`
+	PathWithNamespace string   `json:"path_with_namespace"`
+	Description       string   `json:"description"`
+	DefaultBranch     string   `json:"default_branch"`
+	Visibility        string   `json:"visibility"`
+	HTTPURLToRepo     string   `json:"http_url_to_repo"`
+	SSHURLToRepo      string   `json:"ssh_url_to_repo"`
+	WebURL            string   `json:"web_url"`
+	Archived          bool     `json:"archived"`
+	Topics            []string `json:"topics"`
+	// TagList is what GitLab versions before 14.0 call topics
+	TagList   []string `json:"tag_list"`
+	Namespace struct {
+		FullPath string `json:"full_path"`
+	} `json:"namespace"`
+	ForkedFromProject *struct {
+		ID int64 `json:"id"`
+	} `json:"forked_from_project"`
+}
+
+// toRepository converts the project to the go-github type every repo is represented by. The full path of the project's
+// group, which may be nested, stands in for the owner
+func (p gitlabProject) toRepository() *github.Repository {
+	topics := p.Topics
+	if len(topics) == 0 {
+		topics = p.TagList
+	}
+
+	return &github.Repository{
+		ID:            github.Int64(p.ID),
+		Name:          github.String(p.Path),
+		FullName:      github.String(p.PathWithNamespace),
+		Description:   github.String(p.Description),
+		Owner:         &github.User{Login: github.String(p.Namespace.FullPath)},
+		DefaultBranch: github.String(p.DefaultBranch),
+		Private:       github.Bool(p.Visibility != "public"),
+		CloneURL:      github.String(p.HTTPURLToRepo),
+		SSHURL:        github.String(p.SSHURLToRepo),
+		HTMLURL:       github.String(p.WebURL),
+		Archived:      github.Bool(p.Archived),
+		Fork:          github.Bool(p.ForkedFromProject != nil),
+		Topics:        topics,
+	}
+}
+
+// gitlabUser is the subset of a GitLab user that git-xargs uses
+type gitlabUser struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// gitlabMergeRequest is the subset of a GitLab merge request that git-xargs uses
+type gitlabMergeRequest struct {
+	IID          int          `json:"iid"`
+	Title        string       `json:"title"`
+	Description  string       `json:"description"`
+	State        string       `json:"state"`
+	WebURL       string       `json:"web_url"`
+	SourceBranch string       `json:"source_branch"`
+	TargetBranch string       `json:"target_branch"`
+	Draft        bool         `json:"draft"`
+	Assignees    []gitlabUser `json:"assignees"`
+	Reviewers    []gitlabUser `json:"reviewers"`
+	// WorkInProgress is what GitLab versions before 14.0 call a draft
+	WorkInProgress bool `json:"work_in_progress"`
+}
+
+// toPullRequest converts the merge request to the go-github type every pull request is represented by. The merge
+// request's IID, which is numbered per project like a Github pull request number, stands in for the number
+func (mr gitlabMergeRequest) toPullRequest() *github.PullRequest {
+	state := mr.State
+	if state == "opened" {
+		state = "open"
+	}
+
+	pr := &github.PullRequest{
+		Number:  github.Int(mr.IID),
+		Title:   github.String(mr.Title),
+		Body:    github.String(mr.Description),
+		State:   github.String(state),
+		HTMLURL: github.String(mr.WebURL),
+		Draft:   github.Bool(mr.Draft || mr.WorkInProgress),
+		Head:    &github.PullRequestBranch{Ref: github.String(mr.SourceBranch)},
+		Base:    &github.PullRequestBranch{Ref: github.String(mr.TargetBranch)},
+	}
+	for _, assignee := range mr.Assignees {
+		pr.Assignees = append(pr.Assignees, &github.User{ID: github.Int64(assignee.ID), Login: github.String(assignee.Username)})
+	}
+	for _, reviewer := range mr.Reviewers {
+		pr.RequestedReviewers = append(pr.RequestedReviewers, &github.User{ID: github.Int64(reviewer.ID), Login: github.String(reviewer.Username)})
+	}
+	return pr
+}
+
+// gitlabHost is the RepoHost for repos on GitLab, making its calls directly against the GitLab v4 REST API
+type gitlabHost struct {
+	client *http.Client
+	// apiURL is the root of the GitLab API, e.g., https://gitlab.com/api/v4/
+	apiURL string
+	token  string
+}
+
+// newGitlabHost returns a host that makes calls to the GitLab instance at baseURL, e.g., https://gitlab.example.com,
+// authenticating with the supplied access token
+func newGitlabHost(baseURL, token string, client *http.Client) (*gitlabHost, error) {
+	parsed, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.Scheme == "" || parsed.Host == "" {
+		return nil, fmt.Errorf("the GitLab URL %q must include a scheme and host, e.g., https://gitlab.example.com", baseURL)
+	}
+
+	return &gitlabHost{
+		client: client,
+		apiURL: strings.TrimSuffix(parsed.String(), "/") + "/api/v4/",
+		token:  token,
+	}, nil
+}
+
+// ConfigureGitlabHost creates a GitLab host using the user-supplied GITLAB_TOKEN, for the instance at --gitlab-url. The
+// same token is used for cloning and pushing over HTTPS for the rest of the run. Calls wait out rate limits and retry
+// server errors, as calls to Github do
+func ConfigureGitlabHost(ctx context.Context) *gitlabHost {
+	token := os.Getenv("GITLAB_TOKEN")
+	if token == "" {
+		log.Fatal("You must set a GitLab personal, group or project access token with the api scope via the Env var GITLAB_TOKEN when passing --repo-host gitlab")
+	}
+
+	activeRepoHostCredentials = &RepoHostCredentials{
+		TokenSource: oauth2.StaticTokenSource(&oauth2.Token{AccessToken: token}),
+		GitUsername: gitlabGitUsername,
+	}
+
+	client := &http.Client{
+		Transport: newRateLimitTransport(ctx, http.DefaultTransport, MaxAPIRetries, MaxRateLimitWait, repoHostAPICallStats),
+	}
+
+	host, err := newGitlabHost(GitlabURL, token, client)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":      err,
+			"GitLab URL": GitlabURL,
+		}).Fatal("Invalid --gitlab-url")
+	}
+
+	log.WithFields(logrus.Fields{
+		"GitLab URL": GitlabURL,
+	}).Debug("GitLab client instantiated!")
+
+	return host
+}
+
+// gitlabProjectID returns the URL-encoded full path of the repo, which the GitLab API accepts wherever a project ID is expected
+func gitlabProjectID(repo *github.Repository) string {
+	fullName := repo.GetFullName()
+	if fullName == "" {
+		fullName = repo.GetOwner().GetLogin() + "/" + repo.GetName()
+	}
+	return url.PathEscape(fullName)
+}
+
+// do makes a call to the GitLab API at the supplied path, relative to the API root, sending body as JSON if it's non-nil
+// and decoding the JSON response into out if it's non-nil. Responses other than 2xx are returned as a GitlabAPIError
+func (h *gitlabHost) do(method, path string, query url.Values, body, out interface{}) (*http.Response, error) {
+	callURL := h.apiURL + path
+	if len(query) > 0 {
+		callURL += "?" + query.Encode()
+	}
+
+	var req *http.Request
+	var err error
+	if body != nil {
+		payload, marshalErr := json.Marshal(body)
+		if marshalErr != nil {
+			return nil, marshalErr
+		}
+		// A bytes.Reader body can be replayed, so that the call can be retried if it's rate limited
+		req, err = http.NewRequest(method, callURL, bytes.NewReader(payload))
+		if err == nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+	} else {
+		req, err = http.NewRequest(method, callURL, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", h.token)
+
+	resp, err := h.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	respBody, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return resp, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp, GitlabAPIError{
+			Method:     method,
+			URL:        req.URL.String(),
+			StatusCode: resp.StatusCode,
+			Message:    strings.TrimSpace(string(respBody)),
+		}
+	}
+
+	if out != nil {
+		if err := json.Unmarshal(respBody, out); err != nil {
+			return resp, err
+		}
+	}
+	return resp, nil
+}
+
+// listProjects pages through every project listed at the supplied path
+func (h *gitlabHost) listProjects(path string, query url.Values) ([]*github.Repository, error) {
+	var allRepos []*github.Repository
+
+	query.Set("per_page", "100")
+	for page := "1"; page != ""; {
+		query.Set("page", page)
+
+		var projects []gitlabProject
+		resp, err := h.do(http.MethodGet, path, query, nil, &projects)
+		if err != nil {
+			return allRepos, err
+		}
+
+		for _, project := range projects {
+			allRepos = append(allRepos, project.toRepository())
+		}
+		page = resp.Header.Get("X-Next-Page")
+	}
+
+	return allRepos, nil
+}
+
+// GetRepo looks up the project at owner/name, where the owner is the full path of its group, e.g., platform/terraform
+func (h *gitlabHost) GetRepo(owner, name string) (*github.Repository, error) {
+	var project gitlabProject
+	if _, err := h.do(http.MethodGet, "projects/"+url.PathEscape(owner+"/"+name), nil, nil, &project); err != nil {
+		if isGitlabNotFound(err) {
+			return nil, fmt.Errorf("%w: %v", ErrRepoNotFound, err)
+		}
+		return nil, err
+	}
+	return project.toRepository(), nil
+}
+
+// ListOrgRepos returns every project of the group, including those of its subgroups. Projects shared with the group by
+// other groups are left out, as they belong to those groups
+func (h *gitlabHost) ListOrgRepos(group string) ([]*github.Repository, error) {
+	query := url.Values{}
+	query.Set("include_subgroups", "true")
+	query.Set("with_shared", "false")
+	return h.listProjects("groups/"+url.PathEscape(group)+"/projects", query)
+}
+
+func (h *gitlabHost) ListUserRepos(user string) ([]*github.Repository, error) {
+	return h.listProjects("users/"+url.PathEscape(user)+"/projects", url.Values{})
+}
+
+// SearchRepos returns every project visible to the token whose name, path or namespace contains the query. GitLab's
+// project search is a plain substring match, so Github's search qualifiers, such as org:, aren't understood
+func (h *gitlabHost) SearchRepos(query string) ([]*github.Repository, error) {
+	values := url.Values{}
+	values.Set("search", query)
+	values.Set("search_namespaces", "true")
+	return h.listProjects("projects", values)
+}
+
+func (h *gitlabHost) BranchExists(repo *github.Repository, branch string) (bool, error) {
+	_, err := h.do(http.MethodGet, "projects/"+gitlabProjectID(repo)+"/repository/branches/"+url.PathEscape(branch), nil, nil, nil)
+	if err != nil {
+		if isGitlabNotFound(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// CreatePullRequest opens a merge request. GitLab has no separate draft flag when opening one, so draft merge requests
+// are opened by prefixing their title
+func (h *gitlabHost) CreatePullRequest(repo *github.Repository, newPR *github.NewPullRequest) (*github.PullRequest, error) {
+	title := newPR.GetTitle()
+	if newPR.GetDraft() {
+		title = gitlabDraftPrefix + title
+	}
+
+	create := map[string]string{
+		"source_branch": strings.TrimPrefix(newPR.GetHead(), "refs/heads/"),
+		"target_branch": newPR.GetBase(),
+		"title":         title,
+		"description":   newPR.GetBody(),
+	}
+
+	var mr gitlabMergeRequest
+	if _, err := h.do(http.MethodPost, "projects/"+gitlabProjectID(repo)+"/merge_requests", nil, create, &mr); err != nil {
+		return nil, err
+	}
+	return mr.toPullRequest(), nil
+}
+
+func (h *gitlabHost) FindOpenPullRequest(repo *github.Repository, head, baseBranch string) (*github.PullRequest, error) {
+	query := url.Values{}
+	query.Set("state", "opened")
+	query.Set("source_branch", strings.TrimPrefix(head, "refs/heads/"))
+	query.Set("target_branch", baseBranch)
+
+	var mrs []gitlabMergeRequest
+	if _, err := h.do(http.MethodGet, "projects/"+gitlabProjectID(repo)+"/merge_requests", query, nil, &mrs); err != nil {
+		return nil, err
+	}
+
+	if len(mrs) == 0 {
+		return nil, nil
+	}
+	return mrs[0].toPullRequest(), nil
+}
+
+// EditPullRequest replaces the title and description of the merge request, keeping it a draft if it was one
+func (h *gitlabHost) EditPullRequest(repo *github.Repository, pr *github.PullRequest, title, body string) (*github.PullRequest, error) {
+	if pr.GetDraft() {
+		title = gitlabDraftPrefix + title
+	}
+
+	return h.updateMergeRequest(repo, pr, map[string]interface{}{
+		"title":       title,
+		"description": body,
+	})
+}
+
+// updateMergeRequest applies the supplied changes to the merge request
+func (h *gitlabHost) updateMergeRequest(repo *github.Repository, pr *github.PullRequest, changes map[string]interface{}) (*github.PullRequest, error) {
+	var mr gitlabMergeRequest
+	path := fmt.Sprintf("projects/%s/merge_requests/%d", gitlabProjectID(repo), pr.GetNumber())
+	if _, err := h.do(http.MethodPut, path, nil, changes, &mr); err != nil {
+		return nil, err
+	}
+	return mr.toPullRequest(), nil
+}
+
+// ApplyPullRequestMetadata adds the reviewers, labels, assignees and milestone passed for this run to the merge request,
+// just as is done for Github pull requests. GitLab has no team reviewers, so passing --team-reviewers is tracked as a
+// failure to request reviewers. Reviewers and assignees already on the merge request are kept
+func (h *gitlabHost) ApplyPullRequestMetadata(repo *github.Repository, pr *github.PullRequest, stats *RunStats) {
+	if len(PullRequestReviewers) > 0 || len(PullRequestTeamReviewers) > 0 {
+		if len(PullRequestTeamReviewers) > 0 {
+			trackPullRequestMetadataErr(PullRequestReviewersErr, repo, pr, errors.New("GitLab merge requests can't have team reviewers"), stats)
+		}
+		if len(PullRequestReviewers) > 0 {
+			if err := h.addMergeRequestUsers(repo, pr, "reviewer_ids", pr.RequestedReviewers, PullRequestReviewers); err != nil {
+				trackPullRequestMetadataErr(PullRequestReviewersErr, repo, pr, err, stats)
+			}
+		}
+	}
+
+	if len(PullRequestLabels) > 0 {
+		// Labels that don't exist in the project yet are created by GitLab
+		if _, err := h.updateMergeRequest(repo, pr, map[string]interface{}{"add_labels": strings.Join(PullRequestLabels, ",")}); err != nil {
+			trackPullRequestMetadataErr(PullRequestLabelsErr, repo, pr, err, stats)
+		}
+	}
+
+	if len(PullRequestAssignees) > 0 {
+		if err := h.addMergeRequestUsers(repo, pr, "assignee_ids", pr.Assignees, PullRequestAssignees); err != nil {
+			trackPullRequestMetadataErr(PullRequestAssigneesErr, repo, pr, err, stats)
+		}
+	}
+
+	if PullRequestMilestone != "" {
+		milestoneID, lookupErr := h.lookupMilestoneID(repo, PullRequestMilestone)
+		if lookupErr != nil {
+			trackPullRequestMetadataErr(PullRequestMilestoneErr, repo, pr, lookupErr, stats)
+			return
+		}
+
+		if _, err := h.updateMergeRequest(repo, pr, map[string]interface{}{"milestone_id": milestoneID}); err != nil {
+			trackPullRequestMetadataErr(PullRequestMilestoneErr, repo, pr, err, stats)
+		}
+	}
+}
+
+// addMergeRequestUsers sets the user IDs field of the merge request, e.g., its reviewer_ids, to the users already on it
+// plus the users with the supplied usernames
+func (h *gitlabHost) addMergeRequestUsers(repo *github.Repository, pr *github.PullRequest, field string, existing []*github.User, usernames []string) error {
+	var ids []int64
+	for _, user := range existing {
+		ids = append(ids, user.GetID())
+	}
+
+	for _, username := range usernames {
+		id, err := h.lookupUserID(username)
+		if err != nil {
+			return err
+		}
+		ids = append(ids, id)
+	}
+
+	_, err := h.updateMergeRequest(repo, pr, map[string]interface{}{field: ids})
+	return err
+}
+
+// lookupUserID returns the ID of the GitLab user with the supplied username, which is what merge requests are updated with
+func (h *gitlabHost) lookupUserID(username string) (int64, error) {
+	query := url.Values{}
+	query.Set("username", username)
+
+	var users []gitlabUser
+	if _, err := h.do(http.MethodGet, "users", query, nil, &users); err != nil {
+		return 0, err
+	}
+	if len(users) == 0 {
+		return 0, fmt.Errorf("no GitLab user has the username %q", username)
+	}
+	return users[0].ID, nil
+}
+
+// lookupMilestoneID returns the ID of the project's active milestone with the supplied title. As with Github, a plain
+// number is taken to be the number of the milestone within the project, which GitLab calls its IID
+func (h *gitlabHost) lookupMilestoneID(repo *github.Repository, milestone string) (int64, error) {
+	query := url.Values{}
+	if _, err := strconv.Atoi(milestone); err == nil {
+		query.Set("iids[]", milestone)
+	} else {
+		query.Set("title", milestone)
+		query.Set("state", "active")
+	}
+
+	var milestones []struct {
+		ID int64 `json:"id"`
+	}
+	if _, err := h.do(http.MethodGet, "projects/"+gitlabProjectID(repo)+"/milestones", query, nil, &milestones); err != nil {
+		return 0, err
+	}
+	if len(milestones) == 0 {
+		return 0, fmt.Errorf("repo %s has no active milestone %q", repo.GetFullName(), milestone)
+	}
+	return milestones[0].ID, nil
+}
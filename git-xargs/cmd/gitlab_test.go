@@ -0,0 +1,236 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestGitlabHost returns a GitLab host whose API calls are served by the supplied handlers, keyed by method and
+// escaped path, e.g., "GET /api/v4/projects/platform%2Fterraform", along with a function to shut the server down.
+// Project IDs are URL-encoded paths, so calls are matched on the escaped path rather than routed by a ServeMux
+func newTestGitlabHost(t *testing.T, handlers map[string]http.HandlerFunc) (*gitlabHost, func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Header.Get("PRIVATE-TOKEN"), "gitlab-token")
+
+		handler, ok := handlers[r.Method+" "+r.URL.EscapedPath()]
+		if !ok {
+			t.Errorf("unexpected GitLab API call: %s %s", r.Method, r.URL.EscapedPath())
+			http.NotFound(w, r)
+			return
+		}
+		handler(w, r)
+	}))
+
+	host, err := newGitlabHost(server.URL, "gitlab-token", server.Client())
+	require.NoError(t, err)
+
+	return host, server.Close
+}
+
+const testGitlabProject = `{
+	"id": 42,
+	"path": "terraform",
+	"path_with_namespace": "platform/infra/terraform",
+	"default_branch": "main",
+	"visibility": "internal",
+	"http_url_to_repo": "https://gitlab.example.com/platform/infra/terraform.git",
+	"ssh_url_to_repo": "git@gitlab.example.com:platform/infra/terraform.git",
+	"web_url": "https://gitlab.example.com/platform/infra/terraform",
+	"archived": true,
+	"topics": ["terraform"],
+	"namespace": {"full_path": "platform/infra"},
+	"forked_from_project": {"id": 7}
+}`
+
+func makeTestGitlabRepo() *github.Repository {
+	return &github.Repository{
+		Name:     github.String("terraform"),
+		FullName: github.String("platform/infra/terraform"),
+		Owner:    &github.User{Login: github.String("platform/infra")},
+	}
+}
+
+func TestGitlabGetRepoConvertsProject(t *testing.T) {
+	host, closeServer := newTestGitlabHost(t, map[string]http.HandlerFunc{
+		"GET /api/v4/projects/platform%2Finfra%2Fterraform": func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, testGitlabProject)
+		},
+	})
+	defer closeServer()
+
+	repo, err := host.GetRepo("platform/infra", "terraform")
+	require.NoError(t, err)
+
+	assert.Equal(t, repo.GetName(), "terraform")
+	assert.Equal(t, repo.GetFullName(), "platform/infra/terraform")
+	assert.Equal(t, repo.GetOwner().GetLogin(), "platform/infra")
+	assert.Equal(t, repo.GetDefaultBranch(), "main")
+	assert.Equal(t, repo.GetCloneURL(), "https://gitlab.example.com/platform/infra/terraform.git")
+	assert.Equal(t, repo.GetSSHURL(), "git@gitlab.example.com:platform/infra/terraform.git")
+	assert.Equal(t, repo.Topics, []string{"terraform"})
+	assert.True(t, repo.GetArchived())
+	assert.True(t, repo.GetFork())
+	assert.True(t, repo.GetPrivate())
+}
+
+func TestGitlabGetRepoReturnsErrRepoNotFound(t *testing.T) {
+	host, closeServer := newTestGitlabHost(t, map[string]http.HandlerFunc{
+		"GET /api/v4/projects/platform%2Fmissing": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message": "404 Project Not Found"}`, http.StatusNotFound)
+		},
+	})
+	defer closeServer()
+
+	_, err := host.GetRepo("platform", "missing")
+	assert.True(t, errors.Is(err, ErrRepoNotFound))
+}
+
+func TestGitlabListOrgReposPagesThroughSubgroups(t *testing.T) {
+	host, closeServer := newTestGitlabHost(t, map[string]http.HandlerFunc{
+		"GET /api/v4/groups/platform/projects": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.URL.Query().Get("include_subgroups"), "true")
+
+			if r.URL.Query().Get("page") == "1" {
+				w.Header().Set("X-Next-Page", "2")
+				fmt.Fprint(w, `[{"path": "modules", "path_with_namespace": "platform/modules"}]`)
+				return
+			}
+			fmt.Fprint(w, `[`+testGitlabProject+`]`)
+		},
+	})
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	repos, err := getReposByOrg(host, "platform", stats)
+	require.NoError(t, err)
+
+	require.Equal(t, len(repos), 2)
+	assert.Equal(t, repos[0].GetFullName(), "platform/modules")
+	assert.Equal(t, repos[1].GetFullName(), "platform/infra/terraform")
+	assert.Equal(t, len(stats.GetMultiple(FetchedViaGithubAPI)), 2)
+}
+
+func TestGitlabLookupTargetBranchTracksMissingBranch(t *testing.T) {
+	host, closeServer := newTestGitlabHost(t, map[string]http.HandlerFunc{
+		"GET /api/v4/projects/platform%2Finfra%2Fterraform/repository/branches/git-xargs": func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, `{"message": "404 Branch Not Found"}`, http.StatusNotFound)
+		},
+	})
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	exists, err := lookupTargetBranch(host, makeTestGitlabRepo(), stats)
+
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, len(stats.GetMultiple(TargetBranchNotFound)), 1)
+}
+
+func TestGitlabOpenPullRequestOpensDraftMergeRequestWithMetadata(t *testing.T) {
+	defer setPullRequestMetadataFlags([]string{"alice"}, []string{"platform"}, []string{"chore", "deps"}, []string{"bob"}, true, "Q3 cleanup")()
+
+	var created map[string]string
+	updates := map[string]interface{}{}
+
+	host, closeServer := newTestGitlabHost(t, map[string]http.HandlerFunc{
+		"POST /api/v4/projects/platform%2Finfra%2Fterraform/merge_requests": func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+			fmt.Fprint(w, `{"iid": 3, "web_url": "https://gitlab.example.com/platform/infra/terraform/-/merge_requests/3", "draft": true, "reviewers": [{"id": 5, "username": "carol"}]}`)
+		},
+		"PUT /api/v4/projects/platform%2Finfra%2Fterraform/merge_requests/3": func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&updates))
+			fmt.Fprint(w, `{"iid": 3}`)
+		},
+		"GET /api/v4/users": func(w http.ResponseWriter, r *http.Request) {
+			ids := map[string]int{"alice": 11, "bob": 12}
+			fmt.Fprintf(w, `[{"id": %d}]`, ids[r.URL.Query().Get("username")])
+		},
+		"GET /api/v4/projects/platform%2Finfra%2Fterraform/milestones": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.URL.Query().Get("title"), "Q3 cleanup")
+			fmt.Fprint(w, `[{"id": 99}]`)
+		},
+	})
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	err := openPullRequest(false, host, makeTestGitlabRepo(), "refs/heads/git-xargs", "main", "title", "body", stats)
+	require.NoError(t, err)
+
+	assert.Equal(t, created["source_branch"], "git-xargs")
+	assert.Equal(t, created["target_branch"], "main")
+	assert.Equal(t, created["title"], "Draft: title")
+	assert.Equal(t, stats.GetPullRequests()["terraform"], "https://gitlab.example.com/platform/infra/terraform/-/merge_requests/3")
+
+	// Every piece of metadata is its own update, so the last update of each field is what was sent
+	assert.Equal(t, updates["reviewer_ids"], []interface{}{float64(5), float64(11)})
+	assert.Equal(t, updates["add_labels"], "chore,deps")
+	assert.Equal(t, updates["assignee_ids"], []interface{}{float64(12)})
+	assert.Equal(t, updates["milestone_id"], float64(99))
+
+	// GitLab has no team reviewers, which is tracked without failing the merge request that was opened
+	assert.Equal(t, len(stats.GetMultiple(PullRequestReviewersErr)), 1)
+	assert.Empty(t, stats.GetMultiple(PullRequestLabelsErr))
+	assert.Empty(t, stats.GetMultiple(PullRequestAssigneesErr))
+	assert.Empty(t, stats.GetMultiple(PullRequestMilestoneErr))
+}
+
+func TestGitlabOpenPullRequestUpdatesExistingDraftMergeRequest(t *testing.T) {
+	defer func(original bool) { UpdateExisting = original }(UpdateExisting)
+	UpdateExisting = true
+
+	var edited map[string]interface{}
+
+	host, closeServer := newTestGitlabHost(t, map[string]http.HandlerFunc{
+		"GET /api/v4/projects/platform%2Finfra%2Fterraform/merge_requests": func(w http.ResponseWriter, r *http.Request) {
+			assert.Equal(t, r.URL.Query().Get("state"), "opened")
+			assert.Equal(t, r.URL.Query().Get("source_branch"), "git-xargs")
+			fmt.Fprint(w, `[{"iid": 3, "draft": true, "web_url": "https://gitlab.example.com/platform/infra/terraform/-/merge_requests/3"}]`)
+		},
+		"PUT /api/v4/projects/platform%2Finfra%2Fterraform/merge_requests/3": func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&edited))
+			fmt.Fprint(w, `{"iid": 3, "web_url": "https://gitlab.example.com/platform/infra/terraform/-/merge_requests/3"}`)
+		},
+	})
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	err := openPullRequest(false, host, makeTestGitlabRepo(), "refs/heads/git-xargs", "main", "title", "body", stats)
+	require.NoError(t, err)
+
+	assert.Equal(t, edited["title"], "Draft: title")
+	assert.Equal(t, edited["description"], "body")
+	assert.Equal(t, len(stats.GetMultiple(PullRequestUpdated)), 1)
+}
+
+func TestParseRepoLineAcceptsGitlabSubgroupsOnlyForGitlab(t *testing.T) {
+	defer func(original string) { RepoHostName = original }(RepoHostName)
+
+	RepoHostName = RepoHostGithub
+	_, ok := parseRepoLine("platform/infra/terraform")
+	assert.False(t, ok)
+
+	RepoHostName = RepoHostGitlab
+	for _, line := range []string{
+		"platform/infra/terraform",
+		"https://gitlab.example.com/platform/infra/terraform.git",
+		"git@gitlab.example.com:platform/infra/terraform.git",
+	} {
+		repo, ok := parseRepoLine(line)
+		require.True(t, ok, line)
+		assert.Equal(t, repo.Organization, "platform/infra", line)
+		assert.Equal(t, repo.Name, "terraform", line)
+	}
+
+	// Repos that aren't nested are parsed as they are for Github
+	repo, ok := parseRepoLine("platform/terraform")
+	require.True(t, ok)
+	assert.Equal(t, repo.Organization, "platform")
+}
@@ -2,18 +2,114 @@ package cmd
 
 import (
 	"bufio"
+	"fmt"
+	"io"
 	"os"
 	"regexp"
 	"strings"
 
+	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
 )
 
-// This utility function accepts a path to the flatfile in which the user has defined their explicitly allowed repos
+// StdinRepoSource is the special value of --repos / --allowed-repos-filepath that means the repos should be read from STDIN
+const StdinRepoSource = "-"
+
+var (
+	// The regex for all common special characters to remove from the repo lines in the allowed repos file
+	charRegex = regexp.MustCompile(`['",!]`)
+
+	// Matches a repo in the plain org/repo format, e.g., gruntwork-io/cloud-nuke
+	orgAndRepoRegex = regexp.MustCompile(`^([\w.-]+)/([\w.-]+?)(?:\.git)?/?$`)
+
+	// Matches a repo URL with a scheme, e.g., https://github.com/gruntwork-io/cloud-nuke or
+	// ssh://git@github.com/gruntwork-io/cloud-nuke.git. Any host is accepted, so Github Enterprise URLs work too
+	repoURLRegex = regexp.MustCompile(`^(?:https?|ssh|git)://(?:[^@/]+@)?[^/]+/([\w.-]+)/([\w.-]+?)(?:\.git)?/?$`)
+
+	// Matches an scp-style SSH remote, e.g., git@github.com:gruntwork-io/cloud-nuke.git
+	sshRemoteRegex = regexp.MustCompile(`^[\w.-]+@[\w.-]+:([\w.-]+)/([\w.-]+?)(?:\.git)?/?$`)
+
+	// The same three formats, for GitLab projects nested in subgroups, e.g., platform/infra/terraform, where the full path
+	// of the subgroup stands in for the organization
+	gitlabNestedRepoRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`^([\w.-]+(?:/[\w.-]+)+)/([\w.-]+?)(?:\.git)?/?$`),
+		regexp.MustCompile(`^(?:https?|ssh|git)://(?:[^@/]+@)?[^/]+/([\w.-]+(?:/[\w.-]+)+)/([\w.-]+?)(?:\.git)?/?$`),
+		regexp.MustCompile(`^[\w.-]+@[\w.-]+:([\w.-]+(?:/[\w.-]+)+)/([\w.-]+?)(?:\.git)?/?$`),
+	}
+)
+
+// parseRepoLine extracts the organization and repo name from a single line naming a repo, which may be in the plain
+// org/repo format, a full URL to the repo, or an SSH remote. Stray quotes, commas and exclamation marks are stripped
+// first. It returns false if the line is not in any recognized format. With --repo-host gitlab, repos nested in subgroups
+// are recognized too
+func parseRepoLine(line string) (*AllowedRepo, bool) {
+	cleanedLine := charRegex.ReplaceAllString(strings.TrimSpace(line), "")
+
+	regexes := []*regexp.Regexp{orgAndRepoRegex, repoURLRegex, sshRemoteRegex}
+	if RepoHostName == RepoHostGitlab {
+		regexes = append(regexes, gitlabNestedRepoRegexes...)
+	}
+
+	for _, re := range regexes {
+		if matches := re.FindStringSubmatch(cleanedLine); matches != nil {
+			return &AllowedRepo{
+				Organization: matches[1],
+				Name:         matches[2],
+			}, true
+		}
+	}
+
+	return nil, false
+}
+
+// parseAllowedRepos reads repos from the reader, one per line, in any of the formats accepted by parseRepoLine. Blank
+// lines and lines starting with # are ignored. Lines that cannot be parsed are returned separately so that they can be
+// reported rather than silently dropped
+func parseAllowedRepos(reader io.Reader) ([]*AllowedRepo, []string, error) {
+	var allowedRepos []*AllowedRepo
+	var malformedLines []string
+
+	scanner := bufio.NewScanner(reader)
+	for scanner.Scan() {
+		trimmedLine := strings.TrimSpace(scanner.Text())
+		if trimmedLine == "" || strings.HasPrefix(trimmedLine, "#") {
+			continue
+		}
+
+		repo, ok := parseRepoLine(trimmedLine)
+		if !ok {
+			log.WithFields(logrus.Fields{
+				"Line": trimmedLine,
+			}).Debug("Could not parse repo from line")
+
+			malformedLines = append(malformedLines, trimmedLine)
+			continue
+		}
+
+		allowedRepos = append(allowedRepos, repo)
+	}
+
+	if err := scanner.Err(); err != nil {
+		log.WithFields(logrus.Fields{
+			"Error": err,
+		}).Debug("Error parsing line from allowed repos file")
+		return allowedRepos, malformedLines, err
+	}
+
+	return allowedRepos, malformedLines, nil
+}
+
+// This utility function accepts a path to the flatfile in which the user has defined their explicitly allowed repos, or
+// StdinRepoSource to read them from STDIN instead, so that lists of repos can be piped in xargs-style
 // It expects repos to be defined one per line in the following format: `gruntwork-io/cloud-nuke` with optional commas
+// Full Github URLs and SSH remotes are also accepted
 // Stray single and double quotes are also handled and stripped out if they are encountered, and spacing is irrelevant
-func processAllowedRepos(filepath string) ([]*AllowedRepo, error) {
-	var allowedRepos []*AllowedRepo
+// Lines that don't name a repo in any of the accepted formats are returned separately
+func processAllowedRepos(filepath string) ([]*AllowedRepo, []string, error) {
+	if filepath == StdinRepoSource {
+		log.Debug("Reading repos from STDIN")
+		return parseAllowedRepos(os.Stdin)
+	}
 
 	file, err := os.Open(filepath)
 
@@ -23,7 +119,7 @@ func processAllowedRepos(filepath string) ([]*AllowedRepo, error) {
 			"Filepath": filepath,
 		}).Debug("Could not open")
 
-		return allowedRepos, err
+		return nil, nil, err
 	}
 
 	// By wrapping the file.Close in a deferred anonymous function, we are able to avoid a nasty edge-case where
@@ -37,41 +133,26 @@ func processAllowedRepos(filepath string) ([]*AllowedRepo, error) {
 		}
 	}()
 
-	// The regex for all common special characters to remove from the repo lines in the allowed repos file
-	charRegex := regexp.MustCompile(`['",!]`)
-
-	// Read through the file line by line, extracting the repo organization and name by splitting on the / char
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		trimmedLine := strings.TrimSpace(scanner.Text())
-		cleanedLine := charRegex.ReplaceAllString(trimmedLine, "")
-		orgAndRepoSlice := strings.Split(cleanedLine, "/")
-		// Guard against stray lines, extra dangling single quotes, etc
-		if len(orgAndRepoSlice) < 2 {
-			continue
-		}
-
-		// Validate both the org and name are not empty
-		parsedOrg := orgAndRepoSlice[0]
-		parsedName := orgAndRepoSlice[1]
-
-		// If both org name and repo name are present, create a new allowed repo and add it to the list
-		if parsedOrg != "" && parsedName != "" {
-			repo := &AllowedRepo{
-				Organization: parsedOrg,
-				Name:         parsedName,
-			}
-			allowedRepos = append(allowedRepos, repo)
-
-		}
+	return parseAllowedRepos(file)
+}
 
+// stdinIsPiped returns true if STDIN is a pipe or redirected file rather than an interactive terminal, meaning the
+// operator may be piping a list of repos into the tool
+func stdinIsPiped() bool {
+	info, err := os.Stdin.Stat()
+	if err != nil {
+		return false
 	}
+	return info.Mode()&os.ModeCharDevice == 0
+}
 
-	if err := scanner.Err(); err != nil {
-		log.WithFields(logrus.Fields{
-			"Error": err,
-		}).Debug("Error parsing line from allowed repos file")
+// trackMalformedRepoLines tracks each line that could not be parsed as a repo under its own event, so that they show
+// up in the run report. Since there is no real repo behind such a line, the line itself is used as the repo's name
+func trackMalformedRepoLines(malformedLines []string, stats *RunStats) {
+	for _, line := range malformedLines {
+		malformedRepo := &github.Repository{
+			Name: github.String(line),
+		}
+		stats.TrackError(RepoLineMalformed, malformedRepo, fmt.Errorf("could not parse %q as org/repo, a repo URL or an SSH remote", line))
 	}
-
-	return allowedRepos, nil
 }
@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -10,7 +11,7 @@ func TestProcessAllowedReposErrsOnBadFilepath(t *testing.T) {
 
 	intentionallyBadFilepath := "_testdata/i-am-not-really-here.sh"
 
-	allowedRepos, err := processAllowedRepos(intentionallyBadFilepath)
+	allowedRepos, _, err := processAllowedRepos(intentionallyBadFilepath)
 
 	assert.Error(t, err)
 
@@ -21,10 +22,12 @@ func TestProcessAllowedReposCorrectlyParsesValidReposFile(t *testing.T) {
 
 	filepathToValidReposFile := "_testdata/good-test-repos.txt"
 
-	allowedRepos, err := processAllowedRepos(filepathToValidReposFile)
+	allowedRepos, malformedLines, err := processAllowedRepos(filepathToValidReposFile)
 
 	assert.NoError(t, err)
 
+	assert.Empty(t, malformedLines)
+
 	assert.Equal(t, len(allowedRepos), 3)
 
 	// Test that repo names are correctly parsed from the flat file by initiallly setting a map of each repo name
@@ -50,10 +53,13 @@ func TestProcessAllowedReposCorrectlyFiltersMalformedInput(t *testing.T) {
 
 	filepathToReposFileWithSomeMalformedRepos := "_testdata/mixed-test-repos.txt"
 
-	allowedRepos, err := processAllowedRepos(filepathToReposFileWithSomeMalformedRepos)
+	allowedRepos, malformedLines, err := processAllowedRepos(filepathToReposFileWithSomeMalformedRepos)
 
 	assert.NoError(t, err)
 
+	// The malformed lines are returned so that they can be reported, rather than silently dropped
+	assert.Equal(t, len(malformedLines), 3)
+
 	// There are 3 valid repos defined in this test file, and 3 intentionally malformed repos, so only 3 should
 	// be returned by the function as valid repos to operate on
 
@@ -78,3 +84,33 @@ func TestProcessAllowedReposCorrectlyFiltersMalformedInput(t *testing.T) {
 	}
 
 }
+
+func TestParseRepoLineAcceptsUrlsAndSshRemotes(t *testing.T) {
+	validLines := []string{
+		"gruntwork-io/cloud-nuke",
+		"https://github.com/gruntwork-io/cloud-nuke",
+		"https://github.com/gruntwork-io/cloud-nuke.git",
+		"https://github.example.com/gruntwork-io/cloud-nuke/",
+		"git@github.com:gruntwork-io/cloud-nuke.git",
+		"ssh://git@github.com/gruntwork-io/cloud-nuke.git",
+		"  'gruntwork-io/cloud-nuke',  ",
+	}
+
+	for _, line := range validLines {
+		repo, ok := parseRepoLine(line)
+
+		assert.True(t, ok, line)
+		assert.Equal(t, repo.Organization, "gruntwork-io", line)
+		assert.Equal(t, repo.Name, "cloud-nuke", line)
+	}
+}
+
+func TestParseAllowedReposReportsMalformedLinesAndSkipsBlanks(t *testing.T) {
+	input := strings.NewReader("gruntwork-io/fetch\n\n# a comment\nnot a repo\nhttps://github.com/gruntwork-io/terragrunt\n")
+
+	allowedRepos, malformedLines, err := parseAllowedRepos(input)
+
+	assert.NoError(t, err)
+	assert.Equal(t, len(allowedRepos), 2)
+	assert.Equal(t, malformedLines, []string{"not a repo"})
+}
@@ -0,0 +1,378 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// RunStateSchemaVersion is the version of the run-state file format. It must be incremented whenever a field is removed
+// or changes meaning, so that an older state file is never misread when resuming
+const RunStateSchemaVersion = 2
+
+// RepoState is the journalled progress of a single repo through a run. Stages holds every Event tracked against the repo,
+// in order and without duplicates, reusing the same Event vocabulary as the run report
+type RepoState struct {
+	Stages         []Event
+	PullRequestURL string
+	LastError      string
+	Completed      bool
+	UpdatedAt      time.Time
+}
+
+// RunState is the progress of every repo through a run, as rebuilt from its state file
+type RunState struct {
+	SchemaVersion int
+	BranchName    string
+	DryRun        bool
+	StartedAt     time.Time
+	Repos         map[string]*RepoState
+}
+
+// runStateHeader is the first line of a state file, describing the run it journals
+type runStateHeader struct {
+	SchemaVersion int       `json:"schema_version"`
+	BranchName    string    `json:"branch_name"`
+	DryRun        bool      `json:"dry_run"`
+	StartedAt     time.Time `json:"started_at"`
+}
+
+// runStateEntry is each following line of a state file, recording a single event tracked against a single repo
+type runStateEntry struct {
+	Repo           string    `json:"repo"`
+	Event          Event     `json:"event"`
+	Error          string    `json:"error,omitempty"`
+	PullRequestURL string    `json:"pull_request_url,omitempty"`
+	At             time.Time `json:"at"`
+}
+
+// RunJournal records how far each repo got through a run to a state file on disk. The file is in JSON Lines format: a
+// header describing the run, followed by a line per event that is appended as soon as the event is tracked, so that it
+// survives the process dying at any point without the whole file being rewritten each time. A run can later be resumed
+// from the state file, skipping repos that were completed and retrying the rest. It is safe for concurrent use, and a
+// nil *RunJournal is valid and records nothing
+type RunJournal struct {
+	mu    sync.Mutex
+	path  string
+	state RunState
+}
+
+// completingEvents are the events after which a repo needs no further work, so it is skipped when the run is resumed
+var completingEvents = map[Event]bool{
+	PullRequestOpened: true,
+	NoChangesRequired: true,
+}
+
+// NewRunJournal creates an empty journal that will be written to the supplied path
+func NewRunJournal(path, branchName string, dryRun bool) *RunJournal {
+	return &RunJournal{
+		path: path,
+		state: RunState{
+			SchemaVersion: RunStateSchemaVersion,
+			BranchName:    branchName,
+			DryRun:        dryRun,
+			StartedAt:     time.Now().UTC(),
+			Repos:         make(map[string]*RepoState),
+		},
+	}
+}
+
+// LoadRunJournal reads a previously written state file so that the run it describes can be resumed, replaying each of
+// its events. Further events are appended to the same file. A last line left half-written by a run that died mid-write
+// is dropped
+func LoadRunJournal(path string) (*RunJournal, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	decoder := json.NewDecoder(file)
+
+	var header runStateHeader
+	if err := decoder.Decode(&header); err != nil {
+		return nil, fmt.Errorf("state file %s does not start with a run-state header: %v", path, err)
+	}
+
+	if header.SchemaVersion != RunStateSchemaVersion {
+		return nil, fmt.Errorf("state file %s has schema version %d, but this version of git-xargs only understands version %d", path, header.SchemaVersion, RunStateSchemaVersion)
+	}
+
+	journal := NewRunJournal(path, header.BranchName, header.DryRun)
+	journal.state.StartedAt = header.StartedAt
+
+	validLength := decoder.InputOffset()
+	for {
+		var entry runStateEntry
+		if err := decoder.Decode(&entry); err != nil {
+			if err == io.EOF {
+				break
+			}
+			if err == io.ErrUnexpectedEOF {
+				// Cut the half-written line off, so that the events appended from here on start on a line of their own
+				log.WithFields(logrus.Fields{
+					"State file": path,
+				}).Debug("Dropping the half-written last line of the run state file")
+				if truncateErr := os.Truncate(path, validLength); truncateErr != nil {
+					return nil, truncateErr
+				}
+				break
+			}
+			return nil, fmt.Errorf("state file %s is not valid: %v", path, err)
+		}
+		journal.apply(entry)
+		validLength = decoder.InputOffset()
+	}
+
+	return journal, nil
+}
+
+// defaultRunStatePath creates an empty state file with a unique name in the system temp directory and returns its path,
+// so that runs started at the same time never append to each other's state file
+func defaultRunStatePath() (string, error) {
+	file, err := ioutil.TempFile("", "git-xargs-run-*.jsonl")
+	if err != nil {
+		return "", err
+	}
+	return file.Name(), file.Close()
+}
+
+// configureRunJournal sets up the journal for this run. When resuming, the existing state file is loaded and must
+// describe a run using the same branch name and dry-run setting; otherwise a new state file is started at statePath,
+// or at a generated path in the system temp directory if statePath is empty
+func configureRunJournal(resumePath, statePath, branchName string, dryRun bool) (*RunJournal, error) {
+	if resumePath == "" {
+		if statePath == "" {
+			var err error
+			if statePath, err = defaultRunStatePath(); err != nil {
+				return nil, err
+			}
+		}
+		journal := NewRunJournal(statePath, branchName, dryRun)
+		return journal, journal.writeHeader()
+	}
+
+	journal, err := LoadRunJournal(resumePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if journal.state.BranchName != branchName {
+		return nil, fmt.Errorf("state file %s was written by a run using branch %q, but this run is using branch %q", resumePath, journal.state.BranchName, branchName)
+	}
+
+	if journal.state.DryRun != dryRun {
+		return nil, fmt.Errorf("state file %s was written by a run with --dry-run=%t, but this run has --dry-run=%t", resumePath, journal.state.DryRun, dryRun)
+	}
+
+	return journal, nil
+}
+
+// Path returns the path of the state file the journal is written to
+func (j *RunJournal) Path() string {
+	if j == nil {
+		return ""
+	}
+	return j.path
+}
+
+// journalKey returns the key a repo's state is stored under. The full name is used so that same-named repos in
+// different organizations can't collide
+func journalKey(repo *github.Repository) string {
+	if repo.GetFullName() != "" {
+		return repo.GetFullName()
+	}
+	return fmt.Sprintf("%s/%s", repo.GetOwner().GetLogin(), repo.GetName())
+}
+
+// Record journals that the event happened to the repo, along with the error that caused it, if any, appending it to the
+// state file
+func (j *RunJournal) Record(repo *github.Repository, event Event, err error) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	j.appendAndLog(newRunStateEntry(repo, event, err))
+}
+
+// RecordMultiple is like Record, but journals the event for every repo in the slice with a single write to disk
+func (j *RunJournal) RecordMultiple(repos []*github.Repository, event Event) {
+	if j == nil || len(repos) == 0 {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entries := make([]runStateEntry, len(repos))
+	for i, repo := range repos {
+		entries[i] = newRunStateEntry(repo, event, nil)
+	}
+	j.appendAndLog(entries...)
+}
+
+// RecordPullRequest journals the URL of the pull request opened against the repo, which completes the repo, appending
+// it to the state file
+func (j *RunJournal) RecordPullRequest(repo *github.Repository, prURL string) {
+	if j == nil {
+		return
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	entry := newRunStateEntry(repo, PullRequestOpened, nil)
+	entry.PullRequestURL = prURL
+	j.appendAndLog(entry)
+}
+
+// newRunStateEntry returns the line of the state file journalling that the event happened to the repo
+func newRunStateEntry(repo *github.Repository, event Event, err error) runStateEntry {
+	entry := runStateEntry{
+		Repo:  journalKey(repo),
+		Event: event,
+		At:    time.Now().UTC(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	return entry
+}
+
+// apply updates the in-memory state of the entry's repo. Callers must hold j.mu, except while loading the journal
+func (j *RunJournal) apply(entry runStateEntry) {
+	repoState, ok := j.state.Repos[entry.Repo]
+	if !ok {
+		repoState = &RepoState{}
+		j.state.Repos[entry.Repo] = repoState
+	}
+
+	if !repoState.hasStage(entry.Event) {
+		repoState.Stages = append(repoState.Stages, entry.Event)
+	}
+
+	if entry.Error != "" {
+		repoState.LastError = entry.Error
+	}
+
+	if entry.PullRequestURL != "" {
+		repoState.PullRequestURL = entry.PullRequestURL
+	}
+
+	// A dry run never actually completes a repo, so resuming from a dry run's state file must process every repo again
+	if completingEvents[entry.Event] && !j.state.DryRun {
+		repoState.Completed = true
+		repoState.LastError = ""
+	}
+
+	repoState.UpdatedAt = entry.At
+}
+
+// IsCompleted returns true if a previous run already finished all work on the repo
+func (j *RunJournal) IsCompleted(repo *github.Repository) bool {
+	if j == nil {
+		return false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	repoState, ok := j.state.Repos[journalKey(repo)]
+	return ok && repoState.Completed
+}
+
+// HasStage returns true if the event was previously journalled for the repo
+func (j *RunJournal) HasStage(repo *github.Repository, event Event) bool {
+	if j == nil {
+		return false
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	repoState, ok := j.state.Repos[journalKey(repo)]
+	return ok && repoState.hasStage(event)
+}
+
+// hasStage returns true if the event is among the repo's journalled stages
+func (rs *RepoState) hasStage(event Event) bool {
+	for _, stage := range rs.Stages {
+		if stage == event {
+			return true
+		}
+	}
+	return false
+}
+
+// appendAndLog applies the entries to the in-memory state and appends them to the state file, logging rather than
+// returning any error, since failing to journal should never stop the run itself. Callers must hold j.mu
+func (j *RunJournal) appendAndLog(entries ...runStateEntry) {
+	var lines bytes.Buffer
+	for _, entry := range entries {
+		j.apply(entry)
+
+		line, err := json.Marshal(entry)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error":      err,
+				"State file": j.path,
+			}).Debug("Error encoding run state entry")
+			return
+		}
+		lines.Write(line)
+		lines.WriteByte('\n')
+	}
+
+	if err := j.appendLines(lines.Bytes()); err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":      err,
+			"State file": j.path,
+		}).Debug("Error writing run state file")
+	}
+}
+
+// appendLines appends the encoded entries to the state file with a single write
+func (j *RunJournal) appendLines(lines []byte) error {
+	file, err := os.OpenFile(j.path, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.Write(lines); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// writeHeader starts the state file with the header describing the run. It's written to a temporary file next to the
+// state file and then renamed into place, so that the state file on disk never lacks its header
+func (j *RunJournal) writeHeader() error {
+	header, err := json.Marshal(runStateHeader{
+		SchemaVersion: j.state.SchemaVersion,
+		BranchName:    j.state.BranchName,
+		DryRun:        j.state.DryRun,
+		StartedAt:     j.state.StartedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	tmpPath := j.path + ".tmp"
+	if err := ioutil.WriteFile(tmpPath, append(header, '\n'), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpPath, j.path)
+}
@@ -0,0 +1,161 @@
+package cmd
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func makeJournalTestDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "git-xargs-journal-test")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestRunJournalRoundTripsThroughStateFile(t *testing.T) {
+	dir := makeJournalTestDir(t)
+	defer os.RemoveAll(dir)
+
+	statePath := filepath.Join(dir, "state.jsonl")
+	finished := &github.Repository{Name: github.String("fetch"), FullName: github.String("gruntwork-io/fetch")}
+	pushed := &github.Repository{Name: github.String("cloud-nuke"), FullName: github.String("gruntwork-io/cloud-nuke")}
+	failed := &github.Repository{Name: github.String("terragrunt"), FullName: github.String("gruntwork-io/terragrunt")}
+
+	journal, err := configureRunJournal("", statePath, "git-xargs", false)
+	require.NoError(t, err)
+	journal.Record(finished, RepoSuccessfullyCloned, nil)
+	journal.RecordPullRequest(finished, "https://github.com/gruntwork-io/fetch/pull/1")
+	journal.Record(pushed, PushBranchSucceeded, nil)
+	journal.Record(pushed, PullRequestOpenErr, errors.New("422 Validation Failed"))
+	journal.Record(failed, RepoFailedToClone, errors.New("authentication required"))
+
+	loaded, err := LoadRunJournal(statePath)
+	require.NoError(t, err)
+
+	assert.True(t, loaded.IsCompleted(finished))
+	assert.False(t, loaded.IsCompleted(pushed))
+	assert.True(t, loaded.HasStage(pushed, PushBranchSucceeded))
+	assert.False(t, loaded.IsCompleted(failed))
+	assert.Equal(t, loaded.state.Repos["gruntwork-io/fetch"].PullRequestURL, "https://github.com/gruntwork-io/fetch/pull/1")
+	assert.Equal(t, loaded.state.Repos["gruntwork-io/terragrunt"].LastError, "authentication required")
+}
+
+func TestRunJournalNeverCompletesReposDuringDryRun(t *testing.T) {
+	dir := makeJournalTestDir(t)
+	defer os.RemoveAll(dir)
+
+	repo := &github.Repository{Name: github.String("fetch"), FullName: github.String("gruntwork-io/fetch")}
+
+	journal, err := configureRunJournal("", filepath.Join(dir, "state.jsonl"), "git-xargs", true)
+	require.NoError(t, err)
+	journal.Record(repo, NoChangesRequired, nil)
+
+	assert.False(t, journal.IsCompleted(repo))
+}
+
+func TestRunJournalAppendsALinePerEvent(t *testing.T) {
+	dir := makeJournalTestDir(t)
+	defer os.RemoveAll(dir)
+
+	statePath := filepath.Join(dir, "state.jsonl")
+	repo := &github.Repository{Name: github.String("fetch"), FullName: github.String("gruntwork-io/fetch")}
+	others := []*github.Repository{
+		{Name: github.String("cloud-nuke"), FullName: github.String("gruntwork-io/cloud-nuke")},
+		{Name: github.String("terragrunt"), FullName: github.String("gruntwork-io/terragrunt")},
+	}
+
+	journal, err := configureRunJournal("", statePath, "git-xargs", false)
+	require.NoError(t, err)
+	header := readJournalLines(t, statePath)
+	require.Equal(t, len(header), 1)
+
+	journal.Record(repo, RepoSuccessfullyCloned, nil)
+	journal.RecordMultiple(others, ReposSelected)
+	journal.RecordPullRequest(repo, "https://github.com/gruntwork-io/fetch/pull/1")
+
+	// Earlier lines are never rewritten, only added to
+	lines := readJournalLines(t, statePath)
+	assert.Equal(t, len(lines), 5)
+	assert.Equal(t, lines[0], header[0])
+}
+
+func TestLoadRunJournalDropsHalfWrittenLastLine(t *testing.T) {
+	dir := makeJournalTestDir(t)
+	defer os.RemoveAll(dir)
+
+	statePath := filepath.Join(dir, "state.jsonl")
+	finished := &github.Repository{Name: github.String("fetch"), FullName: github.String("gruntwork-io/fetch")}
+	pending := &github.Repository{Name: github.String("terragrunt"), FullName: github.String("gruntwork-io/terragrunt")}
+
+	journal, err := configureRunJournal("", statePath, "git-xargs", false)
+	require.NoError(t, err)
+	journal.RecordPullRequest(finished, "https://github.com/gruntwork-io/fetch/pull/1")
+
+	// Simulate the run dying part way through appending an event
+	file, err := os.OpenFile(statePath, os.O_APPEND|os.O_WRONLY, 0644)
+	require.NoError(t, err)
+	_, err = file.WriteString(`{"repo":"gruntwork-io/terragrunt","event":"pull-request-op`)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	resumed, err := LoadRunJournal(statePath)
+	require.NoError(t, err)
+	assert.True(t, resumed.IsCompleted(finished))
+	assert.False(t, resumed.IsCompleted(pending))
+
+	// Events recorded after resuming can still be read back
+	resumed.RecordPullRequest(pending, "https://github.com/gruntwork-io/terragrunt/pull/2")
+	reloaded, err := LoadRunJournal(statePath)
+	require.NoError(t, err)
+	assert.True(t, reloaded.IsCompleted(pending))
+}
+
+func readJournalLines(t *testing.T, path string) []string {
+	contents, err := ioutil.ReadFile(path)
+	require.NoError(t, err)
+	return strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+}
+
+func TestConfigureRunJournalGivesRunsStartedTogetherTheirOwnStateFiles(t *testing.T) {
+	first, err := configureRunJournal("", "", "git-xargs", false)
+	require.NoError(t, err)
+	defer os.Remove(first.Path())
+
+	second, err := configureRunJournal("", "", "git-xargs", false)
+	require.NoError(t, err)
+	defer os.Remove(second.Path())
+
+	assert.NotEqual(t, first.Path(), second.Path())
+	assert.True(t, strings.HasSuffix(first.Path(), ".jsonl"))
+}
+
+func TestConfigureRunJournalRejectsMismatchedBranch(t *testing.T) {
+	dir := makeJournalTestDir(t)
+	defer os.RemoveAll(dir)
+
+	statePath := filepath.Join(dir, "state.jsonl")
+
+	_, err := configureRunJournal("", statePath, "git-xargs", false)
+	require.NoError(t, err)
+
+	_, resumeErr := configureRunJournal(statePath, "", "some-other-branch", false)
+	assert.Error(t, resumeErr)
+}
+
+func TestNilRunJournalIsSafeToUse(t *testing.T) {
+	var journal *RunJournal
+	repo := &github.Repository{Name: github.String("fetch")}
+
+	journal.Record(repo, RepoSuccessfullyCloned, nil)
+
+	assert.False(t, journal.IsCompleted(repo))
+	assert.False(t, journal.HasStage(repo, RepoSuccessfullyCloned))
+	assert.Equal(t, journal.Path(), "")
+}
@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	// CampaignRunReport is the path of a JSON run report, saved via --report-file, listing the pull requests of a campaign
+	CampaignRunReport string
+	// MergeMethod is how pull requests are merged - one of merge, squash or rebase
+	MergeMethod string
+	// MergeWaitTimeout is how long to keep polling a pull request whose checks are still running before giving up on it
+	MergeWaitTimeout time.Duration
+	// MergePollInterval is how long to wait between polls of a pull request whose checks are still running
+	MergePollInterval time.Duration
+	// MergeRequireApproval only merges pull requests that were approved by at least one reviewer
+	MergeRequireApproval bool
+	// MergeDeleteBranch deletes the branch of each pull request once it's merged
+	MergeDeleteBranch bool
+)
+
+// mergeMethods are the values accepted by --merge-method
+var mergeMethods = map[string]bool{
+	"merge":  true,
+	"squash": true,
+	"rebase": true,
+}
+
+func init() {
+	mergeCmd.Flags().StringVar(&CampaignRunReport, "run-report", "", "The path of a JSON run report saved via --report-file, whose pull requests should be merged. Otherwise, the open pull requests of --branch-name are looked up")
+
+	mergeCmd.Flags().StringVar(&MergeMethod, "merge-method", "merge", "How to merge each pull request. One of: merge, squash, rebase")
+
+	mergeCmd.Flags().DurationVar(&MergeWaitTimeout, "wait-timeout", 30*time.Minute, "How long to wait for the checks of each pull request to finish before giving up on it")
+
+	mergeCmd.Flags().DurationVar(&MergePollInterval, "poll-interval", 30*time.Second, "How long to wait between checking on a pull request whose checks are still running")
+
+	mergeCmd.Flags().BoolVar(&MergeRequireApproval, "require-approval", true, "Only merge pull requests that were approved by at least one reviewer, and that nobody requested changes on")
+
+	mergeCmd.Flags().BoolVar(&MergeDeleteBranch, "delete-branch", true, "Delete the branch of each pull request once it's merged")
+
+	rootCmd.AddCommand(mergeCmd)
+}
+
+var mergeCmd = &cobra.Command{
+	Use:   "merge",
+	Short: "Merge the pull requests opened by a previous run once their checks pass",
+	Long:  "Merge the pull requests opened by a previous run, read from a saved run report or looked up by branch name, once their checks pass and they are approved, then delete their branches",
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		campaignPreRun(cmd, args)
+
+		if !mergeMethods[MergeMethod] {
+			log.WithFields(logrus.Fields{
+				"Merge method": MergeMethod,
+			}).Fatal("Invalid --merge-method. Must be one of: merge, squash, rebase")
+		}
+	},
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := cancelOnInterrupt()
+		defer cancel()
+
+		GithubClient := ConfigureGithubClient(ctx)
+
+		stats := NewStatsTracker()
+
+		prs, err := selectCampaignPullRequests(GithubClient, true, stats)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+			}).Fatal("Error looking up the pull requests to merge")
+		}
+
+		forEachCampaignPullRequest(ctx, prs, stats, func(pr CampaignPullRequest, repo *github.Repository) {
+			if mergeErr := mergeCampaignPullRequest(ctx, DryRun, GithubClient, pr, repo, stats); mergeErr != nil {
+				log.WithFields(logrus.Fields{
+					"Pull Request URL": pr.URL,
+					"Error":            mergeErr,
+				}).Debug("Pull request was not merged")
+			}
+		})
+
+		stats.printReport(mergeEvents)
+	},
+}
+
+// waitForMergeablePullRequest polls the pull request until its checks have finished and Github knows whether it can be
+// merged, or until MergeWaitTimeout elapses. Each poll is a fresh look at the pull request, so that a push or a review
+// that happens while waiting is taken into account
+func waitForMergeablePullRequest(ctx context.Context, githubClient *github.Client, pr CampaignPullRequest) (*PullRequestState, error) {
+	deadline := time.Now().Add(MergeWaitTimeout)
+
+	for {
+		state, err := getPullRequestState(githubClient, pr)
+		if err != nil {
+			return nil, err
+		}
+
+		stillWaiting := state.State == "open" && (state.Checks == ChecksPending || state.Mergeable == MergeableUnknown)
+		if !stillWaiting || time.Now().After(deadline) {
+			return state, nil
+		}
+
+		log.WithFields(logrus.Fields{
+			"Pull Request URL": pr.URL,
+			"Checks":           state.Checks,
+			"Mergeable":        state.Mergeable,
+		}).Debug("Waiting for pull request checks to finish")
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(MergePollInterval):
+		}
+	}
+}
+
+// mergeCampaignPullRequest waits for the pull request's checks to finish and, if they passed, it was approved (when
+// --require-approval is set) and it has no conflicts, merges it and deletes its branch. Every pull request that isn't
+// merged is tracked under the reason why
+func mergeCampaignPullRequest(ctx context.Context, dryRun bool, githubClient *github.Client, pr CampaignPullRequest, repo *github.Repository, stats *RunStats) error {
+	state, err := waitForMergeablePullRequest(ctx, githubClient, pr)
+	if err == context.Canceled {
+		stats.TrackSingle(RepoProcessingCancelled, repo)
+		return err
+	}
+	if err != nil {
+		stats.TrackError(PullRequestLookupErr, repo, err)
+		return err
+	}
+
+	var blockedEvent Event
+	switch {
+	case state.State != "open":
+		blockedEvent = PullRequestNotOpen
+	case state.Checks == ChecksFailure:
+		blockedEvent = PullRequestChecksFailed
+	case state.Checks == ChecksPending:
+		blockedEvent = PullRequestChecksPending
+	case MergeRequireApproval && state.Review != ReviewApproved:
+		blockedEvent = PullRequestNotApproved
+	case state.Mergeable != MergeableClean:
+		blockedEvent = PullRequestNotMergeable
+	}
+
+	if blockedEvent != "" {
+		blockedErr := fmt.Errorf("pull request is %s, checks are %s, review is %s and mergeability is %s", state.State, state.Checks, state.Review, state.Mergeable)
+		stats.TrackError(blockedEvent, repo, blockedErr)
+		return blockedErr
+	}
+
+	if dryRun {
+		log.WithFields(logrus.Fields{
+			"Pull Request URL": pr.URL,
+		}).Debug("dryRun is set to true, so skipping merging a pull request that is ready to merge!")
+
+		stats.TrackSingle(PullRequestReadyToMerge, repo)
+		return nil
+	}
+
+	// Passing the SHA of the head we checked ensures nothing is merged that was pushed after the checks were looked at
+	opts := &github.PullRequestOptions{
+		MergeMethod: MergeMethod,
+		SHA:         state.PullRequest.GetHead().GetSHA(),
+	}
+
+	if _, _, mergeErr := githubClient.PullRequests.Merge(context.Background(), pr.Owner, pr.Repo, pr.Number, "", opts); mergeErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error":            mergeErr,
+			"Pull Request URL": pr.URL,
+		}).Debug("Error merging pull request")
+
+		stats.TrackError(PullRequestMergeErr, repo, mergeErr)
+		return mergeErr
+	}
+
+	log.WithFields(logrus.Fields{
+		"Pull Request URL": pr.URL,
+		"Merge method":     MergeMethod,
+	}).Debug("Successfully merged pull request")
+
+	stats.TrackSingle(PullRequestMerged, repo)
+
+	if MergeDeleteBranch {
+		return deleteCampaignBranch(githubClient, state.PullRequest, repo, stats)
+	}
+	return nil
+}
+
+// deleteCampaignBranch deletes the head branch of the pull request. Branches of pull requests opened from forks are
+// left alone, since they belong to someone else
+func deleteCampaignBranch(githubClient *github.Client, ghPR *github.PullRequest, repo *github.Repository, stats *RunStats) error {
+	if openedFromFork(ghPR) {
+		return nil
+	}
+
+	return deleteBranch(githubClient, repo, ghPR.GetHead().GetRef(), stats)
+}
+
+// openedFromFork returns true, and logs why its branch is left alone, if the pull request was opened from a fork
+func openedFromFork(ghPR *github.PullRequest) bool {
+	if ghPR.GetHead().GetRepo().GetFullName() == ghPR.GetBase().GetRepo().GetFullName() {
+		return false
+	}
+
+	log.WithFields(logrus.Fields{
+		"Pull Request URL": ghPR.GetHTMLURL(),
+	}).Debug("Not deleting the branch of a pull request opened from a fork")
+	return true
+}
+
+// deleteBranch deletes the branch from the repo's remote origin. A branch that is already gone, e.g., because the repo
+// has "Automatically delete head branches" enabled and Github deleted it as soon as its pull request was merged, is
+// treated as deleted. Github responds to deleting a missing ref with a 422, or a 404
+func deleteBranch(githubClient *github.Client, repo *github.Repository, branch string, stats *RunStats) error {
+	resp, err := githubClient.Git.DeleteRef(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), "heads/"+branch)
+	if err != nil && resp != nil && (resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusUnprocessableEntity) {
+		log.WithFields(logrus.Fields{
+			"Branch": branch,
+			"Repo":   repo.GetName(),
+		}).Debug("Branch was already deleted")
+	} else if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":  err,
+			"Branch": branch,
+			"Repo":   repo.GetName(),
+		}).Debug("Error deleting branch")
+
+		stats.TrackError(CampaignBranchDeleteErr, repo, err)
+		return err
+	}
+
+	stats.TrackSingle(CampaignBranchDeleted, repo)
+	return nil
+}
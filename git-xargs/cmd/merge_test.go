@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeTestCampaignPullRequest returns the campaign pull request served by newCampaignPullRequestMux
+func makeTestCampaignPullRequest() CampaignPullRequest {
+	return CampaignPullRequest{Owner: "gruntwork-io", Repo: "cloud-nuke", Number: 7, URL: "https://github.com/gruntwork-io/cloud-nuke/pull/7"}
+}
+
+// newCampaignPullRequestMux serves an open pull request with the supplied mergeability, combined status state, check run
+// conclusion and review state
+func newCampaignPullRequestMux(t *testing.T, mergeable bool, statusState, checkConclusion, reviewState string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"number": 7, "state": "open", "mergeable": %t, "html_url": "https://github.com/gruntwork-io/cloud-nuke/pull/7",
+			"head": {"ref": "git-xargs", "sha": "abc123", "repo": {"full_name": "gruntwork-io/cloud-nuke"}},
+			"base": {"ref": "master", "repo": {"full_name": "gruntwork-io/cloud-nuke"}}}`, mergeable)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/commits/abc123/status", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"state": %q, "total_count": 1}`, statusState)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/commits/abc123/check-runs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"total_count": 1, "check_runs": [{"status": "completed", "conclusion": %q}]}`, checkConclusion)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7/reviews", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"state": "COMMENTED", "user": {"login": "alice"}}, {"state": %q, "user": {"login": "bob"}}]`, reviewState)
+	})
+	return mux
+}
+
+func TestMergeCampaignPullRequestMergesGreenApprovedPullRequest(t *testing.T) {
+	originalMethod, originalDelete, originalApproval := MergeMethod, MergeDeleteBranch, MergeRequireApproval
+	defer func() {
+		MergeMethod, MergeDeleteBranch, MergeRequireApproval = originalMethod, originalDelete, originalApproval
+	}()
+	MergeMethod, MergeDeleteBranch, MergeRequireApproval = "squash", true, true
+
+	var mergeRequest map[string]string
+	branchDeleted := false
+
+	mux := newCampaignPullRequestMux(t, true, "success", "success", "APPROVED")
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7/merge", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPut)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&mergeRequest))
+		fmt.Fprint(w, `{"merged": true}`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/git/refs/heads/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodDelete)
+		branchDeleted = true
+		w.WriteHeader(http.StatusNoContent)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := mergeCampaignPullRequest(context.Background(), false, client, pr, pr.repository(), stats)
+
+	require.NoError(t, err)
+	assert.Equal(t, mergeRequest["merge_method"], "squash")
+	assert.Equal(t, mergeRequest["sha"], "abc123")
+	assert.True(t, branchDeleted)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestMerged)), 1)
+	assert.Equal(t, len(stats.GetMultiple(CampaignBranchDeleted)), 1)
+	assert.Equal(t, stats.CountHeadlineRepos(mergeEvents)[0], HeadlineCount{Headline: "Pull requests merged", Count: 1})
+}
+
+func TestMergeCampaignPullRequestToleratesBranchAlreadyDeletedByGithub(t *testing.T) {
+	originalMethod, originalDelete, originalApproval := MergeMethod, MergeDeleteBranch, MergeRequireApproval
+	defer func() {
+		MergeMethod, MergeDeleteBranch, MergeRequireApproval = originalMethod, originalDelete, originalApproval
+	}()
+	MergeMethod, MergeDeleteBranch, MergeRequireApproval = "squash", true, true
+
+	mux := newCampaignPullRequestMux(t, true, "success", "success", "APPROVED")
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7/merge", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"merged": true}`)
+	})
+	// The repo has "Automatically delete head branches" enabled, so the branch is gone by the time the merge returns
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/git/refs/heads/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Reference does not exist"}`, http.StatusUnprocessableEntity)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := mergeCampaignPullRequest(context.Background(), false, client, pr, pr.repository(), stats)
+
+	require.NoError(t, err)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestMerged)), 1)
+	assert.Equal(t, len(stats.GetMultiple(CampaignBranchDeleted)), 1)
+	assert.Empty(t, stats.GetMultiple(CampaignBranchDeleteErr))
+	assert.Equal(t, stats.CountFailedRepos(mergeEvents), 0)
+}
+
+func TestMergeCampaignPullRequestBlocksOnFailedChecks(t *testing.T) {
+	mux := newCampaignPullRequestMux(t, true, "success", "failure", "APPROVED")
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7/merge", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("A pull request with failing checks must not be merged")
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := mergeCampaignPullRequest(context.Background(), false, client, pr, pr.repository(), stats)
+
+	assert.Error(t, err)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestChecksFailed)), 1)
+	assert.Equal(t, stats.CountFailedRepos(mergeEvents), 0)
+}
+
+func TestMergeCampaignPullRequestBlocksOnRequestedChanges(t *testing.T) {
+	originalApproval := MergeRequireApproval
+	defer func() { MergeRequireApproval = originalApproval }()
+	MergeRequireApproval = true
+
+	client, closeServer := newTestGithubClient(t, newCampaignPullRequestMux(t, true, "success", "success", "CHANGES_REQUESTED"))
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := mergeCampaignPullRequest(context.Background(), true, client, pr, pr.repository(), stats)
+
+	assert.Error(t, err)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestNotApproved)), 1)
+}
+
+func TestMergeCampaignPullRequestGivesUpOnPendingChecks(t *testing.T) {
+	originalTimeout, originalInterval := MergeWaitTimeout, MergePollInterval
+	defer func() { MergeWaitTimeout, MergePollInterval = originalTimeout, originalInterval }()
+	MergeWaitTimeout, MergePollInterval = 50*time.Millisecond, 10*time.Millisecond
+
+	client, closeServer := newTestGithubClient(t, newCampaignPullRequestMux(t, true, "pending", "success", "APPROVED"))
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := mergeCampaignPullRequest(context.Background(), false, client, pr, pr.repository(), stats)
+
+	assert.Error(t, err)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestChecksPending)), 1)
+}
+
+func TestMergeCampaignPullRequestOnlyReportsReadyPullRequestsOnDryRun(t *testing.T) {
+	mux := newCampaignPullRequestMux(t, true, "success", "neutral", "APPROVED")
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7/merge", func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Nothing may be merged during a dry run")
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	err := mergeCampaignPullRequest(context.Background(), true, client, pr, pr.repository(), stats)
+
+	assert.NoError(t, err)
+	assert.Equal(t, stats.GetMultiple(PullRequestReadyToMerge), []*github.Repository{pr.repository()})
+}
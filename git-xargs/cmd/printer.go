@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"sort"
 	"strings"
 	"time"
 
@@ -26,6 +27,24 @@ func printRepoReport(allEvents []AnnotatedEvent, r *RunStats) {
 	fmt.Println("*****************************************************")
 	fmt.Printf("  RUN SUMMARY @ %v\n", time.Now().UTC())
 	fmt.Printf("  Runtime in seconds: %v\n", r.GetTotalRunSeconds())
+	// Only a run that makes changes opens pull requests or finds repos that need no changes, as opposed to, e.g., the merge subcommand
+	if hasEvent(allEvents, NoChangesRequired) {
+		fmt.Printf("  Pull requests opened: %d\n", len(r.GetPullRequests()))
+		fmt.Printf("  Repos that needed no changes: %d\n", len(r.GetMultiple(NoChangesRequired)))
+	}
+	for _, hc := range r.CountHeadlineRepos(allEvents) {
+		fmt.Printf("  %s: %d\n", hc.Headline, hc.Count)
+	}
+	fmt.Printf("  Repos that failed: %d\n", r.CountFailedRepos(allEvents))
+	if apiCalls := r.GetAPICallCounts(); apiCalls.Retried > 0 || apiCalls.Exhausted > 0 {
+		fmt.Printf("  Github API calls retried: %d, paused on rate limits: %d times, given up on: %d\n", apiCalls.Retried, apiCalls.RateLimitPauses, apiCalls.Exhausted)
+	}
+	if r.Journal() != nil {
+		fmt.Printf("  Run state file (pass to --resume to retry): %s\n", r.Journal().Path())
+	}
+	if ScriptLogDir != "" {
+		fmt.Printf("  Script output logs: %s\n", ScriptLogDir)
+	}
 	fmt.Println("*****************************************************")
 
 	// If there were any allowed repos provided via file, print out the list of them
@@ -36,36 +55,37 @@ func printRepoReport(allEvents []AnnotatedEvent, r *RunStats) {
 
 	// If the user selected repos via flatfile, print a table showing which repos they were
 	if len(r.fileProvidedRepos) > 0 {
-		fmt.Println(" REPOS SUPPLIED VIA --allowed-repos-filepath FLAG")
+		fmt.Println(" REPOS SUPPLIED VIA --allowed-repos-filepath / --repos FLAG OR STDIN")
 		fileProvidedReposPrinter.Print(r.fileProvidedRepos)
 	}
-	// For each event type, print a summary of the repos in that category
+	// For each event type, print a summary of the repos in that category. Failures are printed last, under their own
+	// banner, so that they are never confused with repos that were fine but simply needed no changes
 	for _, ae := range allEvents {
+		if !ae.Failure {
+			printEventSection(ae, r)
+		}
+	}
 
-		var reducedRepos []ReducedRepo
-
-		printer := tableprinter.New(os.Stdout)
-		configurePrinterStyling(printer)
+	if r.CountFailedRepos(allEvents) > 0 {
+		fmt.Println()
+		fmt.Println("*****************************************************")
+		fmt.Println("  FAILURES")
+		fmt.Println("*****************************************************")
 
-		for _, repo := range r.repos[ae.Event] {
-			rr := ReducedRepo{
-				Name: repo.GetName(),
-				URL:  repo.GetHTMLURL(),
+		for _, ae := range allEvents {
+			if ae.Failure {
+				printEventSection(ae, r)
 			}
-			reducedRepos = append(reducedRepos, rr)
-		}
-
-		if len(reducedRepos) > 0 {
-			fmt.Println()
-			fmt.Printf(" %s\n", strings.ToUpper(ae.Description))
-			printer.Print(reducedRepos)
-			fmt.Println()
 		}
 	}
 
+	printFailedRepoTimelines(r)
+
+	printFailedScriptOutputs(r)
+
 	var pullRequests []PullRequest
 
-	for repoName, prURL := range r.pulls {
+	for repoName, prURL := range r.GetPullRequests() {
 		pr := PullRequest{
 			Repo: repoName,
 			URL:  prURL,
@@ -85,3 +105,96 @@ func printRepoReport(allEvents []AnnotatedEvent, r *RunStats) {
 
 	}
 }
+
+// printEventSection prints a table of every repo filed under the supplied event, headed by the event's description. Events
+// with no repos filed under them are skipped entirely
+func printEventSection(ae AnnotatedEvent, r *RunStats) {
+	var reducedRepos []ReducedRepo
+
+	printer := tableprinter.New(os.Stdout)
+	configurePrinterStyling(printer)
+
+	for _, repo := range r.GetMultiple(ae.Event) {
+		rr := ReducedRepo{
+			Name: repo.GetName(),
+			URL:  repo.GetHTMLURL(),
+		}
+		reducedRepos = append(reducedRepos, rr)
+	}
+
+	if len(reducedRepos) > 0 {
+		fmt.Println()
+		fmt.Printf(" %s\n", strings.ToUpper(ae.Description))
+		printer.Print(reducedRepos)
+		fmt.Println()
+	}
+}
+
+// printFailedRepoTimelines prints the ordered event timeline of every repo that hit an error during the run, so that the
+// operator can see when and why each repo failed rather than only which event it was filed under
+func printFailedRepoTimelines(r *RunStats) {
+	failedRepoNames := r.GetFailedRepoNames()
+	if len(failedRepoNames) == 0 {
+		return
+	}
+	sort.Strings(failedRepoNames)
+
+	var rows []TimelineRow
+
+	for _, repoName := range failedRepoNames {
+		for _, entry := range r.GetTimeline(repoName) {
+			rows = append(rows, TimelineRow{
+				Repo:     repoName,
+				Event:    string(entry.Event),
+				Time:     entry.Timestamp.UTC().Format("15:04:05.000"),
+				Duration: entry.Duration.Round(time.Millisecond).String(),
+				Error:    entry.Error,
+			})
+		}
+	}
+
+	fmt.Println()
+	fmt.Println("*****************************************************")
+	fmt.Println("  TIMELINES OF REPOS THAT ENCOUNTERED ERRORS")
+	fmt.Println("*****************************************************")
+	timelinePrinter := tableprinter.New(os.Stdout)
+	configurePrinterStyling(timelinePrinter)
+	timelinePrinter.Print(rows)
+	fmt.Println()
+}
+
+// printFailedScriptOutputs prints the trailing lines of the output of every script that failed, along with the log file
+// holding its full output, so that the operator can see why without re-running the script against each repo
+func printFailedScriptOutputs(r *RunStats) {
+	repoNames := r.getRepoNamesWithScriptOutput()
+	sort.Strings(repoNames)
+
+	var rows []ScriptOutputRow
+
+	for _, repoName := range repoNames {
+		for _, output := range r.GetScriptOutputs(repoName) {
+			if output.Error == "" {
+				continue
+			}
+			rows = append(rows, ScriptOutputRow{
+				Repo:      repoName,
+				Script:    output.Script,
+				LogFile:   output.LogFile,
+				LastLines: tailLines(output.Output, ScriptOutputLines),
+			})
+		}
+	}
+
+	if len(rows) == 0 {
+		return
+	}
+
+	fmt.Println()
+	fmt.Println("*****************************************************")
+	fmt.Println("  OUTPUT OF SCRIPTS THAT FAILED")
+	fmt.Println("*****************************************************")
+	outputPrinter := tableprinter.New(os.Stdout)
+	configurePrinterStyling(outputPrinter)
+	outputPrinter.Print(rows)
+	fmt.Println()
+}
@@ -1,51 +1,206 @@
 package cmd
 
 import (
+	"context"
+	"fmt"
 	"sync"
 
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
 )
 
-// Loop through every repo we've selected and use a WaitGroup so that the processing can happen in parallel
-func processRepos(dryRun bool, githubClient *github.Client, repos []*github.Repository, scriptsCollection ScriptCollection, stats *RunStats) {
+// stageLimiter is a counting semaphore that caps how many repos may be inside a given processing stage at once. A nil
+// stageLimiter places no cap on its stage, so callers never need to check whether a limit was configured
+type stageLimiter chan struct{}
+
+// newStageLimiter returns a stageLimiter allowing up to limit concurrent holders, or nil (unlimited) if limit is not positive
+func newStageLimiter(limit int) stageLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return make(stageLimiter, limit)
+}
+
+// acquire blocks until a slot in the stage is free
+func (s stageLimiter) acquire() {
+	if s != nil {
+		s <- struct{}{}
+	}
+}
+
+// release frees the slot taken by a previous call to acquire
+func (s stageLimiter) release() {
+	if s != nil {
+		<-s
+	}
+}
+
+// StageLimits holds the per-stage concurrency caps shared by every repo worker. These are independent of the overall
+// --max-concurrent-repos setting, so that, for example, many repos can run scripts locally while only a handful at a
+// time are cloning, pushing or calling the Github pull requests API
+type StageLimits struct {
+	Clone       stageLimiter
+	Push        stageLimiter
+	PullRequest stageLimiter
+}
+
+// NewStageLimits builds the StageLimits for a run from the user-supplied flag values. Zero or negative values mean unlimited
+func NewStageLimits(maxClones, maxPushes, maxPullRequests int) *StageLimits {
+	return &StageLimits{
+		Clone:       newStageLimiter(maxClones),
+		Push:        newStageLimiter(maxPushes),
+		PullRequest: newStageLimiter(maxPullRequests),
+	}
+}
+
+// runWorkerPool feeds repos through a queue to a fixed number of workers, each of which calls process on one repo at a
+// time. If workerCount is not positive, one worker per repo is started. Once ctx is cancelled no further repos are
+// handed out, but repos that are already being processed are allowed to finish. The repos that were never handed to a
+// worker are returned so that they can be reported on
+func runWorkerPool(ctx context.Context, workerCount int, repos []*github.Repository, process func(*github.Repository)) []*github.Repository {
+	if workerCount <= 0 || workerCount > len(repos) {
+		workerCount = len(repos)
+	}
+
+	queue := make(chan *github.Repository)
+
 	var wg sync.WaitGroup
 
-	for _, repo := range repos {
+	for i := 0; i < workerCount; i++ {
 		wg.Add(1)
-		go func(dryRun bool, githubClient *github.Client, repo *github.Repository, scriptsCollection ScriptCollection, stats *RunStats) {
+		go func() {
 			defer wg.Done()
-			// For each repo, run all targeted scripts against it and, if they all succeed without error:
-			// commit the changes, push the local branch to remote and use the Github API to open a pr
-			processErr := processRepo(dryRun, githubClient, repo, scriptsCollection, stats)
-
-			if processErr != nil {
-				log.WithFields(logrus.Fields{
-					"Repo name": repo.GetName(),
-					"Error":     processErr,
-				}).Debug("Error encountered while processing repo")
+			for repo := range queue {
+				process(repo)
 			}
+		}()
+	}
+
+	var unprocessed []*github.Repository
 
-		}(dryRun, githubClient, repo, scriptsCollection, stats)
+dispatch:
+	for i, repo := range repos {
+		// Check for cancellation first, because select picks randomly between ready cases and we don't want to hand out
+		// another repo once the operator has asked us to stop
+		if ctx.Err() != nil {
+			unprocessed = repos[i:]
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			unprocessed = repos[i:]
+			break dispatch
+		case queue <- repo:
+		}
 	}
+
+	close(queue)
 	wg.Wait()
+
+	return unprocessed
+}
+
+// Loop through every repo we've selected and hand them to a bounded pool of workers so that the processing can happen in
+// parallel without cloning every repo at once. If the run is cancelled (e.g., via SIGINT), repos that were not yet
+// started are tracked as such so that the final report still accounts for them
+func processRepos(ctx context.Context, dryRun bool, host RepoHost, repos []*github.Repository, scriptsCollection ScriptCollection, stats *RunStats) {
+	limits := NewStageLimits(MaxConcurrentClones, MaxConcurrentPushes, MaxConcurrentPullRequests)
+
+	log.WithFields(logrus.Fields{
+		"Repo count":              len(repos),
+		"Max concurrent repos":    MaxConcurrentRepos,
+		"Max concurrent clones":   MaxConcurrentClones,
+		"Max concurrent pushes":   MaxConcurrentPushes,
+		"Max concurrent PR calls": MaxConcurrentPullRequests,
+	}).Debug("Starting repo worker pool")
+
+	unprocessed := runWorkerPool(ctx, MaxConcurrentRepos, repos, func(repo *github.Repository) {
+		// For each repo, run all targeted scripts against it and, if they all succeed without error:
+		// commit the changes, push the local branch to remote and use the Github API to open a pr
+		processErr := processRepo(dryRun, host, repo, scriptsCollection, limits, stats)
+
+		if processErr != nil {
+			log.WithFields(logrus.Fields{
+				"Repo name": repo.GetName(),
+				"Error":     processErr,
+			}).Debug("Error encountered while processing repo")
+		}
+	})
+
+	if len(unprocessed) > 0 {
+		log.WithFields(logrus.Fields{
+			"Unprocessed repo count": len(unprocessed),
+		}).Debug("Run was cancelled before all repos could be processed")
+
+		stats.TrackMultiple(RepoProcessingCancelled, unprocessed)
+	}
 }
 
-// 1. Attempt to clone it to the local filesystem. To avoid conflicts, this generates a new directory for each repo FOR EACH run, so heavy use of this tool may inflate your /tmp/ directory size
-// 2. Look up the HEAD ref of the repo, and create a new branch from that ref, specific to this tool so that we can
-// safely make our changes in the branch
-// 3. Loop through all the supplied and validated scripts, executing them against the locally cloned repo in sequence
-// 4. Look up any worktree changes (deleted files, modified files, new and untracked files) and ADD THEM ALL to the stage
-// 5. Commit these changes with the optionally configurable git commit message, or fall back to the default if it was not provided by the user
-// 6. Push the branch containing the new commit to the remote origin
-// 7. Via the Github API, open a pull request of the newly pushed branch against the main branch of the repo
-// 8. Track all successfully opened pull requests via the stats tracker so that we can print them out as part of our final
-// run report that is displayed in table format to the operator following each run
-func processRepo(dryRun bool, githubClient *github.Client, repo *github.Repository, scriptsCollection ScriptCollection, stats *RunStats) error {
+//  0. Look up whether the tool-specific branch already exists, and skip the repo if it does unless --update-existing was set
+//  1. Attempt to clone it to the local filesystem. To avoid conflicts, this generates a new directory for each repo FOR EACH run,
+//     so heavy use of this tool may inflate your /tmp/ directory size
+//  2. Look up the HEAD ref of the repo, which is the tip of its base branch, and create a new branch from that ref, specific to
+//     this tool so that we can safely make our changes in the branch
+//  3. Loop through all the supplied and validated scripts, executing them against the locally cloned repo in sequence
+//  4. Look up any worktree changes (deleted files, modified files, new and untracked files) and ADD THEM ALL to the stage.
+//     If there are no changes at all, the repo is tracked as needing no changes, its local clone is removed, and we stop here
+//  5. Commit these changes with the optionally configurable git commit message, or fall back to the default if it was not
+//     provided by the user
+//  6. Push the branch containing the new commit to the remote origin
+//  7. Via the API of the repo host, open a pull request of the newly pushed branch against the base branch of the repo
+//  8. Track all successfully opened pull requests via the stats tracker so that we can print them out as part of our final
+//     run report that is displayed in table format to the operator following each run
+//
+// The clone, push and pull request stages are each gated by the supplied StageLimits
+func processRepo(dryRun bool, host RepoHost, repo *github.Repository, scriptsCollection ScriptCollection, limits *StageLimits, stats *RunStats) error {
+
+	// Work out which branch we start from and open the pull request against: the repo's default branch, unless the operator overrode it via --base-branch
+	baseBranch := resolveBaseBranch(BaseBranch, repo)
+
+	// If the run being resumed already pushed this repo's branch, rebuilding and re-pushing it would only be rejected or
+	// create a duplicate, so all that's left to do is open the pull request
+	if stats.Journal().HasStage(repo, PushBranchSucceeded) {
+		log.WithFields(logrus.Fields{
+			"Repo":   repo.GetName(),
+			"Branch": BranchName,
+		}).Debug("Branch was already pushed by the run being resumed, skipping straight to opening the pull request")
+
+		// The changes were made by the run being resumed, so they aren't known to the templates
+		messages, renderErr := renderRepoMessages(dryRun, repo, baseBranch, scriptsCollection, nil, stats)
+		if renderErr != nil {
+			return renderErr
+		}
+
+		limits.PullRequest.acquire()
+		defer limits.PullRequest.release()
+		return openPullRequest(dryRun, host, repo, plumbing.NewBranchReferenceName(BranchName).String(), baseBranch, messages.PullRequestTitle, pullRequestBody(messages.PullRequestDescription, scriptsCollection), stats)
+	}
+
+	// Check whether a previous run already pushed the tool-specific branch. Unless the operator asked to update existing
+	// branches, there's no point cloning the repo, since pushing would only be rejected
+	branchExists, branchLookupErr := lookupTargetBranch(host, repo, stats)
+	if branchLookupErr != nil {
+		return branchLookupErr
+	}
+
+	if branchExists && !UpdateExisting {
+		existsErr := fmt.Errorf("branch %s already exists in %s - pass --update-existing to add to it and update its pull request", BranchName, repo.GetName())
+		stats.TrackError(TargetBranchExistsNotUpdated, repo, existsErr)
+		return existsErr
+	}
 
 	// Create a new temporary directory in the default temp directory of the system, but append
 	// git-xargs-<repo-name> to it so that it's easier to find when you're looking for it
-	repositoryDir, localRepository, cloneErr := cloneLocalRepository(repo, stats)
+	limits.Clone.acquire()
+	repositoryDir, localRepository, cloneErr := cloneLocalRepository(repo, baseBranch, stats)
+	limits.Clone.release()
+
+	// Whatever happens to the repo from here on, its local clone is no longer needed once processing is done
+	if repositoryDir != "" {
+		defer cleanupLocalRepository(repositoryDir, repo)
+	}
 
 	if cloneErr != nil {
 		return cloneErr
@@ -64,34 +219,74 @@ func processRepo(dryRun bool, githubClient *github.Client, repo *github.Reposito
 		return worktreeErr
 	}
 
+	// When updating an existing branch, start from its tip on the remote origin so that the scripts' changes are added on top of it
+	if branchExists {
+		ref, headRefErr = getRemoteBranchRef(localRepository, repo, stats)
+		if headRefErr != nil {
+			return headRefErr
+		}
+	}
+
 	// Create a branch in the locally cloned copy of the repo to hold all the changes that may result from script execution
-	branchName, branchErr := checkoutLocalBranch(ref, worktree, repo, localRepository, stats)
+	branchName, branchErr := checkoutLocalBranch(ref, baseBranch, worktree, repo, localRepository, stats)
 	if branchErr != nil {
 		return branchErr
 	}
 
 	// At this point, the repo has been successfully cloned, a fresh branch has been checked out, and it is ready to have the target scripts run against it
-	scriptsErr := runAllTargetedScripts(repositoryDir, scriptsCollection, repo, worktree, stats)
+	status, scriptsErr := runAllTargetedScripts(dryRun, repositoryDir, baseBranch, scriptsCollection, repo, worktree, stats)
 	if scriptsErr != nil {
 		return scriptsErr
 	}
 
 	// All scripts have now been run against the local clone of the repository in the tmp directory
 
+	// If the scripts didn't change anything, or every change was left out by --include or --exclude, there is nothing to
+	// commit, push or open a pull request for. Rather than creating an empty commit and a noisy API error, record that
+	// this repo needed no changes
+	if status.IsClean() {
+		log.WithFields(logrus.Fields{
+			"Repo": repo.GetName(),
+		}).Debug("Scripts made no changes, skipping commit, branch push and pull request")
+
+		stats.TrackSingle(NoChangesRequired, repo)
+		return nil
+	}
+
+	// Render the commit message and pull request title and description for this repo, now that its changes are known
+	messages, renderErr := renderRepoMessages(dryRun, repo, baseBranch, scriptsCollection, changedFilePaths(status), stats)
+	if renderErr != nil {
+		return renderErr
+	}
+
+	// The pull request body records any inline commands that were run, so that reviewers can see exactly how the changes were made
+	prBody := pullRequestBody(messages.PullRequestDescription, scriptsCollection)
+
+	// Show reviewers what the scripts reported, tucked away in a collapsible section, if the operator asked for it
+	if IncludeScriptOutputInPR {
+		if details := scriptOutputDetails(stats.GetScriptOutputs(journalKey(repo)), ScriptOutputLines); details != "" {
+			prBody = fmt.Sprintf("%s\n\n%s", prBody, details)
+		}
+	}
+
 	// Commit any untracked files, modified or deleted files that resulted from script execution
-	commitErr := commitLocalChanges(worktree, repo, localRepository, stats)
+	commitErr := commitLocalChanges(messages.CommitMessage, worktree, repo, localRepository, stats)
 	if commitErr != nil {
 		return commitErr
 	}
 
 	// Push the local branch containing all of our changes from executing the target scripts
+	limits.Push.acquire()
 	pushBranchErr := pushLocalBranch(dryRun, repo, localRepository, stats)
+	limits.Push.release()
 	if pushBranchErr != nil {
 		return pushBranchErr
 	}
 
-	// Open a pull request on Github, of the recently pushed branch against master
-	openPullRequestErr := openPullRequest(dryRun, githubClient, repo, branchName.String(), stats)
+	// Open a pull request on Github, of the recently pushed branch against the base branch
+	limits.PullRequest.acquire()
+	openPullRequestErr := openPullRequest(dryRun, host, repo, branchName.String(), baseBranch, messages.PullRequestTitle, prBody, stats)
+	limits.PullRequest.release()
 	if openPullRequestErr != nil {
 		return openPullRequestErr
 	}
@@ -0,0 +1,91 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func makeTestRepos(count int) []*github.Repository {
+	var repos []*github.Repository
+	for i := 0; i < count; i++ {
+		repos = append(repos, &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", i))})
+	}
+	return repos
+}
+
+func TestRunWorkerPoolProcessesEveryRepoWithinConcurrencyLimit(t *testing.T) {
+	repos := makeTestRepos(20)
+
+	var mu sync.Mutex
+	seen := make(map[string]bool)
+	inFlight, maxInFlight := 0, 0
+
+	unprocessed := runWorkerPool(context.Background(), 3, repos, func(repo *github.Repository) {
+		mu.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		seen[repo.GetName()] = true
+		mu.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mu.Lock()
+		inFlight--
+		mu.Unlock()
+	})
+
+	assert.Empty(t, unprocessed)
+	assert.Equal(t, len(seen), 20)
+	assert.True(t, maxInFlight <= 3)
+}
+
+func TestRunWorkerPoolStopsDispatchingOnceCancelled(t *testing.T) {
+	repos := makeTestRepos(10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var mu sync.Mutex
+	processedCount := 0
+
+	unprocessed := runWorkerPool(ctx, 1, repos, func(repo *github.Repository) {
+		mu.Lock()
+		processedCount++
+		mu.Unlock()
+		// Cancel as soon as the first repo is picked up, simulating an operator hitting Ctrl+C mid-run
+		cancel()
+	})
+
+	assert.Equal(t, processedCount, 1)
+	assert.Equal(t, len(unprocessed), 9)
+	assert.Equal(t, unprocessed[0].GetName(), "repo-1")
+}
+
+func TestStageLimiterCapsConcurrentHolders(t *testing.T) {
+	limiter := newStageLimiter(2)
+
+	limiter.acquire()
+	limiter.acquire()
+
+	acquired := make(chan struct{})
+	go func() {
+		limiter.acquire()
+		close(acquired)
+	}()
+
+	select {
+	case <-acquired:
+		t.Fatal("third acquire should block while two slots are held")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	limiter.release()
+	<-acquired
+}
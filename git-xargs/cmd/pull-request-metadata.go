@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// applyPullRequestMetadata requests the reviewers and team reviewers, and adds the labels, assignees and milestone passed
+// for this run to the pull request. Each is applied independently and tracked as its own event if it fails, so that, for
+// example, a missing label neither stops the reviewers from being requested nor hides the pull request that was opened
+func applyPullRequestMetadata(githubClient *github.Client, repo *github.Repository, pr *github.PullRequest, stats *RunStats) {
+	owner := repo.GetOwner().GetLogin()
+	number := pr.GetNumber()
+
+	if len(PullRequestReviewers) > 0 || len(PullRequestTeamReviewers) > 0 {
+		reviewers := github.ReviewersRequest{
+			Reviewers:     PullRequestReviewers,
+			TeamReviewers: PullRequestTeamReviewers,
+		}
+		if _, _, err := githubClient.PullRequests.RequestReviewers(context.Background(), owner, repo.GetName(), number, reviewers); err != nil {
+			trackPullRequestMetadataErr(PullRequestReviewersErr, repo, pr, err, stats)
+		}
+	}
+
+	if len(PullRequestLabels) > 0 {
+		if _, _, err := githubClient.Issues.AddLabelsToIssue(context.Background(), owner, repo.GetName(), number, PullRequestLabels); err != nil {
+			trackPullRequestMetadataErr(PullRequestLabelsErr, repo, pr, err, stats)
+		}
+	}
+
+	if len(PullRequestAssignees) > 0 {
+		if _, _, err := githubClient.Issues.AddAssignees(context.Background(), owner, repo.GetName(), number, PullRequestAssignees); err != nil {
+			trackPullRequestMetadataErr(PullRequestAssigneesErr, repo, pr, err, stats)
+		}
+	}
+
+	if PullRequestMilestone != "" {
+		milestoneNumber, lookupErr := lookupMilestoneNumber(githubClient, repo, PullRequestMilestone)
+		if lookupErr != nil {
+			trackPullRequestMetadataErr(PullRequestMilestoneErr, repo, pr, lookupErr, stats)
+			return
+		}
+
+		edit := &github.IssueRequest{Milestone: github.Int(milestoneNumber)}
+		if _, _, err := githubClient.Issues.Edit(context.Background(), owner, repo.GetName(), number, edit); err != nil {
+			trackPullRequestMetadataErr(PullRequestMilestoneErr, repo, pr, err, stats)
+		}
+	}
+}
+
+// lookupMilestoneNumber returns the number of the repo's open milestone with the supplied title. Since milestones are
+// numbered per repo, a plain number is used as-is, for campaigns against a single repo
+func lookupMilestoneNumber(githubClient *github.Client, repo *github.Repository, milestone string) (int, error) {
+	if number, err := strconv.Atoi(milestone); err == nil {
+		return number, nil
+	}
+
+	opts := &github.MilestoneListOptions{
+		State:       "open",
+		ListOptions: github.ListOptions{PerPage: 100},
+	}
+
+	for {
+		milestones, resp, err := githubClient.Issues.ListMilestones(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), opts)
+		if err != nil {
+			return 0, err
+		}
+
+		for _, m := range milestones {
+			if m.GetTitle() == milestone {
+				return m.GetNumber(), nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	return 0, fmt.Errorf("repo %s has no open milestone titled %q", repo.GetName(), milestone)
+}
+
+// trackPullRequestMetadataErr logs and tracks the failure to apply a piece of metadata to the pull request
+func trackPullRequestMetadataErr(event Event, repo *github.Repository, pr *github.PullRequest, err error, stats *RunStats) {
+	log.WithFields(logrus.Fields{
+		"Error":            err,
+		"Event":            event,
+		"Pull Request URL": pr.GetHTMLURL(),
+	}).Debug("Error applying metadata to pull request")
+
+	stats.TrackError(event, repo, err)
+}
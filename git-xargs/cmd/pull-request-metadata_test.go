@@ -0,0 +1,98 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setPullRequestMetadataFlags sets the pull request metadata flags, returning a function that restores their previous values
+func setPullRequestMetadataFlags(reviewers, teamReviewers, labels, assignees []string, draft bool, milestone string) func() {
+	origReviewers, origTeamReviewers, origLabels, origAssignees := PullRequestReviewers, PullRequestTeamReviewers, PullRequestLabels, PullRequestAssignees
+	origDraft, origMilestone := DraftPullRequest, PullRequestMilestone
+
+	PullRequestReviewers, PullRequestTeamReviewers, PullRequestLabels, PullRequestAssignees = reviewers, teamReviewers, labels, assignees
+	DraftPullRequest, PullRequestMilestone = draft, milestone
+
+	return func() {
+		PullRequestReviewers, PullRequestTeamReviewers, PullRequestLabels, PullRequestAssignees = origReviewers, origTeamReviewers, origLabels, origAssignees
+		DraftPullRequest, PullRequestMilestone = origDraft, origMilestone
+	}
+}
+
+func TestOpenPullRequestAppliesMetadataAndTracksEachFailureSeparately(t *testing.T) {
+	defer setPullRequestMetadataFlags([]string{"alice"}, []string{"platform"}, []string{"chore"}, []string{"bob"}, true, "Q3 cleanup")()
+
+	var created github.NewPullRequest
+	var requestedReviewers github.ReviewersRequest
+	var assignees struct {
+		Assignees []string `json:"assignees"`
+	}
+	var edited github.IssueRequest
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&created))
+		fmt.Fprint(w, `{"number": 7, "html_url": "https://github.com/gruntwork-io/cloud-nuke/pull/7"}`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7/requested_reviewers", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&requestedReviewers))
+		fmt.Fprint(w, `{"number": 7}`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/issues/7/labels", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Validation Failed"}`, http.StatusUnprocessableEntity)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/issues/7/assignees", func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&assignees))
+		fmt.Fprint(w, `{"number": 7}`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/milestones", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"number": 2, "title": "Q2 cleanup"}, {"number": 3, "title": "Q3 cleanup"}]`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/issues/7", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPatch)
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&edited))
+		fmt.Fprint(w, `{"number": 7}`)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	err := openPullRequest(false, &githubHost{client: client}, makeTestRepo(), "refs/heads/git-xargs", "master", "title", "body", stats)
+
+	require.NoError(t, err)
+	assert.True(t, created.GetDraft())
+	assert.Equal(t, requestedReviewers.Reviewers, []string{"alice"})
+	assert.Equal(t, requestedReviewers.TeamReviewers, []string{"platform"})
+	assert.Equal(t, assignees.Assignees, []string{"bob"})
+	assert.Equal(t, edited.GetMilestone(), 3)
+
+	// The label failure is tracked on its own, without hiding the pull request that was opened
+	assert.Equal(t, stats.GetPullRequests()["cloud-nuke"], "https://github.com/gruntwork-io/cloud-nuke/pull/7")
+	assert.Equal(t, len(stats.GetMultiple(PullRequestLabelsErr)), 1)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestReviewersErr)), 0)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestAssigneesErr)), 0)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestMilestoneErr)), 0)
+}
+
+func TestLookupMilestoneNumberTracksMissingMilestone(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/milestones", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `[{"number": 2, "title": "Q2 cleanup"}]`)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	_, err := lookupMilestoneNumber(client, makeTestRepo(), "Q3 cleanup")
+	assert.Error(t, err)
+
+	// Milestone numbers are used as-is, without looking them up
+	number, err := lookupMilestoneNumber(client, makeTestRepo(), "5")
+	assert.NoError(t, err)
+	assert.Equal(t, number, 5)
+}
@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"context"
+
+	"github.com/google/go-github/v32/github"
+)
+
+const (
+	// ChecksSuccess means every commit status and check run on the head of the pull request passed, or there are none
+	ChecksSuccess = "success"
+	// ChecksPending means at least one commit status or check run has not finished yet, and none have failed
+	ChecksPending = "pending"
+	// ChecksFailure means at least one commit status or check run failed
+	ChecksFailure = "failure"
+
+	// ReviewApproved means at least one reviewer approved the pull request, and none requested changes
+	ReviewApproved = "approved"
+	// ReviewChangesRequested means at least one reviewer's latest review requested changes
+	ReviewChangesRequested = "changes-requested"
+	// ReviewRequired means nobody has approved the pull request yet
+	ReviewRequired = "review-required"
+
+	// MergeableClean means Github found the pull request can be merged without conflicts
+	MergeableClean = "mergeable"
+	// MergeableConflicting means the pull request has conflicts with its base branch
+	MergeableConflicting = "conflicting"
+	// MergeableUnknown means Github has not finished working out whether the pull request can be merged
+	MergeableUnknown = "unknown"
+)
+
+// PullRequestState is a snapshot of where a pull request stands: whether it's still open, whether its CI passed,
+// whether it was approved and whether it can be merged
+type PullRequestState struct {
+	PullRequest *github.PullRequest
+	// State is one of open, closed or merged
+	State     string
+	Checks    string
+	Review    string
+	Mergeable string
+}
+
+// getPullRequestState looks up the current state of the pull request, its checks and its reviews via the Github API
+func getPullRequestState(githubClient *github.Client, pr CampaignPullRequest) (*PullRequestState, error) {
+	ghPR, _, err := githubClient.PullRequests.Get(context.Background(), pr.Owner, pr.Repo, pr.Number)
+	if err != nil {
+		return nil, err
+	}
+
+	state := &PullRequestState{
+		PullRequest: ghPR,
+		State:       ghPR.GetState(),
+		Mergeable:   MergeableUnknown,
+	}
+	if ghPR.GetMerged() {
+		state.State = "merged"
+	}
+
+	if ghPR.Mergeable != nil {
+		state.Mergeable = MergeableConflicting
+		if ghPR.GetMergeable() {
+			state.Mergeable = MergeableClean
+		}
+	}
+
+	if state.Checks, err = getChecksState(githubClient, pr, ghPR.GetHead().GetSHA()); err != nil {
+		return nil, err
+	}
+
+	if state.Review, err = getReviewState(githubClient, pr); err != nil {
+		return nil, err
+	}
+
+	return state, nil
+}
+
+// getChecksState combines the commit statuses and the check runs of the commit into a single state
+func getChecksState(githubClient *github.Client, pr CampaignPullRequest, sha string) (string, error) {
+	pending := false
+
+	combined, _, err := githubClient.Repositories.GetCombinedStatus(context.Background(), pr.Owner, pr.Repo, sha, &github.ListOptions{PerPage: 100})
+	if err != nil {
+		return "", err
+	}
+
+	// A commit without any statuses is reported as pending, even though nothing is left to wait for
+	if combined.GetTotalCount() > 0 {
+		switch combined.GetState() {
+		case "success":
+		case "pending":
+			pending = true
+		default:
+			return ChecksFailure, nil
+		}
+	}
+
+	opts := &github.ListCheckRunsOptions{ListOptions: github.ListOptions{PerPage: 100}}
+	for {
+		checkRuns, resp, err := githubClient.Checks.ListCheckRunsForRef(context.Background(), pr.Owner, pr.Repo, sha, opts)
+		if err != nil {
+			return "", err
+		}
+
+		for _, checkRun := range checkRuns.CheckRuns {
+			if checkRun.GetStatus() != "completed" {
+				pending = true
+				continue
+			}
+
+			switch checkRun.GetConclusion() {
+			case "success", "neutral", "skipped":
+			default:
+				return ChecksFailure, nil
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	if pending {
+		return ChecksPending, nil
+	}
+	return ChecksSuccess, nil
+}
+
+// getReviewState works out whether the pull request was approved, based on the latest review left by each reviewer
+func getReviewState(githubClient *github.Client, pr CampaignPullRequest) (string, error) {
+	latestByReviewer := make(map[string]string)
+
+	opts := &github.ListOptions{PerPage: 100}
+	for {
+		reviews, resp, err := githubClient.PullRequests.ListReviews(context.Background(), pr.Owner, pr.Repo, pr.Number, opts)
+		if err != nil {
+			return "", err
+		}
+
+		// Reviews are listed oldest first, so later reviews by the same reviewer replace earlier ones. Comments neither
+		// approve nor block, so they don't replace an earlier approval or request for changes
+		for _, review := range reviews {
+			switch review.GetState() {
+			case "APPROVED", "CHANGES_REQUESTED", "DISMISSED":
+				latestByReviewer[review.GetUser().GetLogin()] = review.GetState()
+			}
+		}
+
+		if resp.NextPage == 0 {
+			break
+		}
+		opts.Page = resp.NextPage
+	}
+
+	approved := false
+	for _, reviewState := range latestByReviewer {
+		switch reviewState {
+		case "CHANGES_REQUESTED":
+			return ReviewChangesRequested, nil
+		case "APPROVED":
+			approved = true
+		}
+	}
+
+	if approved {
+		return ReviewApproved, nil
+	}
+	return ReviewRequired, nil
+}
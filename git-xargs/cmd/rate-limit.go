@@ -0,0 +1,320 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// maxRetryBackoff caps the exponential backoff between retries of a call that failed with a server error
+	maxRetryBackoff = time.Minute
+	// rateLimitQuotaLogInterval is how often, in calls, the remaining Github API quota is logged while it's plentiful
+	rateLimitQuotaLogInterval = 100
+)
+
+// APICallCounts are the counts of Github API calls that did not succeed at the first attempt, for the run report
+type APICallCounts struct {
+	// Retried is the number of calls that were retried at least once
+	Retried int `json:"retried"`
+	// RateLimitPauses is the number of times a call waited for the rate limit to reset, or for as long as Github asked
+	RateLimitPauses int `json:"rate_limit_pauses"`
+	// PausedSeconds is the total time spent waiting on rate limits and backing off
+	PausedSeconds float64 `json:"paused_seconds"`
+	// Exhausted is the number of calls that still failed once they ran out of retries, or whose rate limit would not
+	// reset within --max-rate-limit-wait
+	Exhausted int `json:"exhausted"`
+}
+
+// APICallStats tallies the APICallCounts of every Github API call made during the run. It is safe for concurrent use
+type APICallStats struct {
+	mu     sync.Mutex
+	counts APICallCounts
+}
+
+// Counts returns a copy of the counts tallied so far
+func (s *APICallStats) Counts() APICallCounts {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.counts
+}
+
+func (s *APICallStats) trackRetried() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts.Retried++
+}
+
+func (s *APICallStats) trackPause(d time.Duration, rateLimited bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if rateLimited {
+		s.counts.RateLimitPauses++
+	}
+	s.counts.PausedSeconds += d.Seconds()
+}
+
+func (s *APICallStats) trackExhausted() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counts.Exhausted++
+}
+
+// repoHostAPICallStats tallies the retries of every call made by the clients configured via ConfigureGithubClient and
+// ConfigureGitlabHost
+var repoHostAPICallStats = &APICallStats{}
+
+// idempotentMethods are the methods of the calls that are safe to retry after a server error or network error, since
+// making them twice has the same effect as making them once. A POST that failed that way may still have created a pull
+// request or comment, so retrying it could create a duplicate
+var idempotentMethods = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodHead:   true,
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+	http.MethodPatch:  true,
+}
+
+// rateLimitTransport is an http.RoundTripper that makes Github API calls rate-limit aware. When Github reports that the
+// rate limit is used up, the call is paused until the limit resets. When Github asks for a secondary (abuse) rate limit
+// to be respected, the call is paused for as long as Github asks, or backed off exponentially if it doesn't say. Server
+// errors and network errors are retried with exponential backoff, but only for idempotent calls
+type rateLimitTransport struct {
+	base http.RoundTripper
+	// runCtx is the context of the run, which is cancelled when the operator hits Ctrl+C, so that a call stops waiting
+	// on a rate limit or backoff straight away, rather than holding up the end of the run
+	runCtx context.Context
+	// maxRetries is the number of times a call is retried before its last response is returned as is
+	maxRetries int
+	// maxRateLimitWait is the longest a call may wait for the rate limit to reset before it's given up on
+	maxRateLimitWait time.Duration
+	// baseBackoff is the backoff before the first retry, which doubles with every retry after that
+	baseBackoff time.Duration
+	stats       *APICallStats
+	// sleep waits for the supplied duration, returning early with an error if the context is cancelled
+	sleep func(ctx context.Context, d time.Duration) error
+
+	mu    sync.Mutex
+	calls int
+}
+
+// newRateLimitTransport wraps base, which makes the actual calls, in a rateLimitTransport that tallies into stats and
+// stops waiting once the run's context is cancelled
+func newRateLimitTransport(runCtx context.Context, base http.RoundTripper, maxRetries int, maxRateLimitWait time.Duration, stats *APICallStats) *rateLimitTransport {
+	return &rateLimitTransport{
+		base:             base,
+		runCtx:           runCtx,
+		maxRetries:       maxRetries,
+		maxRateLimitWait: maxRateLimitWait,
+		baseBackoff:      time.Second,
+		stats:            stats,
+		sleep:            sleepContext,
+	}
+}
+
+// sleepContext waits for the supplied duration, or until the context is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// RoundTrip makes the call, retrying it for as long as it's rate limited or failing with a server error and retries
+// remain. Calls whose body can't be replayed are never retried
+func (t *rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	retryable := req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+
+	for attempt := 0; ; attempt++ {
+		attemptReq := req
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			attemptReq = req.Clone(req.Context())
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err == nil {
+			t.logQuota(resp)
+		}
+
+		wait, rateLimited, retry := t.retryDelay(req.Method, resp, err, attempt)
+		if !retry || !retryable || req.Context().Err() != nil || t.runCtx.Err() != nil {
+			return resp, err
+		}
+
+		if attempt >= t.maxRetries || (rateLimited && wait > t.maxRateLimitWait) {
+			log.WithFields(logrus.Fields{
+				"URL":      req.URL.String(),
+				"Attempts": attempt + 1,
+				"Wait":     wait,
+			}).Debug("Giving up on Github API call that is still rate limited or failing")
+
+			t.stats.trackExhausted()
+			return resp, err
+		}
+
+		if attempt == 0 {
+			t.stats.trackRetried()
+		}
+
+		log.WithFields(logrus.Fields{
+			"URL":          req.URL.String(),
+			"Attempt":      attempt + 1,
+			"Wait":         wait,
+			"Rate limited": rateLimited,
+		}).Debug("Github API call was rate limited or failed, retrying after waiting")
+
+		if resp != nil {
+			// The body is drained so that the connection can be reused by the retry
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		t.stats.trackPause(wait, rateLimited)
+		if sleepErr := t.sleepUntilDone(req.Context(), wait); sleepErr != nil {
+			return nil, sleepErr
+		}
+	}
+}
+
+// sleepUntilDone waits for the supplied duration, returning early with an error if either the call's context or the
+// run's context is cancelled
+func (t *rateLimitTransport) sleepUntilDone(reqCtx context.Context, d time.Duration) error {
+	ctx, cancel := context.WithCancel(reqCtx)
+	defer cancel()
+
+	go func() {
+		select {
+		case <-t.runCtx.Done():
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	if err := t.sleep(ctx, d); err != nil {
+		if runErr := t.runCtx.Err(); runErr != nil {
+			return runErr
+		}
+		return err
+	}
+	return nil
+}
+
+// retryDelay works out whether the call should be retried, how long to wait before retrying it and whether that wait
+// is due to a rate limit. Calls of any method are retried once Github says they were rate limited, since Github didn't
+// act on them, but only idempotent calls are retried after a server error or network error
+func (t *rateLimitTransport) retryDelay(method string, resp *http.Response, err error, attempt int) (time.Duration, bool, bool) {
+	idempotent := idempotentMethods[method]
+
+	if err != nil {
+		if !idempotent || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			return 0, false, false
+		}
+		return t.backoff(attempt), false, true
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusForbidden || resp.StatusCode == http.StatusTooManyRequests:
+		// The primary rate limit is used up, so nothing will succeed until it resets
+		if resp.Header.Get("X-RateLimit-Remaining") == "0" {
+			if reset, parseErr := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); parseErr == nil {
+				wait := time.Until(time.Unix(reset, 0)) + time.Second
+				if wait < time.Second {
+					wait = time.Second
+				}
+				return wait, true, true
+			}
+		}
+
+		// Secondary rate limits say how long to wait via Retry-After
+		if retryAfter, parseErr := strconv.Atoi(resp.Header.Get("Retry-After")); parseErr == nil {
+			return time.Duration(retryAfter) * time.Second, true, true
+		}
+
+		// Older secondary rate limit responses only say so in their body, which isn't explicit enough to risk retrying a
+		// call that isn't idempotent
+		if idempotent && isSecondaryRateLimitResponse(resp) {
+			return t.backoff(attempt), true, true
+		}
+
+		return 0, false, false
+	case resp.StatusCode >= 500 && idempotent:
+		return t.backoff(attempt), false, true
+	default:
+		return 0, false, false
+	}
+}
+
+// isSecondaryRateLimitResponse peeks at the body of a 403 response to see whether it's a secondary (abuse) rate limit
+// rather than a permissions error. The body is restored so that the response can still be read by the caller
+func isSecondaryRateLimitResponse(resp *http.Response) bool {
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return false
+	}
+
+	message := strings.ToLower(string(body))
+	return strings.Contains(message, "secondary rate limit") || strings.Contains(message, "abuse")
+}
+
+// backoff returns the exponential backoff before the supplied retry
+func (t *rateLimitTransport) backoff(attempt int) time.Duration {
+	backoff := t.baseBackoff << uint(attempt)
+	if backoff <= 0 || backoff > maxRetryBackoff {
+		return maxRetryBackoff
+	}
+	return backoff
+}
+
+// logQuota logs the remaining Github API quota every rateLimitQuotaLogInterval calls, and on every call once less than
+// a tenth of the quota remains
+func (t *rateLimitTransport) logQuota(resp *http.Response) {
+	remaining, remainingErr := strconv.Atoi(resp.Header.Get("X-RateLimit-Remaining"))
+	limit, limitErr := strconv.Atoi(resp.Header.Get("X-RateLimit-Limit"))
+	if remainingErr != nil || limitErr != nil {
+		return
+	}
+
+	t.mu.Lock()
+	t.calls++
+	calls := t.calls
+	t.mu.Unlock()
+
+	if calls%rateLimitQuotaLogInterval != 1 && remaining >= limit/10 {
+		return
+	}
+
+	fields := logrus.Fields{
+		"Remaining": remaining,
+		"Limit":     limit,
+		"Resource":  resp.Header.Get("X-RateLimit-Resource"),
+	}
+	if reset, err := strconv.ParseInt(resp.Header.Get("X-RateLimit-Reset"), 10, 64); err == nil {
+		fields["Resets at"] = time.Unix(reset, 0).UTC()
+	}
+	log.WithFields(fields).Debug("Github API quota")
+}
@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestRateLimitTransport returns a rateLimitTransport that records how long it was asked to sleep rather than sleeping
+func newTestRateLimitTransport(maxRetries int, maxRateLimitWait time.Duration) (*rateLimitTransport, *[]time.Duration) {
+	var sleeps []time.Duration
+	transport := newRateLimitTransport(context.Background(), http.DefaultTransport, maxRetries, maxRateLimitWait, &APICallStats{})
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+	return transport, &sleeps
+}
+
+// serveResponses serves the supplied handlers in turn, one per call, recording the body of each call
+func serveResponses(t *testing.T, handlers ...http.HandlerFunc) (*httptest.Server, *[]string) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := ioutil.ReadAll(r.Body)
+		require.NoError(t, err)
+		bodies = append(bodies, string(body))
+
+		require.True(t, len(bodies) <= len(handlers), "Unexpected call %d", len(bodies))
+		handlers[len(bodies)-1](w, r)
+	}))
+	return server, &bodies
+}
+
+func respondWith(status int, headers map[string]string, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		for name, value := range headers {
+			w.Header().Set(name, value)
+		}
+		w.WriteHeader(status)
+		fmt.Fprint(w, body)
+	}
+}
+
+func TestRateLimitTransportRetriesServerErrorsWithBackoff(t *testing.T) {
+	server, bodies := serveResponses(t,
+		respondWith(http.StatusBadGateway, nil, ""),
+		respondWith(http.StatusServiceUnavailable, nil, ""),
+		respondWith(http.StatusOK, nil, `{"number": 7}`),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, time.Hour)
+	client := &http.Client{Transport: transport}
+
+	req, err := http.NewRequest(http.MethodPatch, server.URL, strings.NewReader(`{"body": "git-xargs"}`))
+	require.NoError(t, err)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, *bodies, []string{`{"body": "git-xargs"}`, `{"body": "git-xargs"}`, `{"body": "git-xargs"}`})
+	assert.Equal(t, *sleeps, []time.Duration{time.Second, 2 * time.Second})
+	assert.Equal(t, transport.stats.Counts(), APICallCounts{Retried: 1, PausedSeconds: 3})
+}
+
+func TestRateLimitTransportDoesNotRetryPostAfterServerError(t *testing.T) {
+	server, bodies := serveResponses(t,
+		respondWith(http.StatusBadGateway, nil, ""),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, time.Hour)
+
+	// The pull request may well have been created before the gateway timed out, so retrying could open a duplicate
+	resp, err := (&http.Client{Transport: transport}).Post(server.URL, "application/json", strings.NewReader(`{"title": "git-xargs"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusBadGateway)
+	assert.Equal(t, len(*bodies), 1)
+	assert.Empty(t, *sleeps)
+	assert.Equal(t, transport.stats.Counts(), APICallCounts{})
+}
+
+func TestRateLimitTransportRetriesRateLimitedPost(t *testing.T) {
+	server, bodies := serveResponses(t,
+		respondWith(http.StatusForbidden, map[string]string{"Retry-After": "30"}, `{"message": "You have exceeded a secondary rate limit"}`),
+		respondWith(http.StatusCreated, nil, `{"number": 7}`),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, time.Hour)
+
+	resp, err := (&http.Client{Transport: transport}).Post(server.URL, "application/json", strings.NewReader(`{"title": "git-xargs"}`))
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusCreated)
+	assert.Equal(t, *bodies, []string{`{"title": "git-xargs"}`, `{"title": "git-xargs"}`})
+	assert.Equal(t, *sleeps, []time.Duration{30 * time.Second})
+}
+
+func TestRateLimitTransportStopsWaitingWhenRunIsCancelled(t *testing.T) {
+	server, bodies := serveResponses(t,
+		respondWith(http.StatusForbidden, map[string]string{"Retry-After": "3600"}, `{"message": "You have exceeded a secondary rate limit"}`),
+	)
+	defer server.Close()
+
+	runCtx, cancel := context.WithCancel(context.Background())
+	transport := newRateLimitTransport(runCtx, http.DefaultTransport, 5, 2*time.Hour, &APICallStats{})
+
+	// The call itself is made with a context that is never cancelled, as the API calls of the run are
+	time.AfterFunc(100*time.Millisecond, cancel)
+	start := time.Now()
+	_, err := (&http.Client{Transport: transport}).Get(server.URL)
+
+	assert.True(t, errors.Is(err, context.Canceled))
+	assert.True(t, time.Since(start) < 10*time.Second)
+	assert.Equal(t, len(*bodies), 1)
+}
+
+func TestRateLimitTransportWaitsForPrimaryRateLimitToReset(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(30*time.Second).Unix(), 10)
+	server, _ := serveResponses(t,
+		respondWith(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Limit": "5000", "X-RateLimit-Reset": reset}, `{"message": "API rate limit exceeded"}`),
+		respondWith(http.StatusOK, nil, `{}`),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, time.Hour)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	require.Equal(t, len(*sleeps), 1)
+	assert.InDelta(t, (*sleeps)[0].Seconds(), 31, 2)
+	assert.Equal(t, transport.stats.Counts().RateLimitPauses, 1)
+}
+
+func TestRateLimitTransportRespectsRetryAfter(t *testing.T) {
+	server, _ := serveResponses(t,
+		respondWith(http.StatusForbidden, map[string]string{"Retry-After": "60"}, `{"message": "You have exceeded a secondary rate limit"}`),
+		respondWith(http.StatusOK, nil, `{}`),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, time.Hour)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusOK)
+	assert.Equal(t, *sleeps, []time.Duration{time.Minute})
+}
+
+func TestRateLimitTransportGivesUpOnceRetriesRunOut(t *testing.T) {
+	server, bodies := serveResponses(t,
+		respondWith(http.StatusInternalServerError, nil, ""),
+		respondWith(http.StatusInternalServerError, nil, ""),
+		respondWith(http.StatusInternalServerError, nil, `{"message": "still broken"}`),
+	)
+	defer server.Close()
+
+	transport, _ := newTestRateLimitTransport(2, time.Hour)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusInternalServerError)
+	assert.Equal(t, string(body), `{"message": "still broken"}`)
+	assert.Equal(t, len(*bodies), 3)
+	assert.Equal(t, transport.stats.Counts().Exhausted, 1)
+}
+
+func TestRateLimitTransportGivesUpWhenResetIsTooFarAway(t *testing.T) {
+	reset := strconv.FormatInt(time.Now().Add(time.Hour).Unix(), 10)
+	server, _ := serveResponses(t,
+		respondWith(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "0", "X-RateLimit-Reset": reset}, ""),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, 10*time.Minute)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, resp.StatusCode, http.StatusForbidden)
+	assert.Empty(t, *sleeps)
+	assert.Equal(t, transport.stats.Counts().Exhausted, 1)
+}
+
+func TestRateLimitTransportDoesNotRetryPermissionErrors(t *testing.T) {
+	server, _ := serveResponses(t,
+		respondWith(http.StatusForbidden, map[string]string{"X-RateLimit-Remaining": "4999"}, `{"message": "Resource not accessible by integration"}`),
+	)
+	defer server.Close()
+
+	transport, sleeps := newTestRateLimitTransport(5, time.Hour)
+
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, resp.StatusCode, http.StatusForbidden)
+	assert.Equal(t, string(body), `{"message": "Resource not accessible by integration"}`)
+	assert.Empty(t, *sleeps)
+	assert.Equal(t, transport.stats.Counts(), APICallCounts{})
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/go-github/v32/github"
+)
+
+const (
+	// RepoHostGithub selects Github, or Github Enterprise, as the host of the repos operated on
+	RepoHostGithub = "github"
+	// RepoHostGitlab selects GitLab, either gitlab.com or a self-hosted instance, as the host of the repos operated on
+	RepoHostGitlab = "gitlab"
+)
+
+// ErrRepoNotFound is returned by a RepoHost when the repo it was asked for doesn't exist, or isn't visible with the
+// credentials in use
+var ErrRepoNotFound = errors.New("repo not found")
+
+// RepoHost is the service hosting the repos a run operates on. It's how repos are looked up and listed, and how pull
+// requests, which GitLab calls merge requests, are opened and updated
+//
+// Whichever host is in use, repos and pull requests are represented by the go-github types, so that the scripts, the
+// run-state journal and the run report all work the same way regardless of where the repos live
+type RepoHost interface {
+	// GetRepo looks up a single repo by its owner and name, returning ErrRepoNotFound if it doesn't exist
+	GetRepo(owner, name string) (*github.Repository, error)
+	// ListOrgRepos returns every repo of the organization, or GitLab group
+	ListOrgRepos(org string) ([]*github.Repository, error)
+	// ListUserRepos returns every repo owned by the personal account
+	ListUserRepos(user string) ([]*github.Repository, error)
+	// SearchRepos returns every repo matching the host's repo search query
+	SearchRepos(query string) ([]*github.Repository, error)
+	// BranchExists returns true if the branch exists in the remote repo
+	BranchExists(repo *github.Repository, branch string) (bool, error)
+	// CreatePullRequest opens a pull request of the head branch against the base branch of the repo
+	CreatePullRequest(repo *github.Repository, newPR *github.NewPullRequest) (*github.PullRequest, error)
+	// FindOpenPullRequest returns the open pull request of the head branch against the base branch, or nil if there isn't one
+	FindOpenPullRequest(repo *github.Repository, head, baseBranch string) (*github.PullRequest, error)
+	// EditPullRequest replaces the title and body of the pull request
+	EditPullRequest(repo *github.Repository, pr *github.PullRequest, title, body string) (*github.PullRequest, error)
+	// ApplyPullRequestMetadata adds the reviewers, labels, assignees and milestone passed for this run to the pull
+	// request, tracking each one that can't be applied as its own event
+	ApplyPullRequestMetadata(repo *github.Repository, pr *github.PullRequest, stats *RunStats)
+}
+
+// isValidRepoHost returns true if the supplied value is one of the repo hosts git-xargs supports
+func isValidRepoHost(host string) bool {
+	return host == RepoHostGithub || host == RepoHostGitlab
+}
+
+// ConfigureRepoHost returns the host selected via --repo-host, configured with its credentials. The same credentials
+// are used for cloning and pushing over HTTPS for the rest of the run
+func ConfigureRepoHost(ctx context.Context) RepoHost {
+	if RepoHostName == RepoHostGitlab {
+		return ConfigureGitlabHost(ctx)
+	}
+	return &githubHost{client: ConfigureGithubClient(ctx)}
+}
+
+// githubHost is the RepoHost for repos on Github, making its calls via go-github
+type githubHost struct {
+	client *github.Client
+}
+
+func (h *githubHost) GetRepo(owner, name string) (*github.Repository, error) {
+	repo, resp, err := h.client.Repositories.Get(context.Background(), owner, name)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return nil, fmt.Errorf("%w: %v", ErrRepoNotFound, err)
+		}
+		return nil, err
+	}
+	return repo, nil
+}
+
+func (h *githubHost) ListOrgRepos(org string) ([]*github.Repository, error) {
+	var allRepos []*github.Repository
+
+	opt := &github.RepositoryListByOrgOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		repos, resp, err := h.client.Repositories.ListByOrg(context.Background(), org, opt)
+		if err != nil {
+			return allRepos, err
+		}
+		allRepos = append(allRepos, repos...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allRepos, nil
+}
+
+func (h *githubHost) ListUserRepos(user string) ([]*github.Repository, error) {
+	var allRepos []*github.Repository
+
+	opt := &github.RepositoryListOptions{
+		Type: "owner",
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		repos, resp, err := h.client.Repositories.List(context.Background(), user, opt)
+		if err != nil {
+			return allRepos, err
+		}
+		allRepos = append(allRepos, repos...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allRepos, nil
+}
+
+// SearchRepos runs a Github search query, e.g., "org:gruntwork-io terraform in:name". Note that the Github search API
+// returns at most 1,000 results for any query
+func (h *githubHost) SearchRepos(query string) ([]*github.Repository, error) {
+	var allRepos []*github.Repository
+
+	opt := &github.SearchOptions{
+		ListOptions: github.ListOptions{
+			PerPage: 100,
+		},
+	}
+
+	for {
+		result, resp, err := h.client.Search.Repositories(context.Background(), query, opt)
+		if err != nil {
+			return allRepos, err
+		}
+		allRepos = append(allRepos, result.Repositories...)
+		if resp.NextPage == 0 {
+			break
+		}
+		opt.Page = resp.NextPage
+	}
+
+	return allRepos, nil
+}
+
+func (h *githubHost) BranchExists(repo *github.Repository, branch string) (bool, error) {
+	_, resp, err := h.client.Repositories.GetBranch(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), branch)
+	if err != nil {
+		if resp != nil && resp.StatusCode == 404 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+func (h *githubHost) CreatePullRequest(repo *github.Repository, newPR *github.NewPullRequest) (*github.PullRequest, error) {
+	pr, _, err := h.client.PullRequests.Create(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), newPR)
+	return pr, err
+}
+
+func (h *githubHost) FindOpenPullRequest(repo *github.Repository, head, baseBranch string) (*github.PullRequest, error) {
+	opts := &github.PullRequestListOptions{
+		State: "open",
+		Head:  fmt.Sprintf("%s:%s", repo.GetOwner().GetLogin(), head),
+		Base:  baseBranch,
+	}
+
+	prs, _, err := h.client.PullRequests.List(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(prs) == 0 {
+		return nil, nil
+	}
+	return prs[0], nil
+}
+
+func (h *githubHost) EditPullRequest(repo *github.Repository, pr *github.PullRequest, title, body string) (*github.PullRequest, error) {
+	edit := &github.PullRequest{
+		Title: github.String(title),
+		Body:  github.String(body),
+	}
+
+	edited, _, err := h.client.PullRequests.Edit(context.Background(), repo.GetOwner().GetLogin(), repo.GetName(), pr.GetNumber(), edit)
+	return edited, err
+}
+
+func (h *githubHost) ApplyPullRequestMetadata(repo *github.Repository, pr *github.PullRequest, stats *RunStats) {
+	applyPullRequestMetadata(h.client, repo, pr, stats)
+}
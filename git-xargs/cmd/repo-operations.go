@@ -1,26 +1,42 @@
 package cmd
 
 import (
-	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
-	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
 )
 
+// resolveBaseBranch returns the branch that the tool-specific branch is created from and that pull requests are opened
+// against. An operator-supplied override always wins; otherwise the repo's default branch as reported by the Github API
+// is used, falling back to master for the rare repo (such as an empty one) that doesn't report a default branch
+func resolveBaseBranch(override string, repo *github.Repository) string {
+	if override != "" {
+		return override
+	}
+	if repo.GetDefaultBranch() != "" {
+		return repo.GetDefaultBranch()
+	}
+	return "master"
+}
+
 // cloneLocalRepository clones a remote Github repo via SSH to a local temporary directory so that scripts can be run
 // against the repo locally and any git changes handled thereafter. The local directory has
 // git-xargs-<repo-name> appended to it to make it easier to find when you are looking for it while debugging
-func cloneLocalRepository(repo *github.Repository, stats *RunStats) (string, *git.Repository, error) {
+// The supplied base branch is the one checked out after cloning. If --clone-cache-dir is set, the repo is cloned from
+// its mirror in the cache, which is brought up to date first, rather than from Github. Only the --clone-depth most
+// recent commits are cloned, if set, and only the --sparse-checkout directories are checked out, if any
+func cloneLocalRepository(repo *github.Repository, baseBranch string, stats *RunStats) (string, *git.Repository, error) {
 	log.WithFields(logrus.Fields{
-		"Repo": repo.GetName(),
-	}).Debug("Attempting to clone repository using GITHUB_OAUTH_TOKEN")
+		"Repo":        repo.GetName(),
+		"Base branch": baseBranch,
+		"Protocol":    GitProtocol,
+	}).Debug("Attempting to clone repository")
 
 	repositoryDir, tmpDirErr := ioutil.TempDir("", fmt.Sprintf("git-xargs-%s", repo.GetName()))
 	if tmpDirErr != nil {
@@ -31,14 +47,52 @@ func cloneLocalRepository(repo *github.Repository, stats *RunStats) (string, *gi
 		return repositoryDir, nil, tmpDirErr
 	}
 
-	localRepository, err := git.PlainClone(repositoryDir, false, &git.CloneOptions{
-		URL:      repo.GetCloneURL(),
-		Progress: os.Stdout,
-		Auth: &http.BasicAuth{
-			Username: repo.GetOwner().GetLogin(),
-			Password: os.Getenv("GITHUB_OAUTH_TOKEN"),
-		},
-	})
+	cloneURL, auth, authErr := gitCloneAuth(repo)
+	if authErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": authErr,
+			"Repo":  repo.GetName(),
+		}).Debug("Error getting credentials to clone repository with")
+
+		stats.TrackError(RepoFailedToClone, repo, authErr)
+		return repositoryDir, nil, authErr
+	}
+
+	cloneOptions := &git.CloneOptions{
+		URL:           cloneURL,
+		ReferenceName: plumbing.NewBranchReferenceName(baseBranch),
+		Depth:         CloneDepth,
+		// Clone progress is written to STDERR so that STDOUT only ever contains the run report, which may be JSON
+		Progress: os.Stderr,
+		Auth:     auth,
+		// A sparse checkout is made once the repo is cloned, rather than checking out every path first
+		NoCheckout: len(SparseCheckoutPaths) > 0,
+	}
+
+	clonedFromCache := false
+	if CloneCacheDir != "" {
+		mirrorDir, cacheErr := updateCloneCache(CloneCacheDir, repo)
+		if cacheErr != nil {
+			log.WithFields(logrus.Fields{
+				"Error": cacheErr,
+				"Repo":  repo.GetName(),
+			}).Debug("Error updating clone cache mirror of repo, cloning from Github instead")
+
+			stats.TrackError(CloneCacheUpdateFailed, repo, cacheErr)
+		} else {
+			cloneOptions.URL = mirrorDir
+			cloneOptions.Auth = nil
+			clonedFromCache = true
+		}
+	}
+
+	localRepository, err := git.PlainClone(repositoryDir, false, cloneOptions)
+	if err == nil && clonedFromCache {
+		err = setRemoteURL(localRepository, "origin", cloneURL)
+	}
+	if err == nil && cloneOptions.NoCheckout {
+		err = sparseCheckout(localRepository, baseBranch)
+	}
 
 	if err != nil {
 		log.WithFields(logrus.Fields{
@@ -47,7 +101,7 @@ func cloneLocalRepository(repo *github.Repository, stats *RunStats) (string, *gi
 		}).Debug("Error cloning repository")
 
 		// Track failure to clone for our final run report
-		stats.TrackSingle(RepoFailedToClone, repo)
+		stats.TrackError(RepoFailedToClone, repo, err)
 
 		return repositoryDir, nil, err
 	}
@@ -57,6 +111,20 @@ func cloneLocalRepository(repo *github.Repository, stats *RunStats) (string, *gi
 	return repositoryDir, localRepository, nil
 }
 
+// sparseCheckout checks out only the --sparse-checkout directories of the base branch. The paths outside them are
+// marked as skipped in the index, so they're neither written to disk nor committed as deleted
+func sparseCheckout(localRepository *git.Repository, baseBranch string) error {
+	worktree, err := localRepository.Worktree()
+	if err != nil {
+		return err
+	}
+
+	return worktree.Checkout(&git.CheckoutOptions{
+		Branch:                    plumbing.NewBranchReferenceName(baseBranch),
+		SparseCheckoutDirectories: SparseCheckoutPaths,
+	})
+}
+
 // getLocalRepoHeadRef looks up the HEAD reference of the locally cloned git repository, which is required by
 // downstream operations such as branching
 func getLocalRepoHeadRef(localRepository *git.Repository, repo *github.Repository, stats *RunStats) (*plumbing.Reference, error) {
@@ -67,7 +135,7 @@ func getLocalRepoHeadRef(localRepository *git.Repository, repo *github.Repositor
 			"Repo":  repo.GetName(),
 		}).Debug("Error getting HEAD ref from local repo")
 
-		stats.TrackSingle(GetHeadRefFailed, repo)
+		stats.TrackError(GetHeadRefFailed, repo, headErr)
 
 		return nil, headErr
 	}
@@ -75,83 +143,116 @@ func getLocalRepoHeadRef(localRepository *git.Repository, repo *github.Repositor
 }
 
 // runAllTargetedScripts loops through the collection of verified scripts and runs each against the currently targeted
-// locally cloned repository, tracking any exceptions that may be thrown during execution
-func runAllTargetedScripts(repositoryDir string, scriptsCollection ScriptCollection, repo *github.Repository, worktree *git.Worktree, stats *RunStats) error {
-	for _, script := range scriptsCollection.Scripts {
-		cmd := exec.Command(script.Path)
-		cmd.Dir = repositoryDir
+// locally cloned repository, tracking any exceptions that may be thrown during execution. Once every script has run, the
+// worktree status is checked a single time and any new untracked files are staged. The final status is returned so
+// that callers can tell whether the scripts changed anything at all
+// Each script is passed its user-supplied arguments, and the XARGS_* environment variables describing the repo
+func runAllTargetedScripts(dryRun bool, repositoryDir, baseBranch string, scriptsCollection ScriptCollection, repo *github.Repository, worktree *git.Worktree, stats *RunStats) (git.Status, error) {
+	env := scriptEnv(repo, baseBranch, dryRun)
+
+	for i, script := range scriptsCollection.Scripts {
+		cmd := script.newCommand(repositoryDir)
+		cmd.Env = env
 
 		log.WithFields(logrus.Fields{
 			"Repo":      repo.GetName(),
 			"Directory": repositoryDir,
-			"Script":    script,
+			"Script":    script.String(),
 		}).Debug("Executing script against local clone of repo...")
 
-		stdoutStdErr, err := cmd.CombinedOutput()
+		stdoutStdErr, err := runScriptCommand(cmd, ScriptTimeout)
+
+		// Hold on to the script's output regardless of whether it succeeded, both on disk and in the run report, so that
+		// failures can be diagnosed without re-running the script
+		logFile := storeScriptLog(ScriptLogDir, repo, i, script, stdoutStdErr)
+		stats.TrackScriptOutput(repo, script.String(), string(stdoutStdErr), logFile, err)
+
+		// A hung script has already been killed by now, so the repo is given up on and the run moves on to the next one
+		if _, timedOut := err.(ScriptTimeoutError); timedOut {
+			log.WithFields(logrus.Fields{
+				"Repo":    repo.GetName(),
+				"Script":  script.String(),
+				"Timeout": ScriptTimeout,
+			}).Debug("Script timed out and was killed along with its process group")
+			stats.TrackError(ScriptTimedOut, repo, err)
+			return nil, err
+		}
 
 		if err != nil {
 			log.WithFields(logrus.Fields{
 				"Error": err,
 			}).Debug("Error getting output of script execution")
 			// Track the script error against the repo
-			stats.TrackSingle(ScriptErrorOcurredDuringExecution, repo)
-			return err
+			stats.TrackError(ScriptErrorOcurredDuringExecution, repo, err)
+			return nil, err
 		}
 
 		log.WithFields(logrus.Fields{
 			"CombinedOutput": string(stdoutStdErr),
 		}).Debug("Received output of script run")
+	}
 
-		status, statusErr := worktree.Status()
+	status, statusErr := worktree.Status()
 
-		if statusErr != nil {
-			log.WithFields(logrus.Fields{
-				"Error": statusErr,
-				"Repo":  repo.GetName(),
-				"Dir":   repositoryDir,
-			}).Debug("Error looking up worktree status")
+	if statusErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": statusErr,
+			"Repo":  repo.GetName(),
+			"Dir":   repositoryDir,
+		}).Debug("Error looking up worktree status")
 
-			// Track the status check failure
-			stats.TrackSingle(WorktreeStatusCheckFailed, repo)
-			return statusErr
-		}
+		// Track the status check failure
+		stats.TrackError(WorktreeStatusCheckFailed, repo, statusErr)
+		return nil, statusErr
+	}
 
-		// If our scripts made any file changes, we need to stage, add and commit them
-		if !status.IsClean() {
-			log.WithFields(logrus.Fields{
-				"Repo": repo.GetName(),
-			}).Debug("Local repository worktree no longer clean, will stage and add new files and commit changes")
-
-			// Track the fact that worktree changes were made following execution
-			stats.TrackSingle(WorktreeStatusDirty, repo)
-
-			for filepath := range status {
-				if status.IsUntracked(filepath) {
-					fmt.Printf("Found untracked file %s. Adding to stage", filepath)
-					_, addErr := worktree.Add(filepath)
-					if addErr != nil {
-						log.WithFields(logrus.Fields{
-							"Error":    addErr,
-							"Filepath": filepath,
-						}).Debug("Error adding file to git stage")
-						// Track the file staging failure
-						stats.TrackSingle(WorktreeAddFileFailed, repo)
-						return addErr
-					}
-				}
-			}
+	// If our scripts made any file changes, we need to stage, add and commit them
+	if !status.IsClean() {
+		log.WithFields(logrus.Fields{
+			"Repo": repo.GetName(),
+		}).Debug("Local repository worktree no longer clean, will stage and add new files and commit changes")
 
-		} else {
-			log.WithFields(logrus.Fields{
-				"Repo": repo.GetName(),
-			}).Debug("Local repository status is clean - nothing to stage or commit")
+		// Track the fact that worktree changes were made following execution
+		stats.TrackSingle(WorktreeStatusDirty, repo)
 
-			// Track the fact that repo had no file changes post script execution
-			stats.TrackSingle(WorktreeStatusClean, repo)
-		}
+		// Only the changes that pass --include and --exclude are staged, and so committed and reported on from here on
+		return stageChanges(worktree, status, NewPathFilter(IncludePaths, ExcludePaths), repo, stats)
+	} else {
+		log.WithFields(logrus.Fields{
+			"Repo": repo.GetName(),
+		}).Debug("Local repository status is clean - nothing to stage or commit")
+
+		// Track the fact that repo had no file changes post script execution
+		stats.TrackSingle(WorktreeStatusClean, repo)
 	}
 
-	return nil
+	return status, nil
+}
+
+// cleanupLocalRepository removes the local clone of the repo from the temporary directory it was cloned into, unless
+// --keep-clones was set
+func cleanupLocalRepository(repositoryDir string, repo *github.Repository) {
+	if KeepClones {
+		log.WithFields(logrus.Fields{
+			"Repo": repo.GetName(),
+			"Dir":  repositoryDir,
+		}).Debug("Keeping local clone of repo because --keep-clones is set")
+		return
+	}
+
+	if removeErr := os.RemoveAll(repositoryDir); removeErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": removeErr,
+			"Repo":  repo.GetName(),
+			"Dir":   repositoryDir,
+		}).Debug("Error removing local clone of repo")
+		return
+	}
+
+	log.WithFields(logrus.Fields{
+		"Repo": repo.GetName(),
+		"Dir":  repositoryDir,
+	}).Debug("Removed local clone of repo")
 }
 
 // getLocalWorkTree looks up the working tree of the locally cloned repository and returns it if possible, or an error
@@ -170,12 +271,62 @@ func getLocalWorkTree(repositoryDir string, localRepository *git.Repository, rep
 	return worktree, nil
 }
 
+// lookupTargetBranch checks via the repo host's API whether the tool-specific branch already exists in the remote repo,
+// for example because a previous run of the same campaign already pushed it, and tracks the result
+func lookupTargetBranch(host RepoHost, repo *github.Repository, stats *RunStats) (bool, error) {
+	exists, err := host.BranchExists(repo, BranchName)
+
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":  err,
+			"Repo":   repo.GetName(),
+			"Branch": BranchName,
+		}).Debug("Error looking up target branch")
+
+		stats.TrackError(TargetBranchLookupErr, repo, err)
+		return false, err
+	}
+
+	if !exists {
+		stats.TrackSingle(TargetBranchNotFound, repo)
+		return false, nil
+	}
+
+	log.WithFields(logrus.Fields{
+		"Repo":   repo.GetName(),
+		"Branch": BranchName,
+	}).Debug("Target branch already exists in remote repo")
+
+	stats.TrackSingle(TargetBranchAlreadyExists, repo)
+	return true, nil
+}
+
+// getRemoteBranchRef looks up the tip of the tool-specific branch as it exists on the remote origin, so that an existing
+// branch can be checked out and added to rather than rebuilt from the base branch
+func getRemoteBranchRef(localRepository *git.Repository, repo *github.Repository, stats *RunStats) (*plumbing.Reference, error) {
+	ref, refErr := localRepository.Reference(plumbing.NewRemoteReferenceName("origin", BranchName), true)
+	if refErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error":  refErr,
+			"Repo":   repo.GetName(),
+			"Branch": BranchName,
+		}).Debug("Error looking up existing remote branch in local clone")
+
+		stats.TrackError(BranchCheckoutFailed, repo, refErr)
+		return nil, refErr
+	}
+	return ref, nil
+}
+
 // checkoutLocalBranch creates a local branch specific to this tool in the locally checked out copy of the repo in the /tmp folder
-func checkoutLocalBranch(ref *plumbing.Reference, worktree *git.Worktree, remoteRepository *github.Repository, localRepository *git.Repository, stats *RunStats) (plumbing.ReferenceName, error) {
+// The supplied ref is the tip of the base branch that was checked out when cloning, so the new branch starts from it, or,
+// when updating an existing branch via --update-existing, the tip of that branch on the remote origin
+func checkoutLocalBranch(ref *plumbing.Reference, baseBranch string, worktree *git.Worktree, remoteRepository *github.Repository, localRepository *git.Repository, stats *RunStats) (plumbing.ReferenceName, error) {
 	// BranchName is a global variable that is set in cmd/root.go. It is override-able by the operator via the --branch-name or -b flag. It defaults to "git-xargs"
 	branchName := plumbing.NewBranchReferenceName(BranchName)
 	log.WithFields(logrus.Fields{
 		"Branch Name": branchName,
+		"Base branch": baseBranch,
 		"Repo":        remoteRepository.GetName(),
 	}).Debug("Created branch")
 
@@ -184,6 +335,8 @@ func checkoutLocalBranch(ref *plumbing.Reference, worktree *git.Worktree, remote
 		Hash:   ref.Hash(),
 		Branch: branchName,
 		Create: true,
+		// Keep the checkout as sparse as the clone, if --sparse-checkout was passed
+		SparseCheckoutDirectories: SparseCheckoutPaths,
 	}
 
 	// Attempt to checkout the new tool-specific branch on which all scripts will be executed
@@ -196,7 +349,7 @@ func checkoutLocalBranch(ref *plumbing.Reference, worktree *git.Worktree, remote
 		}).Debug("Error creating new branch")
 
 		// Track the error checking out the branch
-		stats.TrackSingle(BranchCheckoutFailed, remoteRepository)
+		stats.TrackError(BranchCheckoutFailed, remoteRepository, checkoutErr)
 
 		return branchName, checkoutErr
 	}
@@ -204,18 +357,22 @@ func checkoutLocalBranch(ref *plumbing.Reference, worktree *git.Worktree, remote
 	return branchName, nil
 }
 
-// commitLocalChanges will create a commit using the supplied or default commit message and will add any untracked, deleted
-// or modified files that resulted from script execution
-func commitLocalChanges(worktree *git.Worktree, remoteRepository *github.Repository, localRepository *git.Repository, stats *RunStats) error {
+// commitLocalChanges will create a commit using the supplied commit message, rendered for this repo, of the untracked,
+// deleted or modified files that resulted from script execution and were staged. The commit is made as the author passed via
+// --author-name and --author-email, credits any --co-author, and is signed with --signing-key if one was passed
+func commitLocalChanges(commitMessage string, worktree *git.Worktree, remoteRepository *github.Repository, localRepository *git.Repository, stats *RunStats) error {
 
-	// With all our untracked files staged, we can now create a commit, passing the All
-	// option when configuring our commit option so that all modified and deleted files
-	// will have their changes committed
+	// Every change to commit was staged after the scripts ran, so only the staged changes are committed. Committing with
+	// the All option would sweep in modified files that --include or --exclude left out
 	commitOps := &git.CommitOptions{
-		All: true,
+		Author: commitAuthor(),
 	}
+	activeCommitSigner.applyToCommitOptions(commitOps)
 
-	_, commitErr := worktree.Commit(CommitMessage, commitOps)
+	_, commitErr := worktree.Commit(withCoAuthorTrailers(commitMessage, CoAuthors), commitOps)
+	if commitErr == nil {
+		commitErr = activeCommitSigner.signHead(localRepository)
+	}
 
 	if commitErr != nil {
 		log.WithFields(logrus.Fields{
@@ -225,12 +382,47 @@ func commitLocalChanges(worktree *git.Worktree, remoteRepository *github.Reposit
 
 		// If we reach this point, we were unable to commit our changes, so we'll
 		// continue rather than attempt to push an empty branch and open an empty PR
-		stats.TrackSingle(CommitChangesFailed, remoteRepository)
+		stats.TrackError(CommitChangesFailed, remoteRepository, commitErr)
 		return commitErr
 	}
 	return nil
 }
 
+// pushRemote is the name of the remote branches are pushed over when they're pushed somewhere other than origin
+const pushRemote = "git-xargs-push"
+
+// setRemoteURL points the named remote of the local repository at the supplied URL, creating the remote if it doesn't
+// exist yet. Remote-tracking branches already fetched from the remote are left in place. This is how a clone of a
+// mirror in the clone cache is pointed at Github rather than at the mirror
+func setRemoteURL(localRepository *git.Repository, name, remoteURL string, fetch ...config.RefSpec) error {
+	if err := localRepository.DeleteRemote(name); err != nil && err != git.ErrRemoteNotFound {
+		return err
+	}
+
+	_, err := localRepository.CreateRemote(&config.RemoteConfig{
+		Name:  name,
+		URLs:  []string{remoteURL},
+		Fetch: fetch,
+	})
+	return err
+}
+
+// pushRemoteName returns the name of the remote to push to the supplied URL over. That's origin, unless the branch is
+// pushed somewhere other than where the repo was cloned from, e.g., over SSH having been cloned over HTTPS, in which
+// case a remote of its own is set up
+func pushRemoteName(localRepository *git.Repository, pushURL string) (string, error) {
+	origin, err := localRepository.Remote("origin")
+	if err != nil {
+		return "", err
+	}
+
+	if urls := origin.Config().URLs; len(urls) > 0 && urls[0] == pushURL {
+		return "origin", nil
+	}
+
+	return pushRemote, setRemoteURL(localRepository, pushRemote, pushURL)
+}
+
 // pushLocalBranch pushes the branch in the local clone of the /tmp/ directory repository to the Github remote origin
 // so that a pull request can be opened against it via the Github API
 func pushLocalBranch(dryRun bool, remoteRepository *github.Repository, localRepository *git.Repository, stats *RunStats) error {
@@ -244,15 +436,18 @@ func pushLocalBranch(dryRun bool, remoteRepository *github.Repository, localRepo
 
 		return nil
 	}
-	// Push the changes to the remote repo
-	po := &git.PushOptions{
-		RemoteName: "origin",
-		Auth: &http.BasicAuth{
-			Username: remoteRepository.GetOwner().GetLogin(),
-			Password: os.Getenv("GITHUB_OAUTH_TOKEN"),
-		},
+	// Push the changes to the remote repo, over a remote of its own if the branch is pushed somewhere other than origin
+	pushURL, auth, pushErr := gitPushAuth(remoteRepository)
+	remoteName := "origin"
+	if pushErr == nil {
+		remoteName, pushErr = pushRemoteName(localRepository, pushURL)
+	}
+	if pushErr == nil {
+		pushErr = localRepository.Push(&git.PushOptions{
+			RemoteName: remoteName,
+			Auth:       auth,
+		})
 	}
-	pushErr := localRepository.Push(po)
 
 	if pushErr != nil {
 		log.WithFields(logrus.Fields{
@@ -261,7 +456,7 @@ func pushLocalBranch(dryRun bool, remoteRepository *github.Repository, localRepo
 		}).Debug("Error pushing new branch to remote origin")
 
 		// Track the push failure
-		stats.TrackSingle(PushBranchFailed, remoteRepository)
+		stats.TrackError(PushBranchFailed, remoteRepository, pushErr)
 		return pushErr
 	}
 
@@ -269,12 +464,15 @@ func pushLocalBranch(dryRun bool, remoteRepository *github.Repository, localRepo
 		"Repo": remoteRepository.GetName(),
 	}).Debug("Successfully pushed local branch to remote origin")
 
+	// Track the successful push, which also lets a resumed run skip straight to opening the pull request for this repo
+	stats.TrackSingle(PushBranchSucceeded, remoteRepository)
+
 	return nil
 }
 
-// Attempt to open a pull request via the Github API, of the supplied branch specific to this tool, against the main
-// branch for the remote origin
-func openPullRequest(dryRun bool, githubClient *github.Client, repo *github.Repository, branch string, stats *RunStats) error {
+// Attempt to open a pull request via the repo host's API, of the supplied branch specific to this tool, against the
+// supplied base branch for the remote origin. On GitLab, this opens a merge request
+func openPullRequest(dryRun bool, host RepoHost, repo *github.Repository, branch, baseBranch, title, body string, stats *RunStats) error {
 
 	if dryRun {
 		log.WithFields(logrus.Fields{
@@ -283,28 +481,42 @@ func openPullRequest(dryRun bool, githubClient *github.Client, repo *github.Repo
 		return nil
 	}
 
+	// When updating an existing campaign, edit the pull request that is already open for the branch rather than opening a duplicate
+	if UpdateExisting {
+		existingPR, lookupErr := findOpenPullRequest(host, repo, baseBranch)
+		if lookupErr != nil {
+			stats.TrackError(PullRequestUpdateErr, repo, lookupErr)
+			return lookupErr
+		}
+
+		if existingPR != nil {
+			return updatePullRequest(host, repo, existingPR, title, body, stats)
+		}
+	}
+
 	// Configure pull request options that the Github client accepts when making calls to open new pull requests
 	newPR := &github.NewPullRequest{
-		Title:               github.String(PullRequestTitle),
+		Title:               github.String(title),
 		Head:                github.String(branch),
-		Base:                github.String("master"),
-		Body:                github.String(PullRequestDescription),
+		Base:                github.String(baseBranch),
+		Body:                github.String(body),
 		MaintainerCanModify: github.Bool(true),
+		Draft:               github.Bool(DraftPullRequest),
 	}
 
-	// Make a pull request via the Github API
-	pr, _, err := githubClient.PullRequests.Create(context.Background(), *repo.GetOwner().Login, repo.GetName(), newPR)
+	// Make a pull request via the repo host's API
+	pr, err := host.CreatePullRequest(repo, newPR)
 
 	if err != nil {
 		log.WithFields(logrus.Fields{
 			"Error": err,
 			"Head":  branch,
-			"Base":  "master",
-			"Body":  PullRequestDescription,
+			"Base":  baseBranch,
+			"Body":  body,
 		}).Debug("Error opening Pull request")
 
 		// Track pull request open failure
-		stats.TrackSingle(PullRequestOpenErr, repo)
+		stats.TrackError(PullRequestOpenErr, repo, err)
 
 		return err
 	}
@@ -314,6 +526,50 @@ func openPullRequest(dryRun bool, githubClient *github.Client, repo *github.Repo
 	}).Debug("Successfully opened pull request")
 
 	// Track successful opening of the pull request, extracting the HTML url to the PR itself for easier review
-	stats.TrackPullRequest(repo.GetName(), pr.GetHTMLURL())
+	stats.TrackPullRequest(repo, pr.GetHTMLURL())
+
+	// Failing to apply any of the metadata is tracked on its own, without failing the pull request that was opened
+	host.ApplyPullRequestMetadata(repo, pr, stats)
+	return nil
+}
+
+// findOpenPullRequest looks up the open pull request of the tool-specific branch against the base branch, returning nil
+// if there isn't one
+func findOpenPullRequest(host RepoHost, repo *github.Repository, baseBranch string) (*github.PullRequest, error) {
+	pr, err := host.FindOpenPullRequest(repo, BranchName, baseBranch)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error": err,
+			"Repo":  repo.GetName(),
+			"Head":  BranchName,
+		}).Debug("Error looking up existing pull request")
+		return nil, err
+	}
+
+	return pr, nil
+}
+
+// updatePullRequest edits the title and body of an already open pull request to match the ones supplied for this run
+func updatePullRequest(host RepoHost, repo *github.Repository, existingPR *github.PullRequest, title, body string, stats *RunStats) error {
+	pr, err := host.EditPullRequest(repo, existingPR, title, body)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":            err,
+			"Pull Request URL": existingPR.GetHTMLURL(),
+		}).Debug("Error updating existing pull request")
+
+		stats.TrackError(PullRequestUpdateErr, repo, err)
+		return err
+	}
+
+	log.WithFields(logrus.Fields{
+		"Pull Request URL": pr.GetHTMLURL(),
+	}).Debug("Successfully updated existing pull request")
+
+	stats.TrackSingle(PullRequestUpdated, repo)
+	stats.TrackPullRequest(repo, pr.GetHTMLURL())
+
+	// Reviewers, labels and so on are only ever added, so applying them again brings the pull request up to date with this run
+	host.ApplyPullRequestMetadata(repo, pr, stats)
 	return nil
 }
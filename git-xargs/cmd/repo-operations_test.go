@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveBaseBranchPrefersOverride(t *testing.T) {
+	repo := &github.Repository{DefaultBranch: github.String("main")}
+
+	assert.Equal(t, resolveBaseBranch("develop", repo), "develop")
+}
+
+func TestResolveBaseBranchUsesRepoDefaultBranch(t *testing.T) {
+	repo := &github.Repository{DefaultBranch: github.String("main")}
+
+	assert.Equal(t, resolveBaseBranch("", repo), "main")
+}
+
+func TestResolveBaseBranchFallsBackToMaster(t *testing.T) {
+	repo := &github.Repository{}
+
+	assert.Equal(t, resolveBaseBranch("", repo), "master")
+}
+
+// newTestGithubClient returns a Github client whose API calls are served by the supplied mux, along with a function to shut the server down
+func newTestGithubClient(t *testing.T, mux *http.ServeMux) (*github.Client, func()) {
+	server := httptest.NewServer(mux)
+
+	baseURL, err := url.Parse(server.URL + "/")
+	require.NoError(t, err)
+
+	client := github.NewClient(nil)
+	client.BaseURL = baseURL
+
+	return client, server.Close
+}
+
+func makeTestRepo() *github.Repository {
+	return &github.Repository{
+		Name:     github.String("cloud-nuke"),
+		FullName: github.String("gruntwork-io/cloud-nuke"),
+		Owner:    &github.User{Login: github.String("gruntwork-io")},
+	}
+}
+
+func TestLookupTargetBranchTracksMissingBranch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/branches/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, `{"message": "Branch not found"}`, http.StatusNotFound)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	exists, err := lookupTargetBranch(&githubHost{client: client}, makeTestRepo(), stats)
+
+	assert.NoError(t, err)
+	assert.False(t, exists)
+	assert.Equal(t, len(stats.GetMultiple(TargetBranchNotFound)), 1)
+}
+
+func TestLookupTargetBranchTracksExistingBranch(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/branches/git-xargs", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"name": "git-xargs"}`)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	exists, err := lookupTargetBranch(&githubHost{client: client}, makeTestRepo(), stats)
+
+	assert.NoError(t, err)
+	assert.True(t, exists)
+	assert.Equal(t, len(stats.GetMultiple(TargetBranchAlreadyExists)), 1)
+}
+
+func TestOpenPullRequestUpdatesExistingPullRequest(t *testing.T) {
+	defer func(original bool) { UpdateExisting = original }(UpdateExisting)
+	UpdateExisting = true
+
+	edited := false
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls", func(w http.ResponseWriter, r *http.Request) {
+		// Opening a new pull request would create a duplicate, so only listing is allowed
+		assert.Equal(t, r.Method, http.MethodGet)
+		assert.Equal(t, r.URL.Query().Get("head"), "gruntwork-io:git-xargs")
+		fmt.Fprint(w, `[{"number": 7, "html_url": "https://github.com/gruntwork-io/cloud-nuke/pull/7"}]`)
+	})
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, r.Method, http.MethodPatch)
+		edited = true
+		fmt.Fprint(w, `{"number": 7, "html_url": "https://github.com/gruntwork-io/cloud-nuke/pull/7"}`)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	stats := NewStatsTracker()
+	err := openPullRequest(false, &githubHost{client: client}, makeTestRepo(), "refs/heads/git-xargs", "master", PullRequestTitle, PullRequestDescription, stats)
+
+	assert.NoError(t, err)
+	assert.True(t, edited)
+	assert.Equal(t, len(stats.GetMultiple(PullRequestUpdated)), 1)
+	assert.Equal(t, stats.GetPullRequests()["cloud-nuke"], "https://github.com/gruntwork-io/cloud-nuke/pull/7")
+}
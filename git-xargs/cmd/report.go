@@ -0,0 +1,241 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sort"
+	"time"
+)
+
+const (
+	// OutputFormatTable renders the run report as human-readable tables
+	OutputFormatTable = "table"
+	// OutputFormatJSON renders the run report as a single JSON document following the RunReport schema
+	OutputFormatJSON = "json"
+
+	// RunReportSchemaVersion is the version of the RunReport JSON schema. Adding new fields does not change the version, but
+	// removing or renaming a field, or changing its meaning, must increment it so that downstream automation can detect the break
+	RunReportSchemaVersion = 1
+)
+
+// isValidOutputFormat returns true if the supplied value is one of the supported --output-format values
+func isValidOutputFormat(format string) bool {
+	return format == OutputFormatTable || format == OutputFormatJSON
+}
+
+// RunReport is the machine-readable form of the final run report. Its JSON representation is a stable, versioned schema
+// intended for dashboards and follow-up automation
+type RunReport struct {
+	SchemaVersion     int                              `json:"schema_version"`
+	StartTime         time.Time                        `json:"start_time"`
+	EndTime           time.Time                        `json:"end_time"`
+	DurationSeconds   float64                          `json:"duration_seconds"`
+	DryRun            bool                             `json:"dry_run"`
+	BranchName        string                           `json:"branch_name"`
+	StateFile         string                           `json:"state_file,omitempty"`
+	ScriptLogDir      string                           `json:"script_log_dir,omitempty"`
+	Summary           ReportSummary                    `json:"summary"`
+	FileProvidedRepos []ReportFileProvidedRepo         `json:"file_provided_repos"`
+	Events            []ReportEvent                    `json:"events"`
+	PullRequests      []ReportPullRequest              `json:"pull_requests"`
+	ScriptOutputs     []ReportScriptOutput             `json:"script_outputs"`
+	Errors            []ReportError                    `json:"errors"`
+	Timelines         map[string][]ReportTimelineEntry `json:"timelines"`
+}
+
+// ReportSummary holds the headline counts of the run, keeping repos that needed no changes apart from failed repos.
+// Headlines holds the count for each AnnotatedEvent headline, e.g., the pull requests merged by the merge subcommand.
+// APICalls counts the Github API calls that were rate limited or failed, and had to be retried
+type ReportSummary struct {
+	PullRequestsOpened int            `json:"pull_requests_opened"`
+	NoChangesRequired  int            `json:"no_changes_required"`
+	Failed             int            `json:"failed"`
+	Headlines          map[string]int `json:"headlines,omitempty"`
+	APICalls           APICallCounts  `json:"api_calls"`
+}
+
+// ReportFileProvidedRepo is a repo that the operator supplied via --allowed-repos-filepath
+type ReportFileProvidedRepo struct {
+	Organization string `json:"organization"`
+	Name         string `json:"name"`
+}
+
+// ReportEvent is a single AnnotatedEvent bucket and every repo that was filed under it. Every known event is always
+// present, with an empty list of repos if none were filed under it
+type ReportEvent struct {
+	Event       Event        `json:"event"`
+	Description string       `json:"description"`
+	Failure     bool         `json:"failure"`
+	Repos       []ReportRepo `json:"repos"`
+}
+
+// ReportRepo identifies a single repo in the run report
+type ReportRepo struct {
+	Name     string `json:"name"`
+	FullName string `json:"full_name"`
+	URL      string `json:"url"`
+}
+
+// ReportPullRequest is a pull request that was opened during the run
+type ReportPullRequest struct {
+	Repo string `json:"repo"`
+	URL  string `json:"url"`
+}
+
+// ReportScriptOutput is the output of a single script run against a single repo
+type ReportScriptOutput struct {
+	Repo    string `json:"repo"`
+	Script  string `json:"script"`
+	Output  string `json:"output"`
+	LogFile string `json:"log_file,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ReportError is a single event that was tracked against a repo along with the error that caused it
+type ReportError struct {
+	Repo      string    `json:"repo"`
+	Event     Event     `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	Error     string    `json:"error"`
+}
+
+// ReportTimelineEntry is a single step in a repo's event timeline
+type ReportTimelineEntry struct {
+	Event           Event     `json:"event"`
+	Timestamp       time.Time `json:"timestamp"`
+	DurationSeconds float64   `json:"duration_seconds"`
+	Error           string    `json:"error,omitempty"`
+}
+
+// buildRunReport assembles a RunReport for the supplied events from everything the stats tracker has recorded so far.
+// Lists are sorted by repo name so that two reports of the same run are identical
+func buildRunReport(events []AnnotatedEvent, r *RunStats) RunReport {
+	endTime := time.Now()
+
+	report := RunReport{
+		SchemaVersion:   RunReportSchemaVersion,
+		StartTime:       r.startTime.UTC(),
+		EndTime:         endTime.UTC(),
+		DurationSeconds: endTime.Sub(r.startTime).Seconds(),
+		DryRun:          DryRun,
+		BranchName:      BranchName,
+		ScriptLogDir:    ScriptLogDir,
+		Summary: ReportSummary{
+			PullRequestsOpened: len(r.GetPullRequests()),
+			NoChangesRequired:  len(r.GetMultiple(NoChangesRequired)),
+			Failed:             r.CountFailedRepos(events),
+			APICalls:           r.GetAPICallCounts(),
+		},
+		FileProvidedRepos: []ReportFileProvidedRepo{},
+		Events:            []ReportEvent{},
+		PullRequests:      []ReportPullRequest{},
+		ScriptOutputs:     []ReportScriptOutput{},
+		Errors:            []ReportError{},
+		Timelines:         make(map[string][]ReportTimelineEntry),
+	}
+
+	for _, hc := range r.CountHeadlineRepos(events) {
+		if report.Summary.Headlines == nil {
+			report.Summary.Headlines = make(map[string]int)
+		}
+		report.Summary.Headlines[hc.Headline] = hc.Count
+	}
+
+	for _, allowedRepo := range r.fileProvidedRepos {
+		report.FileProvidedRepos = append(report.FileProvidedRepos, ReportFileProvidedRepo{
+			Organization: allowedRepo.Organization,
+			Name:         allowedRepo.Name,
+		})
+	}
+
+	for _, ae := range events {
+		reportEvent := ReportEvent{
+			Event:       ae.Event,
+			Description: ae.Description,
+			Failure:     ae.Failure,
+			Repos:       []ReportRepo{},
+		}
+
+		for _, repo := range r.GetMultiple(ae.Event) {
+			reportEvent.Repos = append(reportEvent.Repos, ReportRepo{
+				Name:     repo.GetName(),
+				FullName: repo.GetFullName(),
+				URL:      repo.GetHTMLURL(),
+			})
+		}
+		sort.Slice(reportEvent.Repos, func(i, j int) bool { return reportEvent.Repos[i].Name < reportEvent.Repos[j].Name })
+
+		report.Events = append(report.Events, reportEvent)
+	}
+
+	for repoName, prURL := range r.GetPullRequests() {
+		report.PullRequests = append(report.PullRequests, ReportPullRequest{Repo: repoName, URL: prURL})
+	}
+	sort.Slice(report.PullRequests, func(i, j int) bool { return report.PullRequests[i].Repo < report.PullRequests[j].Repo })
+
+	scriptRepoNames := r.getRepoNamesWithScriptOutput()
+	sort.Strings(scriptRepoNames)
+	for _, repoName := range scriptRepoNames {
+		for _, output := range r.GetScriptOutputs(repoName) {
+			report.ScriptOutputs = append(report.ScriptOutputs, ReportScriptOutput{
+				Repo:    repoName,
+				Script:  output.Script,
+				Output:  output.Output,
+				LogFile: output.LogFile,
+				Error:   output.Error,
+			})
+		}
+	}
+
+	timelineRepoNames := r.getTimelineRepoNames()
+	sort.Strings(timelineRepoNames)
+	for _, repoName := range timelineRepoNames {
+		var entries []ReportTimelineEntry
+
+		for _, entry := range r.GetTimeline(repoName) {
+			entries = append(entries, ReportTimelineEntry{
+				Event:           entry.Event,
+				Timestamp:       entry.Timestamp.UTC(),
+				DurationSeconds: entry.Duration.Seconds(),
+				Error:           entry.Error,
+			})
+
+			if entry.Error != "" {
+				report.Errors = append(report.Errors, ReportError{
+					Repo:      repoName,
+					Event:     entry.Event,
+					Timestamp: entry.Timestamp.UTC(),
+					Error:     entry.Error,
+				})
+			}
+		}
+
+		report.Timelines[repoName] = entries
+	}
+
+	return report
+}
+
+// writeJSONReport writes the report, such as a RunReport or CampaignStatusReport, to the supplied writer as indented JSON
+func writeJSONReport(w io.Writer, report interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// writeJSONReportFile writes the report as indented JSON to the file at path, creating or truncating it
+func writeJSONReportFile(path string, report interface{}) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	writeErr := writeJSONReport(file, report)
+	closeErr := file.Close()
+
+	if writeErr != nil {
+		return writeErr
+	}
+	return closeErr
+}
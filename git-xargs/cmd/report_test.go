@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBuildRunReportIncludesEveryEventBucket(t *testing.T) {
+	stats := NewStatsTracker()
+
+	report := buildRunReport(allEvents, stats)
+
+	assert.Equal(t, report.SchemaVersion, RunReportSchemaVersion)
+	assert.Equal(t, len(report.Events), len(allEvents))
+	for _, reportEvent := range report.Events {
+		// Empty buckets must still serialize as an empty list rather than null, to keep the schema stable
+		assert.NotNil(t, reportEvent.Repos)
+	}
+}
+
+func TestBuildRunReportSerializesTrackedData(t *testing.T) {
+	stats := NewStatsTracker()
+	repo := &github.Repository{Name: github.String("cloud-nuke"), FullName: github.String("gruntwork-io/cloud-nuke")}
+
+	stats.TrackSingle(RepoSuccessfullyCloned, repo)
+	stats.TrackScriptOutput(repo, "/tmp/add-license.sh", "added LICENSE\n", "/tmp/logs/gruntwork-io/cloud-nuke/01-add-license.sh.log", nil)
+	stats.TrackError(PullRequestOpenErr, repo, errors.New("422 Validation Failed"))
+
+	var buf bytes.Buffer
+	assert.NoError(t, writeJSONReport(&buf, buildRunReport(allEvents, stats)))
+
+	var decoded RunReport
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &decoded))
+
+	assert.Equal(t, decoded.ScriptOutputs, []ReportScriptOutput{{Repo: "gruntwork-io/cloud-nuke", Script: "/tmp/add-license.sh", Output: "added LICENSE\n", LogFile: "/tmp/logs/gruntwork-io/cloud-nuke/01-add-license.sh.log"}})
+	assert.Equal(t, len(decoded.Errors), 1)
+	assert.Equal(t, decoded.Errors[0].Event, PullRequestOpenErr)
+	assert.Equal(t, decoded.Errors[0].Error, "422 Validation Failed")
+	assert.Equal(t, len(decoded.Timelines["gruntwork-io/cloud-nuke"]), 2)
+
+	for _, reportEvent := range decoded.Events {
+		if reportEvent.Event == RepoSuccessfullyCloned {
+			assert.Equal(t, reportEvent.Repos, []ReportRepo{{Name: "cloud-nuke", FullName: "gruntwork-io/cloud-nuke"}})
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -16,7 +21,7 @@ import (
 var VERSION string
 
 var (
-	// AllowedReposFile is the path to the file containing the names  of the repos that are safe for this tool to operate on, each on its own line
+	// AllowedReposFile is the path to the file containing the names  of the repos that are safe for this tool to operate on, each on its own line, or - to read them from STDIN
 	AllowedReposFile string
 	// Debug will dump the YAML pre and post processing to STDOUT for easier debugging, at the cost of extreme verbosity and terminal spew
 	Debug bool
@@ -24,16 +29,118 @@ var (
 	DryRun bool
 	// GithubOrg is the name of the organization that this tool will list repositories from
 	GithubOrg string
+	// GithubUser is the name of the personal Github account that this tool will list repositories from
+	GithubUser string
+	// RepoSearchQuery is a Github repository search query, the results of which are the repos this tool will operate on
+	RepoSearchQuery string
+	// RepoTopics lists topics that every selected repo must have
+	RepoTopics []string
+	// RepoLanguage is the primary language that every selected repo must have
+	RepoLanguage string
+	// ExcludeArchived filters out archived repos from the selected repos
+	ExcludeArchived bool
+	// ExcludeForks filters out forked repos from the selected repos
+	ExcludeForks bool
+	// IncludeRepoRegex, when set, is a regular expression every selected repo's name must match
+	IncludeRepoRegex string
+	// ExcludeRepoRegex, when set, is a regular expression no selected repo's name may match
+	ExcludeRepoRegex string
 	// TargetScripts represents the scripts to run on the given repo
 	TargetScripts []string
+	// ScriptLogDir is the directory the output of every script run against every repo is stored in. Otherwise, a unique directory in the system temp directory is used
+	ScriptLogDir string
+	// ScriptOutputLines is the number of trailing lines of script output to show in the run report and pull request bodies
+	ScriptOutputLines int
+	// IncludeScriptOutputInPR appends the trailing lines of each script's output to the pull request body, in a collapsible section
+	IncludeScriptOutputInPR bool
+	// ScriptTimeout is how long each script may run against a repo before it is killed. Zero means scripts may run forever
+	ScriptTimeout time.Duration
+	// CloneDepth, when positive, limits each clone to that many of the most recent commits of each branch
+	CloneDepth int
+	// CloneCacheDir is the directory a mirror of each repo is kept in between runs, so that repos are fetched rather than cloned afresh
+	CloneCacheDir string
+	// KeepClones leaves the local clone of each repo in place once the repo is processed, for debugging
+	KeepClones bool
+	// SparseCheckoutPaths, when set, are the only directories of each repo that are checked out
+	SparseCheckoutPaths []string
+	// RepoHostName is the host of the repos operated on - one of github or gitlab
+	RepoHostName string
+	// GitlabURL is the GitLab instance repos are looked up on when RepoHostName is gitlab
+	GitlabURL string
+	// GitProtocol is the protocol repos are cloned and fetched over - one of https or ssh
+	GitProtocol string
+	// PushGitProtocol is the protocol branches are pushed over, when it differs from GitProtocol
+	PushGitProtocol string
+	// GithubBaseURL is the URL of the Github Enterprise Server instance repos are hosted on. Otherwise, GITHUB_BASE_URL or github.com is used
+	GithubBaseURL string
+	// GithubAppID is the ID of the Github App to authenticate as, instead of using GITHUB_OAUTH_TOKEN
+	GithubAppID int64
+	// GithubAppInstallationID is the ID of the installation of the Github App whose access tokens are used
+	GithubAppInstallationID int64
+	// GithubAppPrivateKeyFile is the path of the PEM encoded private key of the Github App
+	GithubAppPrivateKeyFile string
+	// MaxAPIRetries is the number of times a Github API call that was rate limited or failed with a server error is retried
+	MaxAPIRetries int
+	// MaxRateLimitWait is the longest a Github API call may wait for the rate limit to reset before it's given up on
+	MaxRateLimitWait time.Duration
+	// InlineCommands are shell commands to run on the given repo via `sh -c`, after any TargetScripts
+	InlineCommands []string
 	// CommitMessage will be used when committing any file changes to the branch
 	CommitMessage string
+	// IncludePaths are .gitignore style patterns of the changed files to commit. Otherwise, every changed file that isn't ignored is committed
+	IncludePaths []string
+	// ExcludePaths are .gitignore style patterns of the changed files to leave out of the commit
+	ExcludePaths []string
+	// AuthorName is the name commits are authored and committed as. Otherwise, user.name of the git config is used
+	AuthorName string
+	// AuthorEmail is the email address commits are authored and committed as. Otherwise, user.email of the git config is used
+	AuthorEmail string
+	// CoAuthors are credited on each commit via Co-authored-by trailers, each in the form "Name <email>"
+	CoAuthors []string
+	// SigningKey is the path of the GPG or SSH private key every commit is signed with. Commits are not signed when it's empty
+	SigningKey string
+	// SigningFormat is the kind of key SigningKey is - one of gpg or ssh
+	SigningFormat string
 	// The optional branch name the user can provide. Otherwise, this tool will default to its fallback of "git-xargs"
 	BranchName string
+	// BaseBranch is the optional branch to start from and open pull requests against. Otherwise, each repo's default branch is used
+	BaseBranch string
 	// PullRequestTitle will be used when opening the PR - so name it generally after what you are accomplishing with this run
 	PullRequestTitle string
 	// PullRequestDescription will be used when opening the PR - so provide some context around the changes you will be making with this run
 	PullRequestDescription string
+	// PullRequestReviewers are the logins of the users to request reviews of each opened pull request from
+	PullRequestReviewers []string
+	// PullRequestTeamReviewers are the slugs of the teams to request reviews of each opened pull request from
+	PullRequestTeamReviewers []string
+	// PullRequestLabels are the labels to add to each opened pull request
+	PullRequestLabels []string
+	// PullRequestAssignees are the logins of the users to assign each opened pull request to
+	PullRequestAssignees []string
+	// DraftPullRequest opens every pull request as a draft
+	DraftPullRequest bool
+	// PullRequestMilestone is the title, or number, of the milestone to add each opened pull request to
+	PullRequestMilestone string
+	// PullRequestDescriptionFile is the optional path of a file whose contents are used as the PullRequestDescription
+	PullRequestDescriptionFile string
+	// MaxConcurrentRepos is the number of repos that may be processed at the same time. Zero means every repo is processed at once
+	MaxConcurrentRepos int
+	// MaxConcurrentClones caps how many repos may be cloning at the same time, independently of MaxConcurrentRepos. Zero means no cap
+	MaxConcurrentClones int
+	// MaxConcurrentPushes caps how many repos may be pushing their branch at the same time. Zero means no cap
+	MaxConcurrentPushes int
+	// MaxConcurrentPullRequests caps how many pull request API calls may be in flight at the same time. Zero means no cap
+	MaxConcurrentPullRequests int
+	// UpdateExisting allows a run to add to a tool-specific branch that already exists and to edit its open pull request, rather than skipping the repo
+	UpdateExisting bool
+	// StateFile is the path the run-state journal is written to. Otherwise, a unique file in the system temp directory is used
+	StateFile string
+	// ResumeStateFile is the path of a run-state journal written by a previous run that should be resumed
+	ResumeStateFile string
+	// OutputFormat selects how the final run report is rendered to STDOUT - either "table" or "json"
+	OutputFormat string
+	// ReportFile is the optional path to which the final run report is written as JSON, regardless of OutputFormat
+	ReportFile string
 
 	log = logrus.New()
 )
@@ -42,21 +149,129 @@ func init() {
 	// Log messages that are of DEBUG level
 	log.SetLevel(logrus.DebugLevel)
 
-	rootCmd.PersistentFlags().StringVarP(&GithubOrg, "github-org", "o", "", "The Github organization whose repos should be operated on")
+	rootCmd.PersistentFlags().StringVarP(&GithubOrg, "github-org", "o", "", "The Github organization, or GitLab group, whose repos should be operated on")
+
+	rootCmd.PersistentFlags().StringVar(&GithubUser, "user", "", "The personal Github or GitLab account whose repos should be operated on")
+
+	rootCmd.PersistentFlags().StringVar(&RepoSearchQuery, "repo-search", "", "A Github repository search query, e.g., \"org:gruntwork-io terraform in:name\". Every matching repo will be operated on. With --repo-host gitlab, the text to search GitLab project names and paths for")
+
+	rootCmd.PersistentFlags().StringSliceVar(&RepoTopics, "topic", []string{}, "Only operate on repos that have this topic. May be repeated or comma separated, in which case repos must have every topic")
+
+	rootCmd.PersistentFlags().StringVar(&RepoLanguage, "language", "", "Only operate on repos whose primary language, as detected by Github, is this language. Not supported with --repo-host gitlab")
+
+	rootCmd.PersistentFlags().BoolVar(&ExcludeArchived, "exclude-archived", false, "Do not operate on archived repos")
+
+	rootCmd.PersistentFlags().BoolVar(&ExcludeForks, "exclude-forks", false, "Do not operate on forked repos")
+
+	rootCmd.PersistentFlags().StringVar(&IncludeRepoRegex, "include-repo-regex", "", "Only operate on repos whose name matches this regular expression")
+
+	rootCmd.PersistentFlags().StringVar(&ExcludeRepoRegex, "exclude-repo-regex", "", "Do not operate on repos whose name matches this regular expression")
 
 	rootCmd.PersistentFlags().BoolVarP(&DryRun, "dry-run", "d", false, "When dry-run is set to true, only proposed YAML updates will be output, but not changes in Github will be made (no branches will be created, no files updated, no PRs opened)")
 
-	rootCmd.PersistentFlags().StringVarP(&AllowedReposFile, "allowed-repos-filepath", "a", "", "The path to the file containing repos this tool is allowed to operate on, each repo in format: gruntwork-io/terraform-aws-eks, one repo per line")
+	rootCmd.PersistentFlags().StringVarP(&AllowedReposFile, "allowed-repos-filepath", "a", "", "The path to the file containing repos this tool is allowed to operate on, each repo in format: gruntwork-io/terraform-aws-eks, one repo per line. Pass - to read repos from STDIN")
+
+	// --repos is a shorter alias of --allowed-repos-filepath, e.g., for piping: gh repo list gruntwork-io --json nameWithOwner -q '.[].nameWithOwner' | git-xargs --repos - ...
+	rootCmd.PersistentFlags().StringVar(&AllowedReposFile, "repos", "", "Alias of --allowed-repos-filepath. Pass - to read repos from STDIN, one per line, as org/repo, a full Github URL or an SSH remote")
+
+	// --scripts is an array rather than a slice flag, since a slice flag is parsed as CSV, which would split a script's
+	// arguments on commas and reject any that are double quoted
+	rootCmd.PersistentFlags().StringArrayVarP(&TargetScripts, "scripts", "s", []string{}, "A script to run against the selected repos. Scripts must exist in the ./scripts directory and be executable. May be passed multiple times. Each script may be followed by arguments to pass to it, e.g., --scripts \"update.sh --flag\"")
+
+	// --cmd is an array rather than a slice flag, so that commas within a command, e.g., in a sed expression, are not split on
+	rootCmd.PersistentFlags().StringArrayVar(&InlineCommands, "cmd", []string{}, "A shell command to run via sh -c in the root of each selected repo, instead of or after --scripts, e.g., --cmd \"go mod tidy\". May be passed multiple times. Each command is recorded in the pull request description")
+
+	rootCmd.PersistentFlags().DurationVar(&ScriptTimeout, "script-timeout", 0, "How long each script may run against a repo, e.g., 5m, before it and every process it spawned are killed and the repo is marked as failed. 0 means no timeout")
+
+	rootCmd.PersistentFlags().IntVar(&CloneDepth, "clone-depth", 0, "Only clone this many of the most recent commits of each repo, which speeds up cloning large repos. 0 clones the full history")
+
+	rootCmd.PersistentFlags().StringVar(&CloneCacheDir, "clone-cache-dir", "", "A directory to keep a mirror of each repo in between runs. Repos already in the cache are fetched rather than cloned afresh from Github, and each run clones from the mirror")
+
+	rootCmd.PersistentFlags().StringSliceVar(&SparseCheckoutPaths, "sparse-checkout", []string{}, "Only check out this directory of each repo, relative to its root, e.g., --sparse-checkout modules/vpc, which speeds up working on large repos. May be repeated or comma separated. The rest of each repo is left untouched by the commit")
+
+	rootCmd.PersistentFlags().BoolVar(&KeepClones, "keep-clones", false, "Leave the local clone of each repo in the system temp directory once it's processed, rather than deleting it, for debugging")
+
+	rootCmd.PersistentFlags().StringVar(&RepoHostName, "repo-host", RepoHostGithub, "Where the repos are hosted. One of: github, or gitlab, which authenticates with GITLAB_TOKEN and opens merge requests rather than pull requests")
+
+	rootCmd.PersistentFlags().StringVar(&GitlabURL, "gitlab-url", DefaultGitlabURL, "The URL of the GitLab instance the repos are hosted on, when passing --repo-host gitlab, e.g., https://gitlab.example.com")
+
+	rootCmd.PersistentFlags().StringVar(&GitProtocol, "git-protocol", GitProtocolHTTPS, "The protocol to clone repos over. One of: https, which authenticates with the Github token, or ssh, which authenticates with the keys loaded into the running SSH agent")
+
+	rootCmd.PersistentFlags().StringVar(&PushGitProtocol, "push-git-protocol", "", "The protocol to push branches over, when it should differ from --git-protocol. One of: https, ssh. When pushing over https, GITHUB_PUSH_TOKEN is used instead of the Github token if set")
+
+	rootCmd.PersistentFlags().StringVar(&GithubBaseURL, "github-base-url", "", "The URL of the Github Enterprise Server instance the repos are hosted on, e.g., https://github.example.com. Defaults to GITHUB_BASE_URL if set, or else github.com")
+
+	rootCmd.PersistentFlags().Int64Var(&GithubAppID, "github-app-id", 0, "The ID of a Github App to authenticate as, instead of using GITHUB_OAUTH_TOKEN. Requires --github-app-installation-id and --github-app-private-key-file")
+
+	rootCmd.PersistentFlags().Int64Var(&GithubAppInstallationID, "github-app-installation-id", 0, "The ID of the installation of the Github App, on the organization or account whose repos are operated on")
+
+	rootCmd.PersistentFlags().StringVar(&GithubAppPrivateKeyFile, "github-app-private-key-file", "", "The path of the PEM encoded private key of the Github App")
+
+	rootCmd.PersistentFlags().IntVar(&MaxAPIRetries, "max-api-retries", 5, "The number of times a Github API call that was rate limited or failed with a server error is retried, with exponential backoff, before it's given up on")
+
+	rootCmd.PersistentFlags().DurationVar(&MaxRateLimitWait, "max-rate-limit-wait", time.Hour, "The longest a Github API call may pause for the rate limit to reset before it's given up on")
+
+	rootCmd.PersistentFlags().StringVar(&ScriptLogDir, "script-log-dir", "", "The directory to store the output of every script run against every repo in, one log file per repo per script. Defaults to a unique directory in the system temp directory")
+
+	rootCmd.PersistentFlags().IntVar(&ScriptOutputLines, "script-output-lines", 20, "The number of trailing lines of script output to show for each failed script in the run report, and in pull request descriptions when --pull-request-script-output is set")
+
+	rootCmd.PersistentFlags().BoolVar(&IncludeScriptOutputInPR, "pull-request-script-output", false, "Append the trailing lines of each script's output to the pull request description, in a collapsible section")
 
-	rootCmd.PersistentFlags().StringSliceVarP(&TargetScripts, "scripts", "s", []string{}, "The scripts to run against the selected repos. These scripts must exist in the ./scripts directory and be executable.")
+	rootCmd.PersistentFlags().StringSliceVar(&IncludePaths, "include", []string{}, "Only commit the files changed by the scripts that match this .gitignore style pattern, e.g., --include \"*.tf\". May be repeated or comma separated. Files ignored by the repo are never committed")
+
+	rootCmd.PersistentFlags().StringSliceVar(&ExcludePaths, "exclude", []string{}, "Leave the files changed by the scripts that match this .gitignore style pattern out of the commit, e.g., --exclude \"docs/\". May be repeated or comma separated")
+
+	rootCmd.PersistentFlags().StringVar(&AuthorName, "author-name", "", "The name to author and commit changes as. Requires --author-email. Defaults to user.name of the git config")
+
+	rootCmd.PersistentFlags().StringVar(&AuthorEmail, "author-email", "", "The email address to author and commit changes as. Requires --author-name. Defaults to user.email of the git config")
+
+	rootCmd.PersistentFlags().StringArrayVar(&CoAuthors, "co-author", []string{}, "A co-author to credit on each commit via a Co-authored-by trailer, e.g., --co-author \"Jane Doe <jane@example.com>\". May be passed multiple times")
+
+	rootCmd.PersistentFlags().StringVar(&SigningKey, "signing-key", "", "The path of a private key to sign every commit with. If the key is passphrase protected, the passphrase is read from GIT_XARGS_SIGNING_KEY_PASSPHRASE")
+
+	rootCmd.PersistentFlags().StringVar(&SigningFormat, "signing-format", SigningFormatGPG, "The kind of key passed via --signing-key. One of: gpg, for an ASCII armored OpenPGP private key, or ssh, for an SSH private key")
 
 	rootCmd.PersistentFlags().StringVarP(&BranchName, "branch-name", "b", "git-xargs", "The name of the branch you want created to hold your changes")
 
-	rootCmd.PersistentFlags().StringVarP(&CommitMessage, "commit-message", "m", "Tis I, git-xargs!", "The commit message to use for any programmatic commits made by this tool")
+	rootCmd.PersistentFlags().StringVar(&BaseBranch, "base-branch", "", "The branch to create your branch from and open pull requests against. Defaults to each repo's default branch as reported by Github")
+
+	rootCmd.PersistentFlags().StringVarP(&CommitMessage, "commit-message", "m", "Tis I, git-xargs!", "The commit message to use for any programmatic commits made by this tool. May be a Go template, e.g., \"Update {{.RepoName}}\"")
+
+	rootCmd.PersistentFlags().StringVarP(&PullRequestTitle, "pull-request-title", "t", "git-xargs programmatic pr", "The title to add to the pull requests that will be opened by this run. May be a Go template, e.g., \"Update {{.RepoName}}\"")
+
+	rootCmd.PersistentFlags().StringVarP(&PullRequestDescription, "pull-request-description", "e", "This pull request was opened programmatically by the git-xargs CLI.", "The description to add to the pull requests that will be opened by this run. May be a Go template, e.g., \"Updates {{.RepoFullName}}\"")
+
+	rootCmd.PersistentFlags().StringVar(&PullRequestDescriptionFile, "pull-request-description-file", "", "The path of a file whose contents are used as the description of the pull requests opened by this run, instead of --pull-request-description. May be a Go template, like --pull-request-description")
+
+	rootCmd.PersistentFlags().StringSliceVar(&PullRequestReviewers, "reviewers", []string{}, "The Github users to request reviews of each pull request from, e.g., --reviewers alice,bob")
+
+	rootCmd.PersistentFlags().StringSliceVar(&PullRequestTeamReviewers, "team-reviewers", []string{}, "The slugs of the Github teams to request reviews of each pull request from, e.g., --team-reviewers platform")
+
+	rootCmd.PersistentFlags().StringSliceVar(&PullRequestLabels, "labels", []string{}, "The labels to add to each pull request. Labels that don't exist in a repo yet are created")
+
+	rootCmd.PersistentFlags().StringSliceVar(&PullRequestAssignees, "assignees", []string{}, "The Github users to assign each pull request to")
+
+	rootCmd.PersistentFlags().BoolVar(&DraftPullRequest, "draft", false, "Open every pull request as a draft")
 
-	rootCmd.PersistentFlags().StringVarP(&PullRequestTitle, "pull-request-title", "t", "git-xargs programmatic pr", "The title to add to the pull requests that will be opened by this run")
+	rootCmd.PersistentFlags().StringVar(&PullRequestMilestone, "milestone", "", "The title of the open milestone to add each pull request to. A number is used as the milestone number instead")
 
-	rootCmd.PersistentFlags().StringVarP(&PullRequestDescription, "pull-request-description", "e", "This pull request was opened programmatically by the git-xargs CLI.", "The description to add to the pull requests that will be opened by this run")
+	rootCmd.PersistentFlags().IntVar(&MaxConcurrentRepos, "max-concurrent-repos", 0, "The maximum number of repos to process at the same time. Remaining repos wait in a queue. 0 means process every repo at once")
+
+	rootCmd.PersistentFlags().IntVar(&MaxConcurrentClones, "max-concurrent-clones", 0, "The maximum number of repos that may be cloning at the same time. 0 means no limit beyond --max-concurrent-repos")
+
+	rootCmd.PersistentFlags().IntVar(&MaxConcurrentPushes, "max-concurrent-pushes", 0, "The maximum number of repos that may be pushing their branch at the same time. 0 means no limit beyond --max-concurrent-repos")
+
+	rootCmd.PersistentFlags().IntVar(&MaxConcurrentPullRequests, "max-concurrent-pr-calls", 0, "The maximum number of pull request API calls that may be in flight at the same time. 0 means no limit beyond --max-concurrent-repos")
+
+	rootCmd.PersistentFlags().StringVar(&OutputFormat, "output-format", OutputFormatTable, "The format of the run report printed to STDOUT at the end of the run. One of: table, json")
+
+	rootCmd.PersistentFlags().StringVar(&ReportFile, "report-file", "", "The optional path of a file to write the run report to as JSON, in addition to the report printed to STDOUT")
+
+	rootCmd.PersistentFlags().BoolVar(&UpdateExisting, "update-existing", false, "When the branch already exists (e.g., from a previous run), check it out, run the scripts on top of it, push and edit the title and body of its open pull request instead of skipping the repo")
+
+	rootCmd.PersistentFlags().StringVar(&StateFile, "state-file", "", "The path to write the run-state journal to, recording how far each repo got so the run can be resumed. Defaults to a unique file in the system temp directory")
+
+	rootCmd.PersistentFlags().StringVar(&ResumeStateFile, "resume", "", "The path of a run-state journal written by a previous run. Repos it completed are skipped, and failed or unfinished repos are retried")
 
 	rootCmd.AddCommand(versionCmd)
 }
@@ -90,18 +305,172 @@ func persistentPreRun(cmd *cobra.Command, args []string) {
 		log.Fatal("All required dependencies must be installed prior to running this tool")
 	}
 
+	if !isValidOutputFormat(OutputFormat) {
+		log.WithFields(logrus.Fields{
+			"Output format": OutputFormat,
+		}).Fatal("Invalid --output-format. Must be one of: table, json")
+	}
+
+	if PullRequestDescriptionFile != "" {
+		if cmd.Flags().Changed("pull-request-description") {
+			log.Fatal("Pass only one of --pull-request-description and --pull-request-description-file")
+		}
+
+		description, readErr := readPullRequestDescriptionFile(PullRequestDescriptionFile)
+		if readErr != nil {
+			log.WithFields(logrus.Fields{
+				"Error":    readErr,
+				"Filepath": PullRequestDescriptionFile,
+			}).Fatal("Error reading --pull-request-description-file")
+		}
+		PullRequestDescription = description
+	}
+
+	// Fail fast on broken message templates, rather than once per repo after cloning it and running the scripts
+	if templatesErr := verifyMessageTemplates(CommitMessage, PullRequestTitle, PullRequestDescription); templatesErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": templatesErr,
+		}).Fatal("Invalid commit message or pull request template")
+	}
+
+	if ScriptTimeout < 0 {
+		log.WithFields(logrus.Fields{
+			"Script timeout": ScriptTimeout,
+		}).Fatal("--script-timeout must not be negative")
+	}
+
+	if !isValidRepoHost(RepoHostName) {
+		log.WithFields(logrus.Fields{
+			"Repo host": RepoHostName,
+		}).Fatal("Invalid --repo-host. Must be one of: github, gitlab")
+	}
+
+	// GitLab doesn't report a primary language when listing projects, so --language would silently filter out every repo
+	if RepoHostName == RepoHostGitlab && RepoLanguage != "" {
+		log.WithFields(logrus.Fields{
+			"Language": RepoLanguage,
+		}).Fatal("--language is not supported with --repo-host gitlab")
+	}
+
+	if _, baseURLErr := githubEnterpriseAPIURL(); baseURLErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error":           baseURLErr,
+			"Github base URL": githubBaseURL(),
+		}).Fatal("Invalid --github-base-url or GITHUB_BASE_URL")
+	}
+
+	if !isValidGitProtocol(GitProtocol) || (PushGitProtocol != "" && !isValidGitProtocol(PushGitProtocol)) {
+		log.WithFields(logrus.Fields{
+			"Git protocol":      GitProtocol,
+			"Push git protocol": PushGitProtocol,
+		}).Fatal("Invalid --git-protocol or --push-git-protocol. Must be one of: https, ssh")
+	}
+
+	if (AuthorName == "") != (AuthorEmail == "") {
+		log.WithFields(logrus.Fields{
+			"Author name":  AuthorName,
+			"Author email": AuthorEmail,
+		}).Fatal("Pass both --author-name and --author-email, or neither")
+	}
+
+	for _, coAuthor := range CoAuthors {
+		if !isValidCoAuthor(coAuthor) {
+			log.WithFields(logrus.Fields{
+				"Co-author": coAuthor,
+			}).Fatal("Invalid --co-author. Must be a name followed by an email address in angle brackets, e.g., \"Jane Doe <jane@example.com>\"")
+		}
+	}
+
+	if !isValidSigningFormat(SigningFormat) {
+		log.WithFields(logrus.Fields{
+			"Signing format": SigningFormat,
+		}).Fatal("Invalid --signing-format. Must be one of: gpg, ssh")
+	}
+
+	// Fail fast on a missing key or wrong passphrase, rather than on the first commit after cloning a repo
+	if _, signerErr := ConfigureCommitSigner(SigningFormat, SigningKey, os.Getenv("GIT_XARGS_SIGNING_KEY_PASSPHRASE")); signerErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error":       signerErr,
+			"Signing key": SigningKey,
+		}).Fatal("Error loading --signing-key")
+	}
+
+	if MaxAPIRetries < 0 {
+		log.WithFields(logrus.Fields{
+			"Max API retries": MaxAPIRetries,
+		}).Fatal("--max-api-retries must not be negative")
+	}
+
+	if CloneDepth < 0 {
+		log.WithFields(logrus.Fields{
+			"Clone depth": CloneDepth,
+		}).Fatal("--clone-depth must not be negative")
+	}
+
+	sparseCheckoutPaths, sparseErr := normalizeSparseCheckoutPaths(SparseCheckoutPaths)
+	if sparseErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": sparseErr,
+		}).Fatal("Invalid --sparse-checkout")
+	}
+	SparseCheckoutPaths = sparseCheckoutPaths
+
+	if ScriptOutputLines < 0 {
+		log.WithFields(logrus.Fields{
+			"Script output lines": ScriptOutputLines,
+		}).Fatal("--script-output-lines must not be negative")
+	}
+
 	// If DryRun is enabled, notify user that no file changes will be made
 	if DryRun {
 		log.Debug("Dry run setting enabled. No actual file changes, branches or PRs will be created in Github")
 	}
 
-	// If user didn't provide either means of looking up repos, bail out with a helpful error
-	if !ensureValidOptionsPassed(AllowedReposFile, GithubOrg) {
-		log.Fatal("You must either provide an AllowedReposFile path or a GithubOrg. See ./git-xargs help")
+	// If no other means of looking up repos was provided but a list is being piped in, read the repos from STDIN
+	if !ensureValidOptionsPassed(AllowedReposFile, GithubOrg, GithubUser, RepoSearchQuery) && stdinIsPiped() {
+		log.Debug("No repo selection flags passed, but STDIN is piped. Reading repos from STDIN")
+		AllowedReposFile = StdinRepoSource
+	}
+
+	// If user didn't provide any means of looking up repos, bail out with a helpful error
+	if !ensureValidOptionsPassed(AllowedReposFile, GithubOrg, GithubUser, RepoSearchQuery) {
+		log.Fatal("You must provide an AllowedReposFile path, a GithubOrg, a Github user or a repo search query. See ./git-xargs help")
+
+	}
 
+	// Fail fast on malformed repo name regexes, rather than after looking up every repo
+	if _, filtersErr := NewRepoFilters(RepoTopics, RepoLanguage, ExcludeArchived, ExcludeForks, IncludeRepoRegex, ExcludeRepoRegex); filtersErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": filtersErr,
+		}).Fatal("Invalid repo filter")
 	}
 }
 
+// cancelOnInterrupt returns a context that is cancelled the first time the operator sends SIGINT or SIGTERM, so that the
+// run can stop picking up new repos, let in-flight repos finish and still print its final report. After the first signal
+// the handler is removed, so a second Ctrl+C terminates the process immediately
+func cancelOnInterrupt() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-interrupts:
+			log.WithFields(logrus.Fields{
+				"Signal": sig,
+			}).Debug("Received interrupt. Waiting for in-progress repos to finish before printing the report. Interrupt again to exit immediately")
+			signal.Stop(interrupts)
+			cancel()
+		case <-ctx.Done():
+			signal.Stop(interrupts)
+		}
+	}()
+
+	return ctx, cancel
+}
+
 var rootCmd = &cobra.Command{
 	Use:              "git-xargs",
 	Short:            "git-xargs CLI",
@@ -110,40 +479,87 @@ var rootCmd = &cobra.Command{
 	Run: func(cmd *cobra.Command, args []string) {
 		log.Debug("git-xargs running...")
 
-		// Verify the scripts that will be run against the repos and package them into a ScriptCollection
-		scriptCollection, verifyErr := VerifyScripts(TargetScripts)
+		// Verify the scripts that will be run against the repos and package them into a ScriptCollection. Scripts are
+		// optional when inline commands were passed via --cmd instead
+		var scriptCollection ScriptCollection
+		if len(TargetScripts) > 0 || len(InlineCommands) == 0 {
+			var verifyErr error
+			scriptCollection, verifyErr = VerifyScripts(TargetScripts)
 
-		if verifyErr != nil {
+			if verifyErr != nil {
+				log.WithFields(logrus.Fields{
+					"Error": verifyErr,
+				}).Fatal("Error verifying scripts passed via --scripts flag. Please fix scripts with issues and re-run")
+			}
+		}
+
+		// Inline commands run after any scripts, in the order they were passed
+		if commandsErr := VerifyInlineCommands(InlineCommands, &scriptCollection); commandsErr != nil {
 			log.WithFields(logrus.Fields{
-				"Error": verifyErr,
-			}).Fatal("Error verifying scripts passed via --scripts flag. Please fix scripts with issues and re-run")
+				"Error": commandsErr,
+			}).Fatal("Error verifying commands passed via --cmd flag. Please fix commands with issues and re-run")
 		}
 
 		// If no valid scripts were returned by the validation function, we have nothing to execute, so must exit with an error
 		if len(scriptCollection.Scripts) == 0 {
 			log.WithFields(logrus.Fields{
 				"User provided scripts": TargetScripts,
-			}).Fatal("No valid scripts found to execute. Ensure each script exists in the ./scripts directory, is executable, and was not misspelled when provided via the --scripts flag")
+			}).Fatal("No valid scripts found to execute. Ensure each script exists in the ./scripts directory, is executable, and was not misspelled when provided via the --scripts flag, or pass a command via --cmd")
 		}
 
-		// Configure the client that will make Github API calls on our behalf, using the user-provided Github personal access token
-		GithubClient := ConfigureGithubClient()
+		// Cancel the run gracefully if the operator hits Ctrl+C, so that the repos that did finish are still reported on
+		ctx, cancel := cancelOnInterrupt()
+		defer cancel()
+
+		// Configure the host that API calls will be made to on our behalf, using the user-provided Github or GitLab token
+		host := ConfigureRepoHost(ctx)
 
 		// Configure a stats tracker that can be passed along to keep tallies of which repos fell into which categories, how many were modified, etc
 		stats := NewStatsTracker()
 
+		// Journal each repo's progress to disk, so that if this run dies part way through it can be resumed via --resume
+		journal, journalErr := configureRunJournal(ResumeStateFile, StateFile, BranchName, DryRun)
+		if journalErr != nil {
+			log.WithFields(logrus.Fields{
+				"Error":  journalErr,
+				"Resume": ResumeStateFile,
+			}).Fatal("Error setting up the run-state file")
+		}
+		stats.SetJournal(journal)
+
+		log.WithFields(logrus.Fields{
+			"State file": journal.Path(),
+		}).Debug("Journalling run state. Pass this file to --resume to pick up where this run left off")
+
+		// Store every script's output on disk, so that failures can be diagnosed after the run without re-running anything
+		logDir, logDirErr := configureScriptLogDir(ScriptLogDir)
+		if logDirErr != nil {
+			log.WithFields(logrus.Fields{
+				"Error":          logDirErr,
+				"Script log dir": logDir,
+			}).Fatal("Error creating the script log directory")
+		}
+		ScriptLogDir = logDir
+
+		log.WithFields(logrus.Fields{
+			"Script log dir": ScriptLogDir,
+		}).Debug("Storing the output of every script in the script log directory")
+
 		var fileProvidedRepos []*AllowedRepo
 
 		// User provided a flatfile of repos to explicitly operate on, which we'll prefer over --github-org
 		if AllowedReposFile != "" {
 			// Call the allowed repos parsing function
-			allowedRepos, err := processAllowedRepos(AllowedReposFile)
+			allowedRepos, malformedLines, err := processAllowedRepos(AllowedReposFile)
 			if err != nil {
 				log.WithFields(logrus.Fields{
 					"Error":    err,
 					"Filepath": AllowedReposFile,
 				}).Debug("error processing allowed repos from file")
 			}
+
+			// Report any lines that didn't name a repo, rather than silently dropping them
+			trackMalformedRepoLines(malformedLines, stats)
 			// fileProvidedRepos, when set, will be preferred by ConvertReposContexts over the user-passed in github-org flag
 			fileProvidedRepos = allowedRepos
 
@@ -151,8 +567,11 @@ var rootCmd = &cobra.Command{
 			stats.SetFileProvidedRepos(allowedRepos)
 
 		}
+		// Build the filters that narrow down the selected repos. These were already validated in persistentPreRun
+		filters, _ := NewRepoFilters(RepoTopics, RepoLanguage, ExcludeArchived, ExcludeForks, IncludeRepoRegex, ExcludeRepoRegex)
+
 		// Update repos to use the target context, where applicable
-		OperateOnRepos(GithubClient, GithubOrg, fileProvidedRepos, scriptCollection, stats)
+		OperateOnRepos(ctx, host, GithubOrg, fileProvidedRepos, filters, scriptCollection, stats)
 
 		// Once all processing is complete, print out the summary of what was done
 		stats.PrintReport()
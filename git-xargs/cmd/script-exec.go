@@ -0,0 +1,95 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scriptKillGracePeriod is how long to wait for a killed script's output to be drained before giving up on it, in case
+// something it spawned escaped its process group and is still holding STDOUT or STDERR open
+const scriptKillGracePeriod = 5 * time.Second
+
+// ScriptTimeoutError is returned when a script is killed for running longer than --script-timeout
+type ScriptTimeoutError struct {
+	Timeout time.Duration
+}
+
+func (e ScriptTimeoutError) Error() string {
+	return fmt.Sprintf("script did not finish within %s and was killed", e.Timeout)
+}
+
+// lockedBuffer is a bytes.Buffer that is safe to read while the command it is attached to is still writing to it
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) Bytes() []byte {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// runScriptCommand runs the command to completion and returns its combined STDOUT and STDERR. The command is started in
+// its own process group, so that if timeout is positive and the command is still running once it elapses, the command
+// and everything it spawned can be killed together, in which case a ScriptTimeoutError is returned along with whatever
+// output was produced before the kill
+func runScriptCommand(cmd *exec.Cmd, timeout time.Duration) ([]byte, error) {
+	var output lockedBuffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	setProcessGroup(cmd)
+
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	if timeout <= 0 {
+		// The command must have exited before its output is read, so this can't be folded into the return statement,
+		// whose operands are evaluated left to right
+		err := <-done
+		return output.Bytes(), err
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	select {
+	case err := <-done:
+		return output.Bytes(), err
+	case <-timer.C:
+	}
+
+	if killErr := killProcessGroup(cmd); killErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": killErr,
+			"Pid":   cmd.Process.Pid,
+		}).Debug("Error killing the process group of a script that timed out")
+	}
+
+	select {
+	case <-done:
+	case <-time.After(scriptKillGracePeriod):
+		log.WithFields(logrus.Fields{
+			"Pid": cmd.Process.Pid,
+		}).Debug("Script that timed out still hasn't exited after being killed, moving on without it")
+	}
+
+	return output.Bytes(), ScriptTimeoutError{Timeout: timeout}
+}
@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRunScriptCommandReturnsOutputWithinTimeout(t *testing.T) {
+	output, err := runScriptCommand(exec.Command("sh", "-c", "echo out; echo err >&2"), time.Minute)
+
+	assert.NoError(t, err)
+	assert.Equal(t, string(output), "out\nerr\n")
+}
+
+func TestRunScriptCommandReturnsOutputWithoutTimeout(t *testing.T) {
+	output, err := runScriptCommand(exec.Command("sh", "-c", "sleep 0.2; echo out; echo err >&2"), 0)
+
+	assert.NoError(t, err)
+	assert.Equal(t, string(output), "out\nerr\n")
+}
+
+func TestRunScriptCommandKillsHungScript(t *testing.T) {
+	start := time.Now()
+	output, err := runScriptCommand(exec.Command("sh", "-c", "echo started; sleep 30"), 200*time.Millisecond)
+
+	assert.Equal(t, err, ScriptTimeoutError{Timeout: 200 * time.Millisecond})
+	assert.Equal(t, string(output), "started\n")
+	assert.True(t, time.Since(start) < 10*time.Second)
+}
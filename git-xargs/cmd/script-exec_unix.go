@@ -0,0 +1,21 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup starts the command as the leader of a new process group, so that it can be killed along with every
+// process it spawns
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
+
+// killProcessGroup kills every process in the started command's process group
+func killProcessGroup(cmd *exec.Cmd) error {
+	// A negative pid signals the whole process group whose ID is the command's pid
+	return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+}
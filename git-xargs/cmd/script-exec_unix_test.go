@@ -0,0 +1,43 @@
+//go:build !windows
+// +build !windows
+
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunScriptCommandKillsWholeProcessGroup(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-xargs-script-timeout-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	// The script backgrounds a child of its own and records its pid before hanging, so that the test can check the child
+	// was killed along with the script
+	pidFile := filepath.Join(dir, "child.pid")
+	cmd := exec.Command("sh", "-c", "sleep 30 & echo $! > "+pidFile+"; wait")
+
+	_, runErr := runScriptCommand(cmd, 500*time.Millisecond)
+	assert.IsType(t, ScriptTimeoutError{}, runErr)
+
+	contents, err := ioutil.ReadFile(pidFile)
+	require.NoError(t, err)
+	childPid, err := strconv.Atoi(strings.TrimSpace(string(contents)))
+	require.NoError(t, err)
+
+	// Signal 0 only checks whether the process still exists
+	assert.Eventually(t, func() bool {
+		return syscall.Kill(childPid, 0) == syscall.ESRCH
+	}, 5*time.Second, 50*time.Millisecond)
+}
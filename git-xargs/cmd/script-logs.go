@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// unsafeLogNameChars matches every character that should not appear in the name of a script's log file
+var unsafeLogNameChars = regexp.MustCompile(`[^\w.-]+`)
+
+// configureScriptLogDir creates the directory that every script's output is stored in, and returns the directory's path.
+// If logDir is empty, a new directory with a unique name is created in the system temp directory, so that runs started
+// at the same time never write to each other's logs
+func configureScriptLogDir(logDir string) (string, error) {
+	if logDir == "" {
+		return ioutil.TempDir("", "git-xargs-logs-*")
+	}
+	return logDir, os.MkdirAll(logDir, 0755)
+}
+
+// scriptLogPath returns the path of the log file the output of the script at the given position in the ScriptCollection
+// is stored in for the repo. Logs are grouped per repo, and numbered so that they sort in the order the scripts ran
+func scriptLogPath(logDir string, repo *github.Repository, index int, script Script) string {
+	name := "cmd"
+	if script.Command == "" {
+		name = filepath.Base(script.Path)
+	}
+	name = unsafeLogNameChars.ReplaceAllString(name, "_")
+
+	return filepath.Join(logDir, filepath.FromSlash(journalKey(repo)), fmt.Sprintf("%02d-%s.log", index+1, name))
+}
+
+// writeScriptLog stores the combined STDOUT and STDERR of running the script against the repo in the log directory,
+// preceded by the script as it was run, and returns the path of the log file. Nothing is stored if logDir is empty
+func writeScriptLog(logDir string, repo *github.Repository, index int, script Script, output []byte) (string, error) {
+	if logDir == "" {
+		return "", nil
+	}
+
+	logPath := scriptLogPath(logDir, repo, index, script)
+	if err := os.MkdirAll(filepath.Dir(logPath), 0755); err != nil {
+		return "", err
+	}
+
+	contents := append([]byte(fmt.Sprintf("$ %s\n", script.String())), output...)
+	if err := ioutil.WriteFile(logPath, contents, 0644); err != nil {
+		return "", err
+	}
+
+	return logPath, nil
+}
+
+// storeScriptLog is like writeScriptLog, but logs rather than returns any error, since failing to store a log should
+// never fail the repo itself
+func storeScriptLog(logDir string, repo *github.Repository, index int, script Script, output []byte) string {
+	logPath, err := writeScriptLog(logDir, repo, index, script, output)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":  err,
+			"Repo":   repo.GetName(),
+			"Script": script.String(),
+		}).Debug("Error storing script output log")
+	}
+	return logPath
+}
+
+// tailLines returns the last n lines of the output, without its trailing newline. A non-positive n returns nothing
+func tailLines(output string, n int) string {
+	if n <= 0 {
+		return ""
+	}
+
+	lines := strings.Split(strings.TrimRight(output, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// scriptOutputDetails renders the last n lines of the output of each script as a collapsible section, to be appended to
+// the body of a pull request so that reviewers can see what the scripts reported without digging through logs
+func scriptOutputDetails(outputs []ScriptOutput, n int) string {
+	if len(outputs) == 0 || n <= 0 {
+		return ""
+	}
+
+	var details strings.Builder
+	details.WriteString("<details>\n<summary>Output of scripts run by git-xargs</summary>\n\n")
+	for _, output := range outputs {
+		details.WriteString(fmt.Sprintf("`%s`\n\n```\n%s\n```\n\n", output.Script, tailLines(output.Output, n)))
+	}
+	details.WriteString("</details>\n")
+
+	return details.String()
+}
@@ -0,0 +1,59 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteScriptLogStoresOutputPerRepoPerScript(t *testing.T) {
+	logDir, err := ioutil.TempDir("", "git-xargs-script-logs-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+
+	repo := makeTestRepo()
+
+	scriptLog, err := writeScriptLog(logDir, repo, 0, Script{Path: "/tmp/add-license.sh", Args: []string{"--year", "2021"}}, []byte("added LICENSE\n"))
+	require.NoError(t, err)
+	commandLog, err := writeScriptLog(logDir, repo, 1, Script{Command: "go mod tidy"}, []byte("go: downloading\n"))
+	require.NoError(t, err)
+
+	assert.Equal(t, scriptLog, filepath.Join(logDir, "gruntwork-io", "cloud-nuke", "01-add-license.sh.log"))
+	assert.Equal(t, commandLog, filepath.Join(logDir, "gruntwork-io", "cloud-nuke", "02-cmd.log"))
+
+	contents, err := ioutil.ReadFile(scriptLog)
+	require.NoError(t, err)
+	assert.Equal(t, string(contents), "$ /tmp/add-license.sh --year 2021\nadded LICENSE\n")
+}
+
+func TestWriteScriptLogSkippedWithoutLogDir(t *testing.T) {
+	logFile, err := writeScriptLog("", makeTestRepo(), 0, Script{Path: "/tmp/add-license.sh"}, []byte("added LICENSE\n"))
+
+	assert.NoError(t, err)
+	assert.Equal(t, logFile, "")
+}
+
+func TestTailLines(t *testing.T) {
+	output := "one\ntwo\nthree\nfour\n"
+
+	assert.Equal(t, tailLines(output, 2), "three\nfour")
+	assert.Equal(t, tailLines(output, 10), "one\ntwo\nthree\nfour")
+	assert.Equal(t, tailLines(output, 0), "")
+}
+
+func TestScriptOutputDetailsIsCollapsible(t *testing.T) {
+	outputs := []ScriptOutput{
+		{Script: "/tmp/add-license.sh", Output: "one\ntwo\nthree\n"},
+	}
+
+	details := scriptOutputDetails(outputs, 2)
+
+	assert.Contains(t, details, "<details>")
+	assert.Contains(t, details, "`/tmp/add-license.sh`\n\n```\ntwo\nthree\n```")
+	assert.Contains(t, details, "</details>")
+	assert.Equal(t, scriptOutputDetails(nil, 2), "")
+}
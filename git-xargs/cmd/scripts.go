@@ -2,10 +2,14 @@ package cmd
 
 import (
 	"errors"
+	"fmt"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
+	"unicode"
 
+	"github.com/google/go-github/v32/github"
 	"github.com/sirupsen/logrus"
 )
 
@@ -26,8 +30,22 @@ func VerifyScripts(scriptPaths []string) (ScriptCollection, error) {
 	}
 
 	// Ensure that every script can be read by the tool, ensuring there were no naming or permissions issues
-	for _, scriptPath := range scriptPaths {
-		scriptPath = strings.TrimSpace(scriptPath)
+	for _, scriptWithArgs := range scriptPaths {
+		// Each entry may carry arguments after the path to the script, e.g., "update.sh --flag 'some value'"
+		scriptFields, splitErr := splitScriptArgs(strings.TrimSpace(scriptWithArgs))
+		if splitErr != nil {
+			log.WithFields(logrus.Fields{
+				"Error":  splitErr,
+				"Script": scriptWithArgs,
+			}).Debug("Could not split script into its path and arguments")
+			return sc, splitErr
+		}
+
+		if len(scriptFields) == 0 {
+			return sc, errors.New("Script paths must not be empty")
+		}
+
+		scriptPath := scriptFields[0]
 		// Check if relative path was passed for script and build it into an absolute path
 		if !filepath.IsAbs(scriptPath) {
 			abs, absErr := filepath.Abs(scriptPath)
@@ -77,6 +95,7 @@ func VerifyScripts(scriptPaths []string) (ScriptCollection, error) {
 		// Package it as a script type and add it to the ScriptCollection
 		s := Script{
 			Path: scriptPath,
+			Args: scriptFields[1:],
 		}
 
 		sc.Add(s)
@@ -84,3 +103,131 @@ func VerifyScripts(scriptPaths []string) (ScriptCollection, error) {
 
 	return sc, nil
 }
+
+// InlineCommandShell is the shell that inline commands passed via --cmd are run with, as `sh -c "<command>"`
+const InlineCommandShell = "sh"
+
+// VerifyInlineCommands ensures that every inline command passed via --cmd is non-empty and that the shell needed to run
+// them is available, then adds them to the supplied ScriptCollection, after any scripts already in it
+func VerifyInlineCommands(commands []string, sc *ScriptCollection) error {
+	if len(commands) == 0 {
+		return nil
+	}
+
+	if _, lookErr := exec.LookPath(InlineCommandShell); lookErr != nil {
+		log.WithFields(logrus.Fields{
+			"Error": lookErr,
+			"Shell": InlineCommandShell,
+		}).Debug("Could not find the shell needed to run inline commands")
+		return lookErr
+	}
+
+	for _, command := range commands {
+		command = strings.TrimSpace(command)
+		if command == "" {
+			return errors.New("Commands passed via --cmd must not be empty")
+		}
+
+		sc.Add(Script{Command: command})
+	}
+
+	return nil
+}
+
+// newCommand builds the command that runs the script, or the inline shell command, in the supplied directory
+func (s Script) newCommand(dir string) *exec.Cmd {
+	var cmd *exec.Cmd
+	if s.Command != "" {
+		cmd = exec.Command(InlineCommandShell, "-c", s.Command)
+	} else {
+		cmd = exec.Command(s.Path, s.Args...)
+	}
+	cmd.Dir = dir
+	return cmd
+}
+
+// pullRequestBody returns the body of the pull requests opened by this run: the operator-supplied description followed,
+// when any inline commands were run, by the exact commands, so that reviewers can audit how the changes were made
+func pullRequestBody(description string, sc ScriptCollection) string {
+	commands := sc.InlineCommands()
+	if len(commands) == 0 {
+		return description
+	}
+
+	var body strings.Builder
+	body.WriteString(description)
+	body.WriteString("\n\n### Commands run by git-xargs\n\n```sh\n")
+	for _, command := range commands {
+		body.WriteString(command)
+		body.WriteString("\n")
+	}
+	body.WriteString("```\n")
+
+	return body.String()
+}
+
+// splitScriptArgs splits a script and its arguments into separate fields on whitespace, the way a shell would. Single
+// quotes, double quotes and backslash escapes can be used to keep whitespace within a single field
+func splitScriptArgs(scriptWithArgs string) ([]string, error) {
+	var fields []string
+	var current strings.Builder
+	inField := false
+	var quote rune
+	escaped := false
+
+	for _, c := range scriptWithArgs {
+		switch {
+		case escaped:
+			current.WriteRune(c)
+			escaped = false
+		case c == '\\' && quote != '\'':
+			escaped = true
+			inField = true
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			} else {
+				current.WriteRune(c)
+			}
+		case c == '\'' || c == '"':
+			quote = c
+			inField = true
+		case unicode.IsSpace(c):
+			if inField {
+				fields = append(fields, current.String())
+				current.Reset()
+				inField = false
+			}
+		default:
+			current.WriteRune(c)
+			inField = true
+		}
+	}
+
+	if quote != 0 || escaped {
+		return nil, fmt.Errorf("unterminated quote or escape in %q", scriptWithArgs)
+	}
+
+	if inField {
+		fields = append(fields, current.String())
+	}
+
+	return fields, nil
+}
+
+// scriptEnv returns the environment a script is run with against the given repo: the operator's own environment, plus
+// XARGS_* variables describing the repo, so that a single script can adapt its behavior to each repo it runs against
+func scriptEnv(repo *github.Repository, baseBranch string, dryRun bool) []string {
+	return append(os.Environ(),
+		fmt.Sprintf("XARGS_REPO_NAME=%s", repo.GetName()),
+		fmt.Sprintf("XARGS_REPO_OWNER=%s", repo.GetOwner().GetLogin()),
+		fmt.Sprintf("XARGS_REPO_FULL_NAME=%s", repo.GetFullName()),
+		fmt.Sprintf("XARGS_DEFAULT_BRANCH=%s", repo.GetDefaultBranch()),
+		fmt.Sprintf("XARGS_BASE_BRANCH=%s", baseBranch),
+		fmt.Sprintf("XARGS_BRANCH_NAME=%s", BranchName),
+		fmt.Sprintf("XARGS_DRY_RUN=%t", dryRun),
+		fmt.Sprintf("XARGS_CLONE_URL=%s", repo.GetCloneURL()),
+		fmt.Sprintf("XARGS_REPO_TOPICS=%s", strings.Join(repo.Topics, ",")),
+		fmt.Sprintf("XARGS_REPO_LANGUAGE=%s", repo.GetLanguage()),
+	)
+}
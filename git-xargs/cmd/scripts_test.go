@@ -1,9 +1,15 @@
 package cmd
 
 import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/google/go-github/v32/github"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 // Ensure that passing missing or misnamed scripts to the VerifyScripts function results in proper filtering
@@ -56,3 +62,124 @@ func TestFileThatIsNotExecutableThrowsError(t *testing.T) {
 	assert.EqualError(t, verifyErr, "All scripts must be chmod'd to be executable by at least their owner")
 
 }
+
+func TestVerifyScriptsSplitsScriptArguments(t *testing.T) {
+	scriptWithArgs := []string{"./_testscripts/add-license.sh --year 2021 --holder 'Gruntwork, Inc'"}
+
+	filteredScriptCollection, verifyErr := VerifyScripts(scriptWithArgs)
+
+	assert.NoError(t, verifyErr)
+
+	assert.Equal(t, len(filteredScriptCollection.Scripts), 1)
+	assert.Equal(t, filteredScriptCollection.Scripts[0].Args, []string{"--year", "2021", "--holder", "Gruntwork, Inc"})
+}
+
+func TestScriptsFlagKeepsScriptArgumentsWhole(t *testing.T) {
+	originalScripts := TargetScripts
+	defer func() { TargetScripts = originalScripts }()
+
+	err := rootCmd.PersistentFlags().Parse([]string{
+		"--scripts", "./_testscripts/add-license.sh --holder 'Gruntwork, Inc'",
+		"-s", `./_testscripts/add-license.sh --holder "Gruntwork, Inc"`,
+	})
+	require.NoError(t, err)
+
+	assert.Equal(t, TargetScripts, []string{
+		"./_testscripts/add-license.sh --holder 'Gruntwork, Inc'",
+		`./_testscripts/add-license.sh --holder "Gruntwork, Inc"`,
+	})
+
+	filteredScriptCollection, verifyErr := VerifyScripts(TargetScripts)
+
+	assert.NoError(t, verifyErr)
+
+	require.Equal(t, len(filteredScriptCollection.Scripts), 2)
+	for _, script := range filteredScriptCollection.Scripts {
+		assert.Equal(t, script.Args, []string{"--holder", "Gruntwork, Inc"})
+	}
+}
+
+func TestSplitScriptArgsHandlesQuotesAndEscapes(t *testing.T) {
+	fields, err := splitScriptArgs(`update.sh "double quoted" 'single \quoted' escaped\ space ''`)
+
+	assert.NoError(t, err)
+
+	assert.Equal(t, fields, []string{"update.sh", "double quoted", `single \quoted`, "escaped space", ""})
+}
+
+func TestSplitScriptArgsRejectsUnterminatedQuote(t *testing.T) {
+	_, err := splitScriptArgs(`update.sh "never closed`)
+
+	assert.Error(t, err)
+}
+
+func TestScriptEnvDescribesRepo(t *testing.T) {
+	repo := &github.Repository{
+		Name:          github.String("cloud-nuke"),
+		FullName:      github.String("gruntwork-io/cloud-nuke"),
+		Owner:         &github.User{Login: github.String("gruntwork-io")},
+		DefaultBranch: github.String("master"),
+		CloneURL:      github.String("https://github.com/gruntwork-io/cloud-nuke.git"),
+		Topics:        []string{"aws", "cli"},
+		Language:      github.String("Go"),
+	}
+
+	env := scriptEnv(repo, "main", true)
+
+	assert.Contains(t, env, "XARGS_REPO_NAME=cloud-nuke")
+	assert.Contains(t, env, "XARGS_REPO_OWNER=gruntwork-io")
+	assert.Contains(t, env, "XARGS_DEFAULT_BRANCH=master")
+	assert.Contains(t, env, "XARGS_BASE_BRANCH=main")
+	assert.Contains(t, env, "XARGS_DRY_RUN=true")
+	assert.Contains(t, env, "XARGS_CLONE_URL=https://github.com/gruntwork-io/cloud-nuke.git")
+	assert.Contains(t, env, "XARGS_REPO_TOPICS=aws,cli")
+	assert.Contains(t, env, "XARGS_REPO_LANGUAGE=Go")
+}
+
+func TestVerifyInlineCommandsAddsCommandsAfterScripts(t *testing.T) {
+	sc, verifyErr := VerifyScripts([]string{"./_testscripts/add-license.sh"})
+	assert.NoError(t, verifyErr)
+
+	commandsErr := VerifyInlineCommands([]string{"go mod tidy", "sed -i 's/a,b/c/' go.mod"}, &sc)
+
+	assert.NoError(t, commandsErr)
+	assert.Equal(t, len(sc.Scripts), 3)
+	assert.Equal(t, sc.InlineCommands(), []string{"go mod tidy", "sed -i 's/a,b/c/' go.mod"})
+}
+
+func TestVerifyInlineCommandsRejectsEmptyCommand(t *testing.T) {
+	sc := ScriptCollection{}
+
+	commandsErr := VerifyInlineCommands([]string{"  "}, &sc)
+
+	assert.Error(t, commandsErr)
+	assert.Equal(t, len(sc.Scripts), 0)
+}
+
+func TestInlineCommandRunsInRepoDirectory(t *testing.T) {
+	dir, err := ioutil.TempDir("", "git-xargs-inline-cmd-test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := Script{Command: "echo hello > greeting.txt && cat greeting.txt"}
+	output, runErr := script.newCommand(dir).CombinedOutput()
+
+	assert.NoError(t, runErr)
+	assert.Equal(t, string(output), "hello\n")
+	assert.FileExists(t, filepath.Join(dir, "greeting.txt"))
+}
+
+func TestPullRequestBodyRecordsInlineCommands(t *testing.T) {
+	sc := ScriptCollection{}
+	sc.Add(Script{Path: "/tmp/add-license.sh"})
+
+	// Without inline commands, the description is used as-is
+	assert.Equal(t, pullRequestBody("Add a license", sc), "Add a license")
+
+	sc.Add(Script{Command: "go mod tidy"})
+	body := pullRequestBody("Add a license", sc)
+
+	assert.True(t, strings.HasPrefix(body, "Add a license\n"))
+	assert.Contains(t, body, "```sh\ngo mod tidy\n```")
+	assert.NotContains(t, body, "add-license.sh")
+}
@@ -1,7 +1,6 @@
 package cmd
 
 import (
-	"context"
 	"errors"
 	"fmt"
 
@@ -9,7 +8,7 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func getFileDefinedRepos(GithubClient *github.Client, allowedRepos []*AllowedRepo, stats *RunStats) ([]*github.Repository, error) {
+func getFileDefinedRepos(host RepoHost, allowedRepos []*AllowedRepo, stats *RunStats) ([]*github.Repository, error) {
 	var allRepos []*github.Repository
 
 	for _, allowedRepo := range allowedRepos {
@@ -19,17 +18,16 @@ func getFileDefinedRepos(GithubClient *github.Client, allowedRepos []*AllowedRep
 			"Name":         allowedRepo.Name,
 		}).Debug("Looking up filename provided repo")
 
-		repo, resp, err := GithubClient.Repositories.Get(context.Background(), allowedRepo.Organization, allowedRepo.Name)
+		repo, err := host.GetRepo(allowedRepo.Organization, allowedRepo.Name)
 
 		if err != nil {
 			log.WithFields(logrus.Fields{
-				"Error":                err,
-				"Response Status Code": resp.StatusCode,
-				"AllowedRepoOwner":     allowedRepo.Organization,
-				"AllowedRepoName":      allowedRepo.Name,
+				"Error":            err,
+				"AllowedRepoOwner": allowedRepo.Organization,
+				"AllowedRepoName":  allowedRepo.Name,
 			}).Debug("error getting single repo")
 
-			if resp.StatusCode == 404 {
+			if errors.Is(err, ErrRepoNotFound) {
 				// This repo does not exist / could not be fetched as named, so we won't include it in the list of repos to process
 
 				// create an empty github repo object to satisfy the stats tracking interface
@@ -37,44 +35,70 @@ func getFileDefinedRepos(GithubClient *github.Client, allowedRepos []*AllowedRep
 					Owner: &github.User{Login: github.String(allowedRepo.Organization)},
 					Name:  github.String(allowedRepo.Name),
 				}
-				stats.TrackSingle(RepoNotExists, missingRepo)
-				continue
+				stats.TrackError(RepoNotExists, missingRepo, err)
 			}
+			continue
 		}
 
-		if resp.StatusCode == 200 {
-			log.WithFields(logrus.Fields{
-				"Organization": allowedRepo.Organization,
-				"Name":         allowedRepo.Name,
-			}).Debug("Successfully fetched repo")
+		log.WithFields(logrus.Fields{
+			"Organization": allowedRepo.Organization,
+			"Name":         allowedRepo.Name,
+		}).Debug("Successfully fetched repo")
 
-			allRepos = append(allRepos, repo)
-		}
+		allRepos = append(allRepos, repo)
 	}
 	return allRepos, nil
 }
 
-// Get all the repos for a given Github organization
-func getReposByOrg(GithubClient *github.Client, GithubOrg string, stats *RunStats) ([]*github.Repository, error) {
-	// Page through all of the organization's repos, collecting them in this slice
-	var allRepos []*github.Repository
+// Get all the repos for a given Github organization, or GitLab group
+func getReposByOrg(host RepoHost, GithubOrg string, stats *RunStats) ([]*github.Repository, error) {
+	allRepos, err := host.ListOrgRepos(GithubOrg)
+	if err != nil {
+		return allRepos, err
+	}
 
-	opt := &github.RepositoryListByOrgOptions{
-		ListOptions: github.ListOptions{
-			PerPage: 100,
-		},
+	repoCount := len(allRepos)
+
+	if repoCount == 0 {
+		return nil, errors.New("no repositories found")
 	}
 
-	for {
-		repos, resp, err := GithubClient.Repositories.ListByOrg(context.Background(), GithubOrg, opt)
-		if err != nil {
-			return allRepos, err
-		}
-		allRepos = append(allRepos, repos...)
-		if resp.NextPage == 0 {
-			break
-		}
-		opt.Page = resp.NextPage
+	log.WithFields(logrus.Fields{
+		"Repo count": repoCount,
+	}).Debug(fmt.Sprintf("Fetched repos from organization: %s", GithubOrg))
+
+	stats.TrackMultiple(FetchedViaGithubAPI, allRepos)
+
+	return allRepos, nil
+}
+
+// Get all the repos matching a search query, e.g., "org:gruntwork-io terraform in:name" on Github
+func getReposBySearch(host RepoHost, query string, stats *RunStats) ([]*github.Repository, error) {
+	allRepos, err := host.SearchRepos(query)
+	if err != nil {
+		return allRepos, err
+	}
+
+	repoCount := len(allRepos)
+
+	if repoCount == 0 {
+		return nil, errors.New("no repositories found")
+	}
+
+	log.WithFields(logrus.Fields{
+		"Repo count": repoCount,
+	}).Debug(fmt.Sprintf("Fetched repos matching search query: %s", query))
+
+	stats.TrackMultiple(FetchedViaGithubAPI, allRepos)
+
+	return allRepos, nil
+}
+
+// Get all the repos owned by a personal account
+func getReposByUser(host RepoHost, GithubUser string, stats *RunStats) ([]*github.Repository, error) {
+	allRepos, err := host.ListUserRepos(GithubUser)
+	if err != nil {
+		return allRepos, err
 	}
 
 	repoCount := len(allRepos)
@@ -85,7 +109,7 @@ func getReposByOrg(GithubClient *github.Client, GithubOrg string, stats *RunStat
 
 	log.WithFields(logrus.Fields{
 		"Repo count": repoCount,
-	}).Debug(fmt.Sprintf("Fetched repos from Github organization: %s", GithubOrg))
+	}).Debug(fmt.Sprintf("Fetched repos from user: %s", GithubUser))
 
 	stats.TrackMultiple(FetchedViaGithubAPI, allRepos)
 
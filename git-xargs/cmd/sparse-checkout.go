@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// normalizeSparseCheckoutPaths checks that each of the directories passed via --sparse-checkout is relative to the root
+// of the repo and stays within it, and returns them slash separated with a trailing slash. go-git checks out every path
+// that starts with one of them, so without the trailing slash "modules/vpc" would also check out "modules/vpc-peering"
+func normalizeSparseCheckoutPaths(paths []string) ([]string, error) {
+	normalized := make([]string, 0, len(paths))
+	for _, sparsePath := range paths {
+		trimmed := strings.TrimSpace(sparsePath)
+		if trimmed == "" {
+			continue
+		}
+		if strings.HasPrefix(trimmed, "/") {
+			return nil, fmt.Errorf("sparse checkout path %q must be relative to the root of the repo", sparsePath)
+		}
+
+		cleaned := path.Clean(trimmed)
+		if cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+			return nil, fmt.Errorf("sparse checkout path %q must be a directory within the repo", sparsePath)
+		}
+		normalized = append(normalized, cleaned+"/")
+	}
+	return normalized, nil
+}
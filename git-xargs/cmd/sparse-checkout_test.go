@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSparseCheckoutPaths(t *testing.T) {
+	paths, err := normalizeSparseCheckoutPaths([]string{"modules/vpc", " modules/rds/ ", "./examples", ""})
+	require.NoError(t, err)
+	assert.Equal(t, paths, []string{"modules/vpc/", "modules/rds/", "examples/"})
+
+	for _, invalid := range []string{"/modules", "..", "../other-repo", "modules/../..", "."} {
+		_, err := normalizeSparseCheckoutPaths([]string{invalid})
+		assert.Error(t, err, invalid)
+	}
+}
+
+// makeTestMonorepo creates a bare repo on the local filesystem to stand in for a large Github repo, with a single commit
+// of files spread across several directories, and returns a repo whose clone URL is its path
+func makeTestMonorepo(t *testing.T) *github.Repository {
+	upstreamDir, err := ioutil.TempDir("", "git-xargs-upstream")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(upstreamDir) })
+
+	_, err = git.PlainInit(upstreamDir, true)
+	require.NoError(t, err)
+
+	authorDir, err := ioutil.TempDir("", "git-xargs-author")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(authorDir) })
+
+	author, err := git.PlainInit(authorDir, false)
+	require.NoError(t, err)
+	_, err = author.CreateRemote(&config.RemoteConfig{Name: "origin", URLs: []string{upstreamDir}})
+	require.NoError(t, err)
+	worktree, err := author.Worktree()
+	require.NoError(t, err)
+
+	for _, path := range []string{"README.md", "modules/vpc/main.tf", "modules/vpc-peering/main.tf", "modules/rds/main.tf"} {
+		require.NoError(t, os.MkdirAll(filepath.Join(authorDir, filepath.Dir(path)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(authorDir, path), []byte("# "+path+"\n"), 0644))
+		_, err = worktree.Add(path)
+		require.NoError(t, err)
+	}
+	_, err = worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "git-xargs", Email: "git-xargs@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+	require.NoError(t, author.Push(&git.PushOptions{RemoteName: "origin"}))
+
+	return &github.Repository{
+		Name:     github.String("infrastructure-modules"),
+		FullName: github.String("gruntwork-io/infrastructure-modules"),
+		Owner:    &github.User{Login: github.String("gruntwork-io")},
+		CloneURL: github.String(upstreamDir),
+	}
+}
+
+func TestSparseCheckoutOnlyCommitsChangesToCheckedOutPaths(t *testing.T) {
+	originalPaths := SparseCheckoutPaths
+	defer func() { SparseCheckoutPaths = originalPaths }()
+	SparseCheckoutPaths = []string{"modules/vpc/"}
+
+	defer setCommitIdentityFlags(t, "git-xargs bot", "bot@example.com", nil, SigningFormatGPG, "")()
+
+	originalLogDir := ScriptLogDir
+	defer func() { ScriptLogDir = originalLogDir }()
+	logDir, err := ioutil.TempDir("", "git-xargs-script-logs")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+	ScriptLogDir = logDir
+
+	repo := makeTestMonorepo(t)
+	stats := NewStatsTracker()
+
+	repositoryDir, localRepository, err := cloneLocalRepository(repo, "master", stats)
+	require.NoError(t, err)
+	defer os.RemoveAll(repositoryDir)
+
+	// Only the sparse checkout directory was written to disk, not the directory that merely shares its prefix
+	_, err = os.Stat(filepath.Join(repositoryDir, "modules", "vpc", "main.tf"))
+	assert.NoError(t, err)
+	for _, path := range []string{"README.md", "modules/vpc-peering/main.tf", "modules/rds/main.tf"} {
+		_, err = os.Stat(filepath.Join(repositoryDir, path))
+		assert.True(t, os.IsNotExist(err), path)
+	}
+
+	worktree, err := localRepository.Worktree()
+	require.NoError(t, err)
+	ref, err := getLocalRepoHeadRef(localRepository, repo, stats)
+	require.NoError(t, err)
+	_, err = checkoutLocalBranch(ref, "master", worktree, repo, localRepository, stats)
+	require.NoError(t, err)
+
+	scripts := ScriptCollection{}
+	scripts.Add(Script{Command: `echo "# vpc v2" > modules/vpc/main.tf && echo "# outputs" > modules/vpc/outputs.tf`})
+
+	status, err := runAllTargetedScripts(false, repositoryDir, "master", scripts, repo, worktree, stats)
+	require.NoError(t, err)
+	assert.Equal(t, changedFilePaths(status), []string{"modules/vpc/main.tf", "modules/vpc/outputs.tf"})
+
+	require.NoError(t, commitLocalChanges("Update vpc", worktree, repo, localRepository, stats))
+
+	tree, err := headCommit(t, localRepository).Tree()
+	require.NoError(t, err)
+
+	var committed []string
+	require.NoError(t, tree.Files().ForEach(func(file *object.File) error {
+		committed = append(committed, file.Name)
+		return nil
+	}))
+	assert.ElementsMatch(t, committed, []string{"README.md", "modules/vpc/main.tf", "modules/vpc/outputs.tf", "modules/vpc-peering/main.tf", "modules/rds/main.tf"})
+}
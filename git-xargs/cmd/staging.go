@@ -0,0 +1,102 @@
+package cmd
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/format/gitignore"
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// PathFilter decides which of the files changed by the scripts are committed, according to --include and --exclude.
+// Both take patterns in .gitignore syntax, so "*.tf" matches at any depth and "vendor/" matches everything beneath it
+type PathFilter struct {
+	include gitignore.Matcher
+	exclude gitignore.Matcher
+}
+
+// NewPathFilter returns a filter that allows the paths matching at least one of the include patterns, or every path if
+// there are none, unless they match one of the exclude patterns
+func NewPathFilter(include, exclude []string) *PathFilter {
+	filter := &PathFilter{}
+	if len(include) > 0 {
+		filter.include = newPathMatcher(include)
+	}
+	if len(exclude) > 0 {
+		filter.exclude = newPathMatcher(exclude)
+	}
+	return filter
+}
+
+func newPathMatcher(patterns []string) gitignore.Matcher {
+	parsed := make([]gitignore.Pattern, len(patterns))
+	for i, pattern := range patterns {
+		parsed[i] = gitignore.ParsePattern(strings.TrimSpace(pattern), nil)
+	}
+	return gitignore.NewMatcher(parsed)
+}
+
+// Allows returns true if the file at the slash separated path, relative to the root of the repo, should be committed
+func (f *PathFilter) Allows(path string) bool {
+	parts := strings.Split(path, "/")
+	if f.include != nil && !f.include.Match(parts, false) {
+		return false
+	}
+	return f.exclude == nil || !f.exclude.Match(parts, false)
+}
+
+// stageChanges stages the files changed by the scripts that should be committed, and returns the status of just those
+// files. Files ignored via .gitignore or .git/info/exclude never show up in the status, so they're left out silently,
+// as git itself would. Changed files that don't pass the --include and --exclude filter are left out too, and since
+// those were changed on purpose, the operator is warned about them
+func stageChanges(worktree *git.Worktree, status git.Status, filter *PathFilter, repo *github.Repository, stats *RunStats) (git.Status, error) {
+	paths := make([]string, 0, len(status))
+	for path := range status {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+
+	staged := git.Status{}
+	var leftOut []string
+
+	for _, path := range paths {
+		fileStatus := status[path]
+		if fileStatus.Worktree == git.Unmodified && fileStatus.Staging == git.Unmodified {
+			continue
+		}
+
+		if !filter.Allows(path) {
+			leftOut = append(leftOut, path)
+			continue
+		}
+
+		log.WithFields(logrus.Fields{
+			"Repo":     repo.GetName(),
+			"Filepath": path,
+		}).Debug("Adding changed file to stage")
+
+		if _, addErr := worktree.Add(path); addErr != nil {
+			log.WithFields(logrus.Fields{
+				"Error":    addErr,
+				"Filepath": path,
+			}).Debug("Error adding file to git stage")
+			// Track the file staging failure
+			stats.TrackError(WorktreeAddFileFailed, repo, addErr)
+			return staged, addErr
+		}
+		staged[path] = fileStatus
+	}
+
+	if len(leftOut) > 0 {
+		log.WithFields(logrus.Fields{
+			"Repo":  repo.GetName(),
+			"Files": leftOut,
+		}).Warn("Files changed by the scripts were left out of the commit, because they didn't pass --include or --exclude")
+
+		stats.TrackSingle(FilesLeftOut, repo)
+	}
+
+	return staged, nil
+}
@@ -0,0 +1,164 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathFilterAllowsEverythingByDefault(t *testing.T) {
+	filter := NewPathFilter(nil, nil)
+	assert.True(t, filter.Allows("main.tf"))
+	assert.True(t, filter.Allows("modules/vpc/main.tf"))
+}
+
+func TestPathFilterIncludesAndExcludes(t *testing.T) {
+	filter := NewPathFilter([]string{"*.tf", "Makefile"}, []string{"examples/", "/legacy.tf"})
+
+	assert.True(t, filter.Allows("main.tf"))
+	assert.True(t, filter.Allows("modules/vpc/main.tf"))
+	assert.True(t, filter.Allows("Makefile"))
+	assert.True(t, filter.Allows("modules/legacy.tf"))
+
+	assert.False(t, filter.Allows("README.md"))
+	assert.False(t, filter.Allows("examples/basic/main.tf"))
+	assert.False(t, filter.Allows("legacy.tf"))
+}
+
+// makeTestRepoWithIgnores creates a local repo whose single commit has a .gitignore and a couple of tracked files, and
+// whose .git/info/exclude ignores further files
+func makeTestRepoWithIgnores(t *testing.T) (string, *git.Repository, *git.Worktree) {
+	dir, err := ioutil.TempDir("", "git-xargs-staging")
+	require.NoError(t, err)
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	localRepository, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	worktree, err := localRepository.Worktree()
+	require.NoError(t, err)
+
+	files := map[string]string{
+		".gitignore":         "node_modules/\n.terraform/\n",
+		"README.md":          "# repo\n",
+		"CHANGELOG.md":       "# changelog\n",
+		"modules/vpc/vpc.tf": "# vpc\n",
+	}
+	for path, contents := range files {
+		require.NoError(t, os.MkdirAll(filepath.Join(dir, filepath.Dir(path)), 0755))
+		require.NoError(t, ioutil.WriteFile(filepath.Join(dir, path), []byte(contents), 0644))
+		_, err = worktree.Add(path)
+		require.NoError(t, err)
+	}
+	_, err = worktree.Commit("Initial commit", &git.CommitOptions{
+		Author: &object.Signature{Name: "upstream", Email: "upstream@example.com", When: time.Now()},
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".git", "info"), 0755))
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, ".git", "info", "exclude"), []byte("# local only\n*.log\n"), 0644))
+
+	return dir, localRepository, worktree
+}
+
+// setPathFilterFlags sets --include and --exclude, returning a function that restores them
+func setPathFilterFlags(include, exclude []string) func() {
+	originalInclude, originalExclude := IncludePaths, ExcludePaths
+	IncludePaths, ExcludePaths = include, exclude
+	return func() {
+		IncludePaths, ExcludePaths = originalInclude, originalExclude
+	}
+}
+
+// makeBuildScript returns a script that makes the intended changes to the repo, along with the kind of build artifacts
+// that should never end up in a pull request
+func makeBuildScript() ScriptCollection {
+	scripts := ScriptCollection{}
+	scripts.Add(Script{Command: `
+		echo "# vpc v2" > modules/vpc/vpc.tf
+		echo "# rds" > main.tf
+		rm CHANGELOG.md
+		echo "# updated" > README.md
+		mkdir -p node_modules/left-pad .terraform docs
+		echo "module.exports = 1" > node_modules/left-pad/index.js
+		echo "{}" > .terraform/state
+		echo "plan output" > plan.log
+		echo "# docs" > docs/usage.md
+	`})
+	return scripts
+}
+
+func TestRunAllTargetedScriptsStagesOnlyIntendedChanges(t *testing.T) {
+	defer setPathFilterFlags(nil, []string{"docs/", "README.md"})()
+	defer setCommitIdentityFlags(t, "git-xargs bot", "bot@example.com", nil, SigningFormatGPG, "")()
+
+	originalLogDir := ScriptLogDir
+	defer func() { ScriptLogDir = originalLogDir }()
+	logDir, err := ioutil.TempDir("", "git-xargs-script-logs")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+	ScriptLogDir = logDir
+
+	dir, localRepository, worktree := makeTestRepoWithIgnores(t)
+	repo := makeTestRepo()
+	stats := NewStatsTracker()
+
+	status, err := runAllTargetedScripts(false, dir, "master", makeBuildScript(), repo, worktree, stats)
+	require.NoError(t, err)
+
+	// Ignored files never show up, and the files left out by --exclude are reported on but not staged
+	assert.Equal(t, changedFilePaths(status), []string{"CHANGELOG.md", "main.tf", "modules/vpc/vpc.tf"})
+	assert.Equal(t, len(stats.GetMultiple(WorktreeStatusDirty)), 1)
+	assert.Equal(t, len(stats.GetMultiple(FilesLeftOut)), 1)
+
+	require.NoError(t, commitLocalChanges("Add rds", worktree, repo, localRepository, stats))
+
+	commit := headCommit(t, localRepository)
+	tree, err := commit.Tree()
+	require.NoError(t, err)
+
+	var committed []string
+	require.NoError(t, tree.Files().ForEach(func(file *object.File) error {
+		committed = append(committed, file.Name)
+		return nil
+	}))
+	assert.ElementsMatch(t, committed, []string{".gitignore", "README.md", "main.tf", "modules/vpc/vpc.tf"})
+
+	// The modified README.md was left out of the commit, so it's still unchanged there
+	readme, err := tree.File("README.md")
+	require.NoError(t, err)
+	contents, err := readme.Contents()
+	require.NoError(t, err)
+	assert.Equal(t, contents, "# repo\n")
+
+	// Whatever was left out stays behind in the worktree, uncommitted
+	remaining, err := worktree.Status()
+	require.NoError(t, err)
+	assert.Equal(t, changedFilePaths(remaining), []string{"README.md", "docs/usage.md"})
+}
+
+func TestRunAllTargetedScriptsTracksNoChangesWhenEverythingIsLeftOut(t *testing.T) {
+	defer setPathFilterFlags([]string{"*.go"}, nil)()
+
+	originalLogDir := ScriptLogDir
+	defer func() { ScriptLogDir = originalLogDir }()
+	logDir, err := ioutil.TempDir("", "git-xargs-script-logs")
+	require.NoError(t, err)
+	defer os.RemoveAll(logDir)
+	ScriptLogDir = logDir
+
+	dir, _, worktree := makeTestRepoWithIgnores(t)
+	stats := NewStatsTracker()
+
+	status, err := runAllTargetedScripts(false, dir, "master", makeBuildScript(), makeTestRepo(), worktree, stats)
+	require.NoError(t, err)
+
+	assert.True(t, status.IsClean())
+	assert.Equal(t, len(stats.GetMultiple(FilesLeftOut)), 1)
+}
@@ -1,9 +1,12 @@
 package cmd
 
 import (
+	"os"
+	"sync"
 	"time"
 
 	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
 )
 
 // Event is a generic tracking ocurrence that RunStats manages
@@ -14,6 +17,10 @@ const (
 	DryRunSet Event = "dry-run-set-no-changes-made"
 	// ReposSelected denotes all the repositories that were targeted for processing by this tool AFTER filtering was applied to determine valid repos
 	ReposSelected Event = "repos-selected-pre-processing"
+	// RepoLineMalformed denotes a line of the allowed repos file or STDIN that could not be parsed as a repo. The line itself is reported as the repo name
+	RepoLineMalformed Event = "repo-line-malformed"
+	// RepoExcludedByFilter denotes a repo that was fetched but left out of processing because it didn't pass the filters supplied via flags such as --topic, --language or --exclude-archived
+	RepoExcludedByFilter Event = "repo-excluded-by-filter"
 	// TargetBranchNotFound denotes the special branch used by this tool to make changes on was not found on lookup, suggesting it should be created
 	TargetBranchNotFound Event = "target-branch-not-found"
 	// TargetBranchAlreadyExists denotes the special branch used by this tool was already found (so it was likely already created by a previous run)
@@ -26,6 +33,8 @@ const (
 	FetchedViaGithubAPI Event = "fetch-via-github-api"
 	// RepoSuccessfullyCloned denotes a repo that was able to be cloned to the local filesystem of the operator's machine
 	RepoSuccessfullyCloned Event = "repo-successfully-cloned"
+	// CloneCacheUpdateFailed denotes a repo whose mirror in --clone-cache-dir could not be created or fetched into, so it was cloned from Github instead
+	CloneCacheUpdateFailed Event = "clone-cache-update-failed"
 	// RepoFailedToClone denotes that for whatever reason we were unable to clone the repo to the local system
 	RepoFailedToClone Event = "repo-failed-to-clone"
 	// BranchCheckoutFailed denotes a failure to checkout a new tool specific branch in the given repo
@@ -34,60 +43,223 @@ const (
 	GetHeadRefFailed Event = "get-head-ref-failed"
 	// ScriptErrorOccurredDuringExecution denotes a repo for which at least one script raised an error during execution
 	ScriptErrorOcurredDuringExecution Event = "script-error-during-execution"
+	// ScriptTimedOut denotes a repo for which a script was killed because it ran for longer than --script-timeout
+	ScriptTimedOut Event = "script-timed-out"
+	// MessageTemplateRenderFailed denotes a repo for which the commit message or pull request templates could not be rendered
+	MessageTemplateRenderFailed Event = "message-template-render-failed"
 	// WorktreeStatusCheckFailed denotes a repo whose git status command failed post script execution
 	WorktreeStatusCheckFailed Event = "worktree-status-check-failed"
 	// WorktreeStatusDirty denotes repos that had local file changes following execution of all their targeted scripts
 	WorktreeStatusDirty Event = "worktree-status-dirty"
 	// WorktreeStatusClean denotes a repo that did not have any local file changes following script execution
 	WorktreeStatusClean Event = "worktree-status-clean"
+	// FilesLeftOut denotes a repo for which at least one file changed by the scripts was not committed, because it didn't pass --include or --exclude
+	FilesLeftOut Event = "files-left-out"
 	// WorktreeAddFileFailed denotes a failure to add at least one file to the git stage following script execution
 	WorktreeAddFileFailed Event = "worktree-add-file-failed"
 	// CommitChangesFailed denotes an error git committing our file changes to the local repo
 	CommitChangesFailed Event = "commit-changes-failed"
 	// PushBranchFailed denotes a repo whose new tool-specific branch could not be pushed to remote origin
 	PushBranchFailed Event = "push-branch-failed"
+	// PushBranchSucceeded denotes a repo whose new tool-specific branch was pushed to remote origin
+	PushBranchSucceeded Event = "push-branch-succeeded"
 	// PushBranchSkipped denotes a repo whose local branch was not pushed due to the --dry-run flag being set
 	PushBranchSkipped Event = "push-branch-skipped"
 	// RepoNotExists denotes a repo + org combo that was supplied via file but could not be successfully looked up via the Github API (returned a 404)
 	RepoNotExists Event = "repo-not-exists"
 	// PullRequestOpenErr denotes a repo whose pull request containing config changes could not be made successfully
 	PullRequestOpenErr Event = "pull-request-open-error"
+	// PullRequestOpened denotes a repo against which a pull request was successfully opened. The pull requests themselves are reported in their own table
+	PullRequestOpened Event = "pull-request-opened"
+	// PullRequestUpdated denotes a repo whose already open pull request was updated with new commits and edited, because --update-existing was set
+	PullRequestUpdated Event = "pull-request-updated"
+	// PullRequestUpdateErr denotes a repo whose already open pull request could not be looked up or edited
+	PullRequestUpdateErr Event = "pull-request-update-error"
+	// PullRequestReviewersErr denotes a repo whose pull request was opened, but whose reviewers could not be requested
+	PullRequestReviewersErr Event = "pull-request-reviewers-error"
+	// PullRequestLabelsErr denotes a repo whose pull request was opened, but could not be labelled
+	PullRequestLabelsErr Event = "pull-request-labels-error"
+	// PullRequestAssigneesErr denotes a repo whose pull request was opened, but could not be assigned
+	PullRequestAssigneesErr Event = "pull-request-assignees-error"
+	// PullRequestMilestoneErr denotes a repo whose pull request was opened, but could not be added to the milestone
+	PullRequestMilestoneErr Event = "pull-request-milestone-error"
+	// TargetBranchExistsNotUpdated denotes a repo that was skipped because its target branch already exists and --update-existing was not set
+	TargetBranchExistsNotUpdated Event = "target-branch-exists-not-updated"
+	// RepoSkippedAlreadyCompleted denotes a repo that was skipped because the run-state file being resumed shows a previous run already finished with it
+	RepoSkippedAlreadyCompleted Event = "repo-skipped-already-completed"
+	// NoChangesRequired denotes a repo whose scripts made no file changes, so no commit, branch push or pull request was made
+	NoChangesRequired Event = "no-changes-required"
+	// RepoProcessingCancelled denotes a repo that was still queued for processing when the run was cancelled (e.g., via SIGINT), so it was never touched
+	RepoProcessingCancelled Event = "repo-processing-cancelled"
+
+	// The following events are tracked by the subcommands that follow up on the pull requests opened by a previous run
+
+	// CampaignPullRequestFound denotes a pull request opened by a previous run that was found via its run report or branch name
+	CampaignPullRequestFound Event = "campaign-pull-request-found"
+	// PullRequestLookupErr denotes a pull request whose state, checks or reviews could not be looked up
+	PullRequestLookupErr Event = "pull-request-lookup-error"
+	// PullRequestNotOpen denotes a pull request that was already merged or closed
+	PullRequestNotOpen Event = "pull-request-not-open"
+	// PullRequestChecksFailed denotes a pull request that was not merged because at least one of its checks failed
+	PullRequestChecksFailed Event = "pull-request-checks-failed"
+	// PullRequestChecksPending denotes a pull request that was not merged because its checks were still running once --wait-timeout elapsed
+	PullRequestChecksPending Event = "pull-request-checks-pending"
+	// PullRequestNotApproved denotes a pull request that was not merged because it wasn't approved, or changes were requested
+	PullRequestNotApproved Event = "pull-request-not-approved"
+	// PullRequestNotMergeable denotes a pull request that was not merged because it has conflicts with its base branch
+	PullRequestNotMergeable Event = "pull-request-not-mergeable"
+	// PullRequestReadyToMerge denotes a pull request that would have been merged, had this not been a dry run
+	PullRequestReadyToMerge Event = "pull-request-ready-to-merge"
+	// PullRequestMerged denotes a pull request that was merged
+	PullRequestMerged Event = "pull-request-merged"
+	// PullRequestMergeErr denotes a pull request that was ready to merge, but the Github API failed to merge it
+	PullRequestMergeErr Event = "pull-request-merge-error"
+	// PullRequestReadyToClose denotes an open pull request that would have been closed and had its branch deleted, had this not been a dry run
+	PullRequestReadyToClose Event = "pull-request-ready-to-close"
+	// PullRequestClosed denotes a pull request that was closed without being merged
+	PullRequestClosed Event = "pull-request-closed"
+	// PullRequestCloseErr denotes a pull request that the Github API failed to close
+	PullRequestCloseErr Event = "pull-request-close-error"
+	// PullRequestCommentErr denotes a pull request that was left open because the --comment explaining why it's being closed could not be left on it
+	PullRequestCommentErr Event = "pull-request-comment-error"
+	// CampaignBranchDeleted denotes a repo whose branch was deleted from the remote origin
+	CampaignBranchDeleted Event = "campaign-branch-deleted"
+	// CampaignBranchDeleteErr denotes a repo whose branch could not be deleted from the remote origin
+	CampaignBranchDeleteErr Event = "campaign-branch-delete-error"
+	// CampaignBranchLookupErr denotes a repo in which the Github API failed to look up whether the branch still exists
+	CampaignBranchLookupErr Event = "campaign-branch-lookup-error"
+	// CampaignBranchReadyToDelete denotes a repo whose branch has no open pull request, and would have been deleted had this not been a dry run
+	CampaignBranchReadyToDelete Event = "campaign-branch-ready-to-delete"
+	// CampaignBranchIsBaseBranch denotes a repo whose default branch, or --base-branch, is the branch being cleaned up, so it was left alone
+	CampaignBranchIsBaseBranch Event = "campaign-branch-is-base-branch"
 )
 
 // AnnotatedEvent is used in printing the final report. It contains the info to print a section's table - both it's Event for looking up the tagged repos, and the human-legible description for printing above the table
+// Failure marks events that mean something went wrong with the repo, so the report can keep them apart from repos that simply needed no changes
+// Headline, when set, is a short label under which the repos filed under the event are counted in the header of the report.
+// Repos filed under several events sharing a headline are counted once
 type AnnotatedEvent struct {
 	Event       Event
 	Description string
+	Failure     bool
+	Headline    string
+}
+
+// HeadlineCount is the number of distinct repos filed under the events sharing a headline
+type HeadlineCount struct {
+	Headline string
+	Count    int
 }
 
 var allEvents = []AnnotatedEvent{
 	{Event: FetchedViaGithubAPI, Description: "Repos successfully fetched via Github API"},
 	{Event: DryRunSet, Description: "Repos that were not modified in any way because this was a dry-run"},
+	{Event: RepoLineMalformed, Description: "Lines of the allowed repos file or STDIN that could not be parsed as a repo", Failure: true},
+	{Event: RepoExcludedByFilter, Description: "Repos that were left out of processing by the --topic, --language, --exclude-* or --*-repo-regex filters"},
 	{Event: ReposSelected, Description: "All repos that were targeted for processing AFTER filtering missing / malformed repos"},
 	{Event: TargetBranchNotFound, Description: "Repos whose target branch was not found"},
 	{Event: TargetBranchAlreadyExists, Description: "Repos whose target branch already existed"},
-	{Event: TargetBranchLookupErr, Description: "Repos whose target branches could not be looked up due to an API error"},
+	{Event: TargetBranchLookupErr, Description: "Repos whose target branches could not be looked up due to an API error", Failure: true},
 	{Event: RepoSuccessfullyCloned, Description: "Repos that were successfully cloned to the local filesystem"},
-	{Event: RepoFailedToClone, Description: "Repos that were unable to be cloned to the local filesystem"},
-	{Event: BranchCheckoutFailed, Description: "Repos for which checking out a new tool-specific branch failed"},
-	{Event: GetHeadRefFailed, Description: "Repos for which the HEAD git reference could not be obtained"},
-	{Event: ScriptErrorOcurredDuringExecution, Description: "Repos for which at least one script raised an error during execution"},
-	{Event: WorktreeStatusCheckFailed, Description: "Repos for which the git status command failed following script execution"},
+	{Event: CloneCacheUpdateFailed, Description: "Repos whose mirror in the clone cache could not be updated, so they were cloned from Github instead"},
+	{Event: RepoFailedToClone, Description: "Repos that were unable to be cloned to the local filesystem", Failure: true},
+	{Event: BranchCheckoutFailed, Description: "Repos for which checking out a new tool-specific branch failed", Failure: true},
+	{Event: GetHeadRefFailed, Description: "Repos for which the HEAD git reference could not be obtained", Failure: true},
+	{Event: ScriptErrorOcurredDuringExecution, Description: "Repos for which at least one script raised an error during execution", Failure: true},
+	{Event: ScriptTimedOut, Description: "Repos for which a script was killed because it ran for longer than --script-timeout", Failure: true},
+	{Event: WorktreeStatusCheckFailed, Description: "Repos for which the git status command failed following script execution", Failure: true},
 	{Event: WorktreeStatusDirty, Description: "Repos that showed file changes to their working directory following script execution"},
 	{Event: WorktreeStatusClean, Description: "Repos that showed NO file changes to their working directory following script execution"},
-	{Event: CommitChangesFailed, Description: "Repos whose file changes failed to be comitted for some reason"},
-	{Event: PushBranchFailed, Description: "Repos whose tool-specific branch containing changes failed to push to remote origin"},
+	{Event: FilesLeftOut, Description: "Repos for which at least one file changed by the scripts was left out of the commit by --include or --exclude"},
+	{Event: WorktreeAddFileFailed, Description: "Repos for which at least one new file could not be added to the git stage", Failure: true},
+	{Event: MessageTemplateRenderFailed, Description: "Repos for which the commit message, pull request title or pull request description template could not be rendered", Failure: true},
+	{Event: CommitChangesFailed, Description: "Repos whose file changes failed to be comitted for some reason", Failure: true},
+	{Event: PushBranchFailed, Description: "Repos whose tool-specific branch containing changes failed to push to remote origin", Failure: true},
+	{Event: PushBranchSucceeded, Description: "Repos whose tool-specific branch containing changes was pushed to remote origin"},
 	{Event: PushBranchSkipped, Description: "Repos whose local branch was not pushed because the --dry-run flag was set"},
-	{Event: RepoNotExists, Description: "Repos that were passed via file but don't exist (404'd) via Github API"},
-	{Event: PullRequestOpenErr, Description: "Repos against which pull requests failed to be opened"},
+	{Event: RepoNotExists, Description: "Repos that were passed via file but don't exist (404'd) via Github API", Failure: true},
+	{Event: PullRequestOpenErr, Description: "Repos against which pull requests failed to be opened", Failure: true},
+	{Event: PullRequestUpdated, Description: "Repos whose already open pull request was updated, because --update-existing was set"},
+	{Event: PullRequestUpdateErr, Description: "Repos whose already open pull request could not be looked up or updated", Failure: true},
+	{Event: PullRequestReviewersErr, Description: "Repos whose pull request was opened, but whose --reviewers or --team-reviewers could not be requested", Failure: true},
+	{Event: PullRequestLabelsErr, Description: "Repos whose pull request was opened, but whose --labels could not be added", Failure: true},
+	{Event: PullRequestAssigneesErr, Description: "Repos whose pull request was opened, but whose --assignees could not be added", Failure: true},
+	{Event: PullRequestMilestoneErr, Description: "Repos whose pull request was opened, but could not be added to the --milestone", Failure: true},
+	{Event: TargetBranchExistsNotUpdated, Description: "Repos that were skipped because their target branch already exists. Pass --update-existing to add to it", Failure: true},
+	{Event: NoChangesRequired, Description: "Repos that needed no changes, so no commit, branch push or pull request was made"},
+	{Event: RepoSkippedAlreadyCompleted, Description: "Repos that were skipped because the resumed run had already completed them"},
+	{Event: RepoProcessingCancelled, Description: "Repos that were never processed because the run was cancelled"},
+}
+
+// mergeEvents are the events reported on by the merge subcommand. Blocked pull requests are counted apart from failures,
+// since most of them only need a human to look at them, rather than indicating that something went wrong
+var mergeEvents = []AnnotatedEvent{
+	{Event: CampaignPullRequestFound, Description: "Open pull requests of the campaign that were found"},
+	{Event: PullRequestMerged, Description: "Pull requests that were merged", Headline: "Pull requests merged"},
+	{Event: PullRequestReadyToMerge, Description: "Pull requests that were not merged because this was a dry-run, but are ready to merge", Headline: "Pull requests ready to merge"},
+	{Event: CampaignBranchDeleted, Description: "Repos whose branch was deleted once its pull request was merged"},
+	{Event: PullRequestNotOpen, Description: "Pull requests that were skipped because they were already merged or closed"},
+	{Event: PullRequestChecksFailed, Description: "Pull requests that were not merged because at least one of their checks failed", Headline: "Pull requests blocked"},
+	{Event: PullRequestChecksPending, Description: "Pull requests that were not merged because their checks were still running once --wait-timeout elapsed", Headline: "Pull requests blocked"},
+	{Event: PullRequestNotApproved, Description: "Pull requests that were not merged because they were not approved, or changes were requested", Headline: "Pull requests blocked"},
+	{Event: PullRequestNotMergeable, Description: "Pull requests that were not merged because they have conflicts with their base branch", Headline: "Pull requests blocked"},
+	{Event: PullRequestLookupErr, Description: "Pull requests whose state, checks or reviews could not be looked up", Failure: true},
+	{Event: PullRequestMergeErr, Description: "Pull requests that were ready, but failed to be merged", Failure: true},
+	{Event: CampaignBranchDeleteErr, Description: "Repos whose branch could not be deleted once its pull request was merged", Failure: true},
+	{Event: RepoProcessingCancelled, Description: "Pull requests that were never merged because the run was cancelled"},
+}
+
+// cleanupEvents are the events reported on by the cleanup subcommand
+var cleanupEvents = []AnnotatedEvent{
+	{Event: CampaignPullRequestFound, Description: "Pull requests of the campaign that were found"},
+	{Event: PullRequestClosed, Description: "Pull requests that were closed", Headline: "Pull requests closed"},
+	{Event: PullRequestReadyToClose, Description: "Pull requests that were not closed because this was a dry-run", Headline: "Pull requests ready to close"},
+	{Event: PullRequestNotOpen, Description: "Pull requests that were already merged or closed. The branches of those closed without being merged are still deleted"},
+	{Event: CampaignBranchDeleted, Description: "Repos whose branch was deleted", Headline: "Branches deleted"},
+	{Event: CampaignBranchReadyToDelete, Description: "Repos whose branch has no open pull request, and was not deleted because this was a dry-run", Headline: "Branches ready to delete"},
+	{Event: CampaignBranchIsBaseBranch, Description: "Repos whose branch was not deleted because it's the repo's default branch or --base-branch"},
+	{Event: PullRequestLookupErr, Description: "Pull requests that could not be looked up", Failure: true},
+	{Event: PullRequestCommentErr, Description: "Pull requests that were left open because --comment could not be left on them", Failure: true},
+	{Event: PullRequestCloseErr, Description: "Pull requests that failed to be closed", Failure: true},
+	{Event: CampaignBranchLookupErr, Description: "Repos whose branch could not be looked up", Failure: true},
+	{Event: CampaignBranchDeleteErr, Description: "Repos whose branch could not be deleted", Failure: true},
+	{Event: RepoProcessingCancelled, Description: "Pull requests that were never closed because the run was cancelled"},
+}
+
+// TimelineEntry is a single step in the ordered history of what happened to a repo during a run
+type TimelineEntry struct {
+	Event     Event
+	Timestamp time.Time
+	// Duration is the time elapsed since the previous entry tracked for the same repo, or since the start of the run
+	// for a repo's first entry, which approximates how long the repo spent in the stage that produced this event
+	Duration time.Duration
+	// Error is the text of the error that caused this event, if any
+	Error string
+}
+
+// ScriptOutput is the combined STDOUT and STDERR of a single script run against a single repo
+type ScriptOutput struct {
+	Script string
+	Output string
+	// LogFile is the path the output was stored at in the script log directory, if it was stored
+	LogFile string
+	// Error is the text of the error returned when running the script, if any
+	Error string
 }
 
 // RunStats will be a stats-tracker class that keeps score of which repos were touched, which were considered for update, which had branches made, PRs made, which were missing workflows or contexts, or had out of date workflows syntax values, etc
+// It is safe for concurrent use, since every repo is processed in its own goroutine
 type RunStats struct {
+	mu                sync.Mutex
 	repos             map[Event][]*github.Repository
 	pulls             map[string]string
+	timelines         map[string][]TimelineEntry
+	scriptOutputs     map[string][]ScriptOutput
 	fileProvidedRepos []*AllowedRepo
 	startTime         time.Time
+	journal           *RunJournal
+	// apiCalls tallies the Github API calls that were retried, which are shared by every Github client of the run
+	apiCalls *APICallStats
 }
 
 // NewStatsTracker initializes a tracker struct that is capable of keeping tabs on which repos were handled and how
@@ -97,12 +269,36 @@ func NewStatsTracker() *RunStats {
 	t := &RunStats{
 		repos:             make(map[Event][]*github.Repository),
 		pulls:             make(map[string]string),
+		timelines:         make(map[string][]TimelineEntry),
+		scriptOutputs:     make(map[string][]ScriptOutput),
 		fileProvidedRepos: fpr,
 		startTime:         time.Now(),
+		apiCalls:          repoHostAPICallStats,
 	}
 	return t
 }
 
+// GetAPICallCounts returns the counts of the Github API calls made so far that were retried or given up on
+func (r *RunStats) GetAPICallCounts() APICallCounts {
+	return r.apiCalls.Counts()
+}
+
+// SetJournal attaches a run-state journal to the tracker, so that every event tracked from here on is also journalled to disk
+func (r *RunStats) SetJournal(journal *RunJournal) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.journal = journal
+}
+
+// Journal returns the run-state journal attached to the tracker, which may be nil
+func (r *RunStats) Journal() *RunJournal {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.journal
+}
+
 // GetTotalRunSeconds returns the total time it took, in seconds, to run all the selected scripts against all the targeted repos
 func (r *RunStats) GetTotalRunSeconds() int {
 	s := time.Since(r.startTime).Seconds()
@@ -111,6 +307,9 @@ func (r *RunStats) GetTotalRunSeconds() int {
 
 // SetFileProvidedRepos sets the number of repos that were provided via file by the user on startup (as opposed to looked up via Github API via the --github-org flag)
 func (r *RunStats) SetFileProvidedRepos(fileProvidedRepos []*AllowedRepo) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	for _, ar := range fileProvidedRepos {
 		r.fileProvidedRepos = append(r.fileProvidedRepos, ar)
 	}
@@ -118,12 +317,99 @@ func (r *RunStats) SetFileProvidedRepos(fileProvidedRepos []*AllowedRepo) {
 
 // GetMultiple returns the slice of pointers to Github repositories filed under the provided event's key
 func (r *RunStats) GetMultiple(event Event) []*github.Repository {
-	return r.repos[event]
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	repos := make([]*github.Repository, len(r.repos[event]))
+	copy(repos, r.repos[event])
+	return repos
+}
+
+// GetPullRequests returns a copy of the map of repo names to the URLs of the pull requests opened against them
+func (r *RunStats) GetPullRequests() map[string]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	pulls := make(map[string]string, len(r.pulls))
+	for repoName, prURL := range r.pulls {
+		pulls[repoName] = prURL
+	}
+	return pulls
+}
+
+// GetTimeline returns a copy of the ordered history of events tracked against the repo with the supplied full name
+func (r *RunStats) GetTimeline(repoName string) []TimelineEntry {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	timeline := make([]TimelineEntry, len(r.timelines[repoName]))
+	copy(timeline, r.timelines[repoName])
+	return timeline
+}
+
+// GetFailedRepoNames returns the full names of every repo that had at least one event tracked with an error, in no particular order
+func (r *RunStats) GetFailedRepoNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for repoName, timeline := range r.timelines {
+		for _, entry := range timeline {
+			if entry.Error != "" {
+				names = append(names, repoName)
+				break
+			}
+		}
+	}
+	return names
 }
 
 // TrackSingle accepts an Event to associate with the supplied repo so that a final report can be generated at the end of each run
 func (r *RunStats) TrackSingle(event Event, repo *github.Repository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.track(event, repo, nil)
+}
+
+// TrackError is like TrackSingle, but also records the error that caused the event in the repo's timeline so that the
+// final report can show why the repo ended up filed under the event
+func (r *RunStats) TrackError(event Event, repo *github.Repository, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.track(event, repo, err)
+}
+
+// track files the repo under the event, appends an entry to its timeline and journals it. Callers must hold r.mu
+func (r *RunStats) track(event Event, repo *github.Repository, err error) {
 	r.repos[event] = TrackEventIfMissing(r.repos[event], repo)
+	r.appendTimelineEntry(repo, event, err)
+	r.journal.Record(repo, event, err)
+}
+
+// appendTimelineEntry adds the event to the end of the repo's timeline. Callers must hold r.mu
+func (r *RunStats) appendTimelineEntry(repo *github.Repository, event Event, err error) {
+	now := time.Now()
+	// Keyed by full name, since same-named repos of different owners can be operated on in the same run
+	key := journalKey(repo)
+	timeline := r.timelines[key]
+
+	previous := r.startTime
+	if len(timeline) > 0 {
+		previous = timeline[len(timeline)-1].Timestamp
+	}
+
+	entry := TimelineEntry{
+		Event:     event,
+		Timestamp: now,
+		Duration:  now.Sub(previous),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+
+	r.timelines[key] = append(timeline, entry)
 }
 
 // TrackEventIfMissing prevents the addition of duplicates to the tracking slices. Repos may end up with file changes
@@ -139,18 +425,175 @@ func TrackEventIfMissing(slice []*github.Repository, repo *github.Repository) []
 	return append(slice, repo)
 }
 
-func (r *RunStats) TrackPullRequest(repoName, prURL string) {
-	r.pulls[repoName] = prURL
+// CountFailedRepos returns the number of distinct repos filed under at least one of the supplied events that is marked as a Failure
+func (r *RunStats) CountFailedRepos(events []AnnotatedEvent) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	failed := make(map[string]bool)
+	for _, ae := range events {
+		if !ae.Failure {
+			continue
+		}
+		for _, repo := range r.repos[ae.Event] {
+			failed[repo.GetName()] = true
+		}
+	}
+	return len(failed)
+}
+
+// CountHeadlineRepos returns, for each headline among the supplied events, in the order they first appear, the number of
+// distinct repos filed under events with that headline
+func (r *RunStats) CountHeadlineRepos(events []AnnotatedEvent) []HeadlineCount {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var headlines []string
+	reposByHeadline := make(map[string]map[string]bool)
+	for _, ae := range events {
+		if ae.Headline == "" {
+			continue
+		}
+		if _, ok := reposByHeadline[ae.Headline]; !ok {
+			headlines = append(headlines, ae.Headline)
+			reposByHeadline[ae.Headline] = make(map[string]bool)
+		}
+		for _, repo := range r.repos[ae.Event] {
+			reposByHeadline[ae.Headline][repo.GetName()] = true
+		}
+	}
+
+	var counts []HeadlineCount
+	for _, headline := range headlines {
+		counts = append(counts, HeadlineCount{Headline: headline, Count: len(reposByHeadline[headline])})
+	}
+	return counts
+}
+
+// hasEvent returns true if the event is among the supplied events
+func hasEvent(events []AnnotatedEvent, event Event) bool {
+	for _, ae := range events {
+		if ae.Event == event {
+			return true
+		}
+	}
+	return false
+}
+
+// TrackPullRequest records the URL of the pull request that was opened against the repo, and files the repo under PullRequestOpened
+func (r *RunStats) TrackPullRequest(repo *github.Repository, prURL string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.pulls[repo.GetName()] = prURL
+
+	// The journal is updated directly, rather than via track, so that the URL is journalled alongside the stage
+	r.repos[PullRequestOpened] = TrackEventIfMissing(r.repos[PullRequestOpened], repo)
+	r.appendTimelineEntry(repo, PullRequestOpened, nil)
+	r.journal.RecordPullRequest(repo, prURL)
+}
+
+// TrackScriptOutput records the output of running the script (its path and any arguments) against the repo, the log file
+// it was stored in, and the error it returned, if any
+func (r *RunStats) TrackScriptOutput(repo *github.Repository, script, output, logFile string, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	scriptOutput := ScriptOutput{
+		Script:  script,
+		Output:  output,
+		LogFile: logFile,
+	}
+	if err != nil {
+		scriptOutput.Error = err.Error()
+	}
+
+	// Keyed by full name, since same-named repos of different owners can be operated on in the same run
+	key := journalKey(repo)
+	r.scriptOutputs[key] = append(r.scriptOutputs[key], scriptOutput)
+}
+
+// GetScriptOutputs returns a copy of the outputs of every script run against the repo with the supplied full name, in the
+// order they ran
+func (r *RunStats) GetScriptOutputs(repoName string) []ScriptOutput {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	outputs := make([]ScriptOutput, len(r.scriptOutputs[repoName]))
+	copy(outputs, r.scriptOutputs[repoName])
+	return outputs
+}
+
+// getRepoNamesWithScriptOutput returns the full names of every repo that had at least one script run against it, in no particular order
+func (r *RunStats) getRepoNamesWithScriptOutput() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for repoName := range r.scriptOutputs {
+		names = append(names, repoName)
+	}
+	return names
+}
+
+// getTimelineRepoNames returns the full names of every repo that had at least one event tracked against it, in no particular order
+func (r *RunStats) getTimelineRepoNames() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	var names []string
+	for repoName := range r.timelines {
+		names = append(names, repoName)
+	}
+	return names
 }
 
 // TrackMultiple accepts an Event and a slice of pointers to Github repos that will all be associated with that event
 func (r *RunStats) TrackMultiple(event Event, repos []*github.Repository) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
 	for _, repo := range repos {
-		r.TrackSingle(event, repo)
+		r.repos[event] = TrackEventIfMissing(r.repos[event], repo)
+		r.appendTimelineEntry(repo, event, nil)
 	}
+
+	// Journal the whole batch with a single write to disk, rather than one write per repo
+	r.journal.RecordMultiple(repos, event)
 }
 
-// PrintReport renders to STDOUT a summary of each repo that was considered by this tool and what happened to it during processing
+// PrintReport renders to STDOUT a summary of each repo that was considered by this tool and what happened to it during
+// processing, either as tables or as JSON depending on --output-format. If --report-file was set, the JSON report is
+// also written to that path
 func (r *RunStats) PrintReport() {
-	printRepoReport(allEvents, r)
+	r.printReport(allEvents)
+}
+
+// printReport renders the report for the supplied events in the format selected by the operator
+func (r *RunStats) printReport(events []AnnotatedEvent) {
+	report := buildRunReport(events, r)
+
+	if OutputFormat == OutputFormatJSON {
+		if err := writeJSONReport(os.Stdout, report); err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+			}).Debug("Error writing JSON run report to STDOUT")
+		}
+	} else {
+		printRepoReport(events, r)
+	}
+
+	if ReportFile != "" {
+		if err := writeJSONReportFile(ReportFile, report); err != nil {
+			log.WithFields(logrus.Fields{
+				"Error":       err,
+				"Report file": ReportFile,
+			}).Debug("Error writing JSON run report to file")
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"Report file": ReportFile,
+		}).Debug("Wrote JSON run report")
+	}
 }
@@ -0,0 +1,103 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/stretchr/testify/assert"
+)
+
+// Ensure that tracking from many goroutines at once, as processRepos does, neither races nor loses events
+func TestRunStatsIsSafeForConcurrentTracking(t *testing.T) {
+	stats := NewStatsTracker()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			repo := &github.Repository{Name: github.String(fmt.Sprintf("repo-%d", i))}
+			stats.TrackSingle(RepoSuccessfullyCloned, repo)
+			stats.TrackSingle(WorktreeStatusDirty, repo)
+			stats.TrackPullRequest(repo, fmt.Sprintf("https://github.com/gruntwork-io/repo-%d/pull/1", i))
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, len(stats.GetMultiple(RepoSuccessfullyCloned)), 50)
+	assert.Equal(t, len(stats.GetMultiple(WorktreeStatusDirty)), 50)
+	assert.Equal(t, len(stats.GetPullRequests()), 50)
+}
+
+func TestRunStatsRecordsOrderedTimelineWithErrors(t *testing.T) {
+	stats := NewStatsTracker()
+	repo := &github.Repository{Name: github.String("cloud-nuke"), FullName: github.String("gruntwork-io/cloud-nuke")}
+
+	stats.TrackSingle(RepoSuccessfullyCloned, repo)
+	stats.TrackError(PushBranchFailed, repo, errors.New("authentication required"))
+
+	timeline := stats.GetTimeline("gruntwork-io/cloud-nuke")
+
+	assert.Equal(t, len(timeline), 2)
+	assert.Equal(t, timeline[0].Event, RepoSuccessfullyCloned)
+	assert.Empty(t, timeline[0].Error)
+	assert.Equal(t, timeline[1].Event, PushBranchFailed)
+	assert.Equal(t, timeline[1].Error, "authentication required")
+	assert.False(t, timeline[1].Timestamp.Before(timeline[0].Timestamp))
+
+	assert.Equal(t, stats.GetFailedRepoNames(), []string{"gruntwork-io/cloud-nuke"})
+}
+
+func TestRunStatsKeepsTimelinesOfSameNamedReposApart(t *testing.T) {
+	stats := NewStatsTracker()
+	orgA := &github.Repository{Name: github.String("infra"), FullName: github.String("org-a/infra")}
+	orgB := &github.Repository{Name: github.String("infra"), FullName: github.String("org-b/infra")}
+
+	stats.TrackSingle(RepoSuccessfullyCloned, orgA)
+	stats.TrackError(RepoFailedToClone, orgB, errors.New("authentication required"))
+
+	assert.Equal(t, len(stats.GetTimeline("org-a/infra")), 1)
+	assert.Equal(t, len(stats.GetTimeline("org-b/infra")), 1)
+	assert.Equal(t, stats.GetFailedRepoNames(), []string{"org-b/infra"})
+}
+
+func TestRunStatsKeepsScriptOutputsOfSameNamedReposApart(t *testing.T) {
+	stats := NewStatsTracker()
+	orgA := &github.Repository{Name: github.String("infra"), FullName: github.String("org-a/infra")}
+	orgB := &github.Repository{Name: github.String("infra"), FullName: github.String("org-b/infra")}
+
+	stats.TrackScriptOutput(orgA, "/tmp/update.sh", "updated org-a\n", "", nil)
+	stats.TrackScriptOutput(orgB, "/tmp/update.sh", "updated org-b\n", "", nil)
+
+	assert.Equal(t, stats.GetScriptOutputs("org-a/infra"), []ScriptOutput{{Script: "/tmp/update.sh", Output: "updated org-a\n"}})
+	assert.Equal(t, stats.GetScriptOutputs("org-b/infra"), []ScriptOutput{{Script: "/tmp/update.sh", Output: "updated org-b\n"}})
+}
+
+func TestTrackSingleDoesNotDuplicateRepoUnderEvent(t *testing.T) {
+	stats := NewStatsTracker()
+	repo := &github.Repository{Name: github.String("fetch"), FullName: github.String("gruntwork-io/fetch")}
+
+	stats.TrackSingle(WorktreeStatusDirty, repo)
+	stats.TrackSingle(WorktreeStatusDirty, repo)
+
+	assert.Equal(t, len(stats.GetMultiple(WorktreeStatusDirty)), 1)
+	// The timeline keeps every occurrence, since each one is a distinct step in the repo's history
+	assert.Equal(t, len(stats.GetTimeline("gruntwork-io/fetch")), 2)
+}
+
+func TestCountFailedReposOnlyCountsFailureEvents(t *testing.T) {
+	stats := NewStatsTracker()
+	failedRepo := &github.Repository{Name: github.String("terragrunt")}
+	unchangedRepo := &github.Repository{Name: github.String("fetch")}
+
+	stats.TrackSingle(RepoFailedToClone, failedRepo)
+	stats.TrackSingle(PushBranchFailed, failedRepo)
+	stats.TrackSingle(WorktreeStatusClean, unchangedRepo)
+	stats.TrackSingle(NoChangesRequired, unchangedRepo)
+
+	// The failed repo is counted once, even though it was filed under two failure events
+	assert.Equal(t, stats.CountFailedRepos(allEvents), 1)
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/go-github/v32/github"
+	"github.com/landoop/tableprinter"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+// CampaignStatusSchemaVersion is the version of the JSON document printed by the status subcommand. It must be
+// incremented whenever a field is removed or changes meaning
+const CampaignStatusSchemaVersion = 1
+
+// CampaignStatusReport is where every pull request of a campaign stands, as printed by the status subcommand
+type CampaignStatusReport struct {
+	SchemaVersion int                 `json:"schema_version"`
+	GeneratedAt   time.Time           `json:"generated_at"`
+	BranchName    string              `json:"branch_name"`
+	RunReport     string              `json:"run_report,omitempty"`
+	Summary       map[string]int      `json:"summary"`
+	PullRequests  []PullRequestStatus `json:"pull_requests"`
+	Errors        []ReportError       `json:"errors"`
+}
+
+func init() {
+	statusCmd.Flags().StringVar(&CampaignRunReport, "run-report", "", "The path of a JSON run report saved via --report-file, whose pull requests should be reported on. Otherwise, the pull requests of --branch-name are looked up")
+
+	rootCmd.AddCommand(statusCmd)
+}
+
+var statusCmd = &cobra.Command{
+	Use:              "status",
+	Short:            "Show where the pull requests opened by a previous run stand",
+	Long:             "Show whether each pull request opened by a previous run, read from a saved run report or looked up by branch name, is open, merged or closed, along with its review state, CI state and mergeability",
+	PersistentPreRun: campaignPreRun,
+	Run: func(cmd *cobra.Command, args []string) {
+		ctx, cancel := cancelOnInterrupt()
+		defer cancel()
+
+		GithubClient := ConfigureGithubClient(ctx)
+
+		stats := NewStatsTracker()
+
+		prs, err := selectCampaignPullRequests(GithubClient, false, stats)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+			}).Fatal("Error looking up the pull requests of the campaign")
+		}
+
+		var mu sync.Mutex
+		var statuses []PullRequestStatus
+
+		forEachCampaignPullRequest(ctx, prs, stats, func(pr CampaignPullRequest, repo *github.Repository) {
+			status, statusErr := getPullRequestStatus(GithubClient, pr, repo, stats)
+			if statusErr != nil {
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			statuses = append(statuses, status)
+		})
+
+		printCampaignStatus(buildCampaignStatusReport(statuses, stats))
+	},
+}
+
+// getPullRequestStatus looks up where the pull request stands, tracking an error against the repo if it can't be looked up
+func getPullRequestStatus(githubClient *github.Client, pr CampaignPullRequest, repo *github.Repository, stats *RunStats) (PullRequestStatus, error) {
+	state, err := getPullRequestState(githubClient, pr)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error":            err,
+			"Pull Request URL": pr.URL,
+		}).Debug("Error looking up the state of pull request")
+
+		stats.TrackError(PullRequestLookupErr, repo, err)
+		return PullRequestStatus{}, err
+	}
+
+	return PullRequestStatus{
+		Repo:      repo.GetFullName(),
+		URL:       pr.URL,
+		State:     state.State,
+		Review:    state.Review,
+		Checks:    state.Checks,
+		Mergeable: state.Mergeable,
+	}, nil
+}
+
+// buildCampaignStatusReport sorts the statuses by repo and counts the pull requests in each state, along with how many
+// are ready to merge, and collects every pull request that could not be looked up
+func buildCampaignStatusReport(statuses []PullRequestStatus, stats *RunStats) CampaignStatusReport {
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Repo < statuses[j].Repo })
+
+	report := CampaignStatusReport{
+		SchemaVersion: CampaignStatusSchemaVersion,
+		GeneratedAt:   time.Now().UTC(),
+		BranchName:    BranchName,
+		RunReport:     CampaignRunReport,
+		Summary:       map[string]int{"open": 0, "merged": 0, "closed": 0, "ready_to_merge": 0},
+		PullRequests:  []PullRequestStatus{},
+		Errors:        []ReportError{},
+	}
+
+	for _, status := range statuses {
+		report.Summary[status.State]++
+		if status.readyToMerge() {
+			report.Summary["ready_to_merge"]++
+		}
+		report.PullRequests = append(report.PullRequests, status)
+	}
+
+	for _, repo := range stats.GetMultiple(PullRequestLookupErr) {
+		for _, entry := range stats.GetTimeline(journalKey(repo)) {
+			if entry.Error != "" {
+				report.Errors = append(report.Errors, ReportError{
+					Repo:      repo.GetFullName(),
+					Event:     entry.Event,
+					Timestamp: entry.Timestamp.UTC(),
+					Error:     entry.Error,
+				})
+			}
+		}
+	}
+
+	return report
+}
+
+// readyToMerge returns true if the pull request is open, green, approved and free of conflicts
+func (s PullRequestStatus) readyToMerge() bool {
+	return s.State == "open" && s.Checks == ChecksSuccess && s.Review == ReviewApproved && s.Mergeable == MergeableClean
+}
+
+// printCampaignStatus renders the status of the campaign to STDOUT, either as a table or as JSON depending on
+// --output-format. If --report-file was set, the JSON document is also written to that path
+func printCampaignStatus(report CampaignStatusReport) {
+	if OutputFormat == OutputFormatJSON {
+		if err := writeJSONReport(os.Stdout, report); err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+			}).Debug("Error writing JSON campaign status to STDOUT")
+		}
+	} else {
+		printCampaignStatusTable(report)
+	}
+
+	if ReportFile != "" {
+		if err := writeJSONReportFile(ReportFile, report); err != nil {
+			log.WithFields(logrus.Fields{
+				"Error":       err,
+				"Report file": ReportFile,
+			}).Debug("Error writing JSON campaign status to file")
+			return
+		}
+
+		log.WithFields(logrus.Fields{
+			"Report file": ReportFile,
+		}).Debug("Wrote JSON campaign status")
+	}
+}
+
+// printCampaignStatusTable prints the counts of the campaign's pull requests in each state, followed by a table of every
+// pull request and a table of every pull request that could not be looked up
+func printCampaignStatusTable(report CampaignStatusReport) {
+	fmt.Print("\n\n")
+	fmt.Println("*****************************************************")
+	fmt.Printf("  CAMPAIGN STATUS @ %v\n", report.GeneratedAt)
+	fmt.Printf("  Branch: %s\n", report.BranchName)
+	fmt.Printf("  Open pull requests: %d (ready to merge: %d)\n", report.Summary["open"], report.Summary["ready_to_merge"])
+	fmt.Printf("  Merged pull requests: %d\n", report.Summary["merged"])
+	fmt.Printf("  Closed pull requests: %d\n", report.Summary["closed"])
+	fmt.Printf("  Pull requests that could not be looked up: %d\n", len(report.Errors))
+	fmt.Println("*****************************************************")
+
+	if len(report.PullRequests) > 0 {
+		fmt.Println()
+		statusPrinter := tableprinter.New(os.Stdout)
+		configurePrinterStyling(statusPrinter)
+		statusPrinter.Print(report.PullRequests)
+		fmt.Println()
+	}
+
+	if len(report.Errors) > 0 {
+		fmt.Println()
+		fmt.Println(" PULL REQUESTS THAT COULD NOT BE LOOKED UP")
+		var rows []TimelineRow
+		for _, reportErr := range report.Errors {
+			rows = append(rows, TimelineRow{
+				Repo:  reportErr.Repo,
+				Event: string(reportErr.Event),
+				Time:  reportErr.Timestamp.Format("15:04:05.000"),
+				Error: reportErr.Error,
+			})
+		}
+		errorPrinter := tableprinter.New(os.Stdout)
+		configurePrinterStyling(errorPrinter)
+		errorPrinter.Print(rows)
+		fmt.Println()
+	}
+}
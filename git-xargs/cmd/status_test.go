@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGetPullRequestStatusReportsChecksReviewAndMergeability(t *testing.T) {
+	client, closeServer := newTestGithubClient(t, newCampaignPullRequestMux(t, false, "success", "failure", "APPROVED"))
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	status, err := getPullRequestStatus(client, pr, pr.repository(), stats)
+
+	require.NoError(t, err)
+	assert.Equal(t, status, PullRequestStatus{
+		Repo:      "gruntwork-io/cloud-nuke",
+		URL:       pr.URL,
+		State:     "open",
+		Review:    ReviewApproved,
+		Checks:    ChecksFailure,
+		Mergeable: MergeableConflicting,
+	})
+	assert.False(t, status.readyToMerge())
+}
+
+func TestGetPullRequestStatusTracksLookupErrors(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/repos/gruntwork-io/cloud-nuke/pulls/7", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	client, closeServer := newTestGithubClient(t, mux)
+	defer closeServer()
+
+	pr := makeTestCampaignPullRequest()
+	stats := NewStatsTracker()
+	_, err := getPullRequestStatus(client, pr, pr.repository(), stats)
+
+	assert.Error(t, err)
+
+	report := buildCampaignStatusReport(nil, stats)
+	require.Equal(t, len(report.Errors), 1)
+	assert.Equal(t, report.Errors[0].Repo, "gruntwork-io/cloud-nuke")
+	assert.Equal(t, report.Errors[0].Event, PullRequestLookupErr)
+}
+
+func TestBuildCampaignStatusReportCountsStatesAndSortsByRepo(t *testing.T) {
+	statuses := []PullRequestStatus{
+		{Repo: "gruntwork-io/terratest", State: "merged", Review: ReviewApproved, Checks: ChecksSuccess, Mergeable: MergeableUnknown},
+		{Repo: "gruntwork-io/fetch", State: "open", Review: ReviewRequired, Checks: ChecksPending, Mergeable: MergeableClean},
+		{Repo: "gruntwork-io/cloud-nuke", State: "open", Review: ReviewApproved, Checks: ChecksSuccess, Mergeable: MergeableClean},
+		{Repo: "gruntwork-io/bash-commons", State: "closed", Review: ReviewChangesRequested, Checks: ChecksFailure, Mergeable: MergeableConflicting},
+	}
+
+	report := buildCampaignStatusReport(statuses, NewStatsTracker())
+
+	assert.Equal(t, report.SchemaVersion, CampaignStatusSchemaVersion)
+	assert.Equal(t, report.Summary, map[string]int{"open": 2, "merged": 1, "closed": 1, "ready_to_merge": 1})
+	require.Equal(t, len(report.PullRequests), 4)
+	assert.Equal(t, report.PullRequests[0].Repo, "gruntwork-io/bash-commons")
+	assert.Equal(t, report.PullRequests[3].Repo, "gruntwork-io/terratest")
+	assert.Empty(t, report.Errors)
+}
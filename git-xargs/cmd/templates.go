@@ -0,0 +1,169 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/google/go-github/v32/github"
+	"github.com/sirupsen/logrus"
+)
+
+// TemplateData is the data available to the Go templates in --commit-message, --pull-request-title,
+// --pull-request-description and --pull-request-description-file, e.g., "Update {{.RepoName}}"
+type TemplateData struct {
+	RepoName      string
+	RepoOwner     string
+	RepoFullName  string
+	DefaultBranch string
+	BaseBranch    string
+	BranchName    string
+	// ChangedFiles are the paths, relative to the root of the repo, of every file the scripts added, modified or deleted.
+	// It is empty when a resumed run only has to open the pull request, since the changes were made by the previous run
+	ChangedFiles []string
+	// Scripts are the scripts and inline commands that were run against the repo, as they were passed to git-xargs
+	Scripts []string
+	DryRun  bool
+}
+
+// RenderedMessages are the commit message, pull request title and pull request description rendered for a single repo
+type RenderedMessages struct {
+	CommitMessage          string
+	PullRequestTitle       string
+	PullRequestDescription string
+}
+
+// templateFuncs are the functions available to message templates, in addition to the text/template builtins
+var templateFuncs = template.FuncMap{
+	"join": strings.Join,
+}
+
+// newTemplateData builds the template data for the repo. changedFiles may be nil if the changes aren't known
+func newTemplateData(repo *github.Repository, baseBranch string, scriptsCollection ScriptCollection, changedFiles []string, dryRun bool) TemplateData {
+	var scripts []string
+	for _, script := range scriptsCollection.Scripts {
+		scripts = append(scripts, script.String())
+	}
+
+	return TemplateData{
+		RepoName:      repo.GetName(),
+		RepoOwner:     repo.GetOwner().GetLogin(),
+		RepoFullName:  repo.GetFullName(),
+		DefaultBranch: repo.GetDefaultBranch(),
+		BaseBranch:    baseBranch,
+		BranchName:    BranchName,
+		ChangedFiles:  changedFiles,
+		Scripts:       scripts,
+		DryRun:        dryRun,
+	}
+}
+
+// changedFilePaths returns the sorted paths of every file with changes in the worktree status
+func changedFilePaths(status git.Status) []string {
+	var paths []string
+	for path := range status {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// parseMessageTemplate parses a single message template, naming it after the flag it was passed via so that errors
+// point the operator to the right flag
+func parseMessageTemplate(flagName, text string) (*template.Template, error) {
+	tmpl, err := template.New(flagName).Funcs(templateFuncs).Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template in --%s: %s", flagName, err)
+	}
+	return tmpl, nil
+}
+
+// renderMessageTemplate renders a single message template against the data
+func renderMessageTemplate(flagName, text string, data TemplateData) (string, error) {
+	tmpl, err := parseMessageTemplate(flagName, text)
+	if err != nil {
+		return "", err
+	}
+
+	var rendered bytes.Buffer
+	if err := tmpl.Execute(&rendered, data); err != nil {
+		return "", fmt.Errorf("could not render --%s: %s", flagName, err)
+	}
+	return rendered.String(), nil
+}
+
+// verifyMessageTemplates checks that the commit message, pull request title and pull request description are valid
+// templates, and renders them once against sample data, so that mistakes such as a misspelled field are caught before
+// any repo is processed
+func verifyMessageTemplates(commitMessage, pullRequestTitle, pullRequestDescription string) error {
+	sample := TemplateData{
+		RepoName:      "repo",
+		RepoOwner:     "owner",
+		RepoFullName:  "owner/repo",
+		DefaultBranch: "main",
+		BaseBranch:    "main",
+		BranchName:    BranchName,
+		ChangedFiles:  []string{"README.md"},
+		Scripts:       []string{"script.sh"},
+	}
+
+	_, err := renderMessages(commitMessage, pullRequestTitle, pullRequestDescription, sample)
+	return err
+}
+
+// renderMessages renders the commit message, pull request title and pull request description templates for one repo
+func renderMessages(commitMessage, pullRequestTitle, pullRequestDescription string, data TemplateData) (RenderedMessages, error) {
+	var messages RenderedMessages
+	var err error
+
+	if messages.CommitMessage, err = renderMessageTemplate("commit-message", commitMessage, data); err != nil {
+		return messages, err
+	}
+	if messages.PullRequestTitle, err = renderMessageTemplate("pull-request-title", pullRequestTitle, data); err != nil {
+		return messages, err
+	}
+	if messages.PullRequestDescription, err = renderMessageTemplate("pull-request-description", pullRequestDescription, data); err != nil {
+		return messages, err
+	}
+
+	return messages, nil
+}
+
+// renderRepoMessages renders the commit message, pull request title and pull request description passed for this run
+// against the repo, tracking an error against the repo if any of them can't be rendered
+func renderRepoMessages(dryRun bool, repo *github.Repository, baseBranch string, scriptsCollection ScriptCollection, changedFiles []string, stats *RunStats) (RenderedMessages, error) {
+	data := newTemplateData(repo, baseBranch, scriptsCollection, changedFiles, dryRun)
+
+	messages, err := renderMessages(CommitMessage, PullRequestTitle, PullRequestDescription, data)
+	if err != nil {
+		log.WithFields(logrus.Fields{
+			"Error": err,
+			"Repo":  repo.GetName(),
+		}).Debug("Error rendering message templates")
+
+		stats.TrackError(MessageTemplateRenderFailed, repo, err)
+		return messages, err
+	}
+
+	log.WithFields(logrus.Fields{
+		"Repo":               repo.GetName(),
+		"Commit message":     messages.CommitMessage,
+		"Pull request title": messages.PullRequestTitle,
+	}).Debug("Rendered message templates")
+
+	return messages, nil
+}
+
+// readPullRequestDescriptionFile returns the contents of the file passed via --pull-request-description-file, which are
+// used as the pull request description template
+func readPullRequestDescriptionFile(path string) (string, error) {
+	contents, err := ioutil.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(contents), nil
+}
@@ -0,0 +1,90 @@
+package cmd
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderMessagesUsesRepoContext(t *testing.T) {
+	sc := ScriptCollection{}
+	sc.Add(Script{Path: "/tmp/add-license.sh"})
+	sc.Add(Script{Command: "go mod tidy"})
+
+	data := newTemplateData(makeTestRepo(), "main", sc, []string{"LICENSE", "go.sum"}, false)
+
+	messages, err := renderMessages(
+		"Add license to {{.RepoName}}",
+		"[{{.RepoOwner}}] Add license",
+		"Changed {{join .ChangedFiles \", \"}} on {{.BaseBranch}} by running:\n{{range .Scripts}}- {{.}}\n{{end}}",
+		data,
+	)
+
+	require.NoError(t, err)
+	assert.Equal(t, messages.CommitMessage, "Add license to cloud-nuke")
+	assert.Equal(t, messages.PullRequestTitle, "[gruntwork-io] Add license")
+	assert.Equal(t, messages.PullRequestDescription, "Changed LICENSE, go.sum on main by running:\n- /tmp/add-license.sh\n- go mod tidy\n")
+}
+
+func TestRenderMessagesLeavesPlainTextAlone(t *testing.T) {
+	messages, err := renderMessages("Tis I, git-xargs!", "git-xargs programmatic pr", "No templating here", TemplateData{})
+
+	require.NoError(t, err)
+	assert.Equal(t, messages, RenderedMessages{
+		CommitMessage:          "Tis I, git-xargs!",
+		PullRequestTitle:       "git-xargs programmatic pr",
+		PullRequestDescription: "No templating here",
+	})
+}
+
+func TestVerifyMessageTemplatesRejectsBadTemplates(t *testing.T) {
+	// Unterminated action
+	assert.Error(t, verifyMessageTemplates("Update {{.RepoName", "title", "body"))
+	// Misspelled field
+	err := verifyMessageTemplates("message", "Update {{.RepoNmae}}", "body")
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "--pull-request-title")
+
+	assert.NoError(t, verifyMessageTemplates("Update {{.RepoName}}", "title", "{{join .ChangedFiles \"\\n\"}}"))
+}
+
+func TestRenderRepoMessagesTracksRenderFailure(t *testing.T) {
+	originalTitle := PullRequestTitle
+	defer func() { PullRequestTitle = originalTitle }()
+	PullRequestTitle = "Update {{index .ChangedFiles 5}}"
+
+	stats := NewStatsTracker()
+	_, err := renderRepoMessages(false, makeTestRepo(), "master", ScriptCollection{}, []string{"README.md"}, stats)
+
+	assert.Error(t, err)
+	assert.Equal(t, len(stats.GetMultiple(MessageTemplateRenderFailed)), 1)
+}
+
+func TestChangedFilePathsAreSorted(t *testing.T) {
+	status := git.Status{
+		"z.txt":     &git.FileStatus{Worktree: git.Modified},
+		"a/b.txt":   &git.FileStatus{Worktree: git.Untracked},
+		"README.md": &git.FileStatus{Worktree: git.Deleted},
+	}
+
+	assert.Equal(t, changedFilePaths(status), []string{"README.md", "a/b.txt", "z.txt"})
+}
+
+func TestReadPullRequestDescriptionFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "git-xargs-pr-description")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("Updates {{.RepoFullName}}\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	description, err := readPullRequestDescriptionFile(file.Name())
+
+	require.NoError(t, err)
+	assert.Equal(t, description, "Updates {{.RepoFullName}}\n")
+}
@@ -1,5 +1,7 @@
 package cmd
 
+import "strings"
+
 // AllowedRepo represents a single repository under a Github organization that this tool may operate on
 type AllowedRepo struct {
 	Organization string `header:"Organization name"`
@@ -18,9 +20,49 @@ type PullRequest struct {
 	URL  string `header:"PR URL"`
 }
 
-// Script represents a single shell script to be run against a repo
+// TimelineRow is a single entry of a repo's event timeline, flattened for printing in the final run report
+type TimelineRow struct {
+	Repo     string `header:"Repo name"`
+	Event    string `header:"Event"`
+	Time     string `header:"Time (UTC)"`
+	Duration string `header:"Duration"`
+	Error    string `header:"Error"`
+}
+
+// ScriptOutputRow is the trailing output of a single failed script run against a single repo, for printing in the final run report
+type ScriptOutputRow struct {
+	Repo      string `header:"Repo name"`
+	Script    string `header:"Script"`
+	LogFile   string `header:"Log file"`
+	LastLines string `header:"Last lines of output"`
+}
+
+// PullRequestStatus is where a single pull request of a campaign stands, for printing by the status subcommand
+type PullRequestStatus struct {
+	Repo      string `header:"Repo name" json:"repo"`
+	URL       string `header:"PR URL" json:"url"`
+	State     string `header:"State" json:"state"`
+	Review    string `header:"Review" json:"review"`
+	Checks    string `header:"Checks" json:"checks"`
+	Mergeable string `header:"Mergeable" json:"mergeable"`
+}
+
+// Script represents a single shell script to be run against a repo, along with any user-supplied arguments to pass to it,
+// or a single inline shell command passed via --cmd
 type Script struct {
 	Path string
+	Args []string
+	// Command, when set, is an inline shell command that is run via `sh -c` instead of a script file
+	Command string
+}
+
+// String returns the script's path followed by its arguments, as it would be typed at a shell, or the inline command
+// itself, for logging and reporting
+func (s Script) String() string {
+	if s.Command != "" {
+		return s.Command
+	}
+	return strings.Join(append([]string{s.Path}, s.Args...), " ")
 }
 
 // Script collection contains a slice of scripts that are to be executed against the local copies of each targeted repo
@@ -32,3 +74,14 @@ type ScriptCollection struct {
 func (sc *ScriptCollection) Add(s Script) {
 	sc.Scripts = append(sc.Scripts, s)
 }
+
+// InlineCommands returns every inline shell command in the collection, in the order they are run
+func (sc ScriptCollection) InlineCommands() []string {
+	var commands []string
+	for _, s := range sc.Scripts {
+		if s.Command != "" {
+			commands = append(commands, s.Command)
+		}
+	}
+	return commands
+}
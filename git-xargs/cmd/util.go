@@ -1,20 +1,26 @@
 package cmd
 
 import (
+	"context"
+
 	"github.com/google/go-github/v32/github"
 
 	"github.com/sirupsen/logrus"
 )
 
-// There are two ways to select repos to operate on via this tool: 1. the --github-org flag, which accepts the Github
-// organization name to look up all the repos for and to page through them programmatically and
-// 2. the user-defined flatfile of repos in the format of 'gruntwork-io/cloud-nuke' with one repos defined one per line
-// This function acts as a switch, depending upon whether or not the user provided an explicit list of repos to operate
-// However, even though there are two methods for users to select repos, we still only want a single uniform interface
+// There are four ways to select repos to operate on via this tool, in order of preference: 1. the user-defined flatfile
+// of repos in the format of 'gruntwork-io/cloud-nuke' with one repos defined one per line, 2. the --repo-search flag,
+// which accepts a Github search query, 3. the --user flag, which accepts a personal Github account name and
+// 4. the --github-org flag, which accepts the Github organization name to look up all the repos for and to page through
+// them programmatically. Whichever method is used, the resulting repos are then narrowed down by the supplied filters
+// This function acts as a switch, depending upon which method the user chose to select the repos to operate on
+// However, even though there are four methods for users to select repos, we still only want a single uniform interface
 // for dealing with a repo throughout this tool, and that is the *github.Repository type provided by the go-github
-// library. Therefore, this function serves the purpose of creating that uniform interface, by looking up flatfile-provided
-// repos via go-github, so that we're only ever dealing with pointers to github.Repositories going forward
-func OperateOnRepos(GithubClient *github.Client, GithubOrg string, allowedRepos []*AllowedRepo, scripts ScriptCollection, stats *RunStats) {
+// library. The search, user and organization lookups already return that type, so this function completes the uniform
+// interface by looking up flatfile-provided repos via the RepoHost, so that we're only ever dealing with pointers to
+// github.Repositories going forward, whether the repos are hosted on Github or GitLab
+// Cancelling ctx stops any repos that have not yet started processing from being picked up
+func OperateOnRepos(ctx context.Context, host RepoHost, GithubOrg string, allowedRepos []*AllowedRepo, filters *RepoFilters, scripts ScriptCollection, stats *RunStats) {
 
 	var reposToIterate []*github.Repository
 	// Prefer repos passed in via file over the user-supplied command line flag for GithubOrg
@@ -23,8 +29,8 @@ func OperateOnRepos(GithubClient *github.Client, GithubOrg string, allowedRepos
 		log.Debug("Allowed repos were provided via file, preferring them over -o --github-org flag's value")
 
 		// Per the comment above, this helper method turns all the flatfile defined repos into pointers to the
-		// github.Repository type provided by go-github
-		repos, err := getFileDefinedRepos(GithubClient, allowedRepos, stats)
+		// github.Repository type provided by go-github, whichever host they're on
+		repos, err := getFileDefinedRepos(host, allowedRepos, stats)
 		if err != nil {
 			log.WithFields(logrus.Fields{
 				"Error":         err,
@@ -32,12 +38,38 @@ func OperateOnRepos(GithubClient *github.Client, GithubOrg string, allowedRepos
 			}).Debug("error looking up filename provided repos")
 		}
 
+		reposToIterate = repos
+	} else if RepoSearchQuery != "" {
+
+		// In this code path, the user asked for every repo matching a Github search query
+		repos, err := getReposBySearch(host, RepoSearchQuery, stats)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+				"Query": RepoSearchQuery,
+			}).Debug("Failure looking up repos matching search query")
+			return
+		}
+
+		reposToIterate = repos
+	} else if GithubUser != "" {
+
+		// In this code path, the user asked for every repo owned by a personal Github account
+		repos, err := getReposByUser(host, GithubUser, stats)
+		if err != nil {
+			log.WithFields(logrus.Fields{
+				"Error": err,
+				"User":  GithubUser,
+			}).Debug("Failure looking up repos for user")
+			return
+		}
+
 		reposToIterate = repos
 	} else {
 
 		// In this code path, the user did not provide a flatfile, so we're just looking up all the Github
 		// repos via their Organization name via the Github API
-		repos, err := getReposByOrg(GithubClient, GithubOrg, stats)
+		repos, err := getReposByOrg(host, GithubOrg, stats)
 		if err != nil {
 			log.WithFields(logrus.Fields{
 				"Error":        err,
@@ -49,9 +81,15 @@ func OperateOnRepos(GithubClient *github.Client, GithubOrg string, allowedRepos
 		reposToIterate = repos
 	}
 
+	// However the repos were selected, narrow them down by the user-supplied filters, such as --topic or --exclude-archived
+	reposToIterate = filterRepos(reposToIterate, filters, stats)
+
 	// Track the repos selected for processing
 	stats.TrackMultiple(ReposSelected, reposToIterate)
 
+	// When resuming a previous run, leave out the repos it already finished with, so only failed or unfinished repos are retried
+	reposToIterate = skipCompletedRepos(reposToIterate, stats)
+
 	for _, repo := range reposToIterate {
 		log.WithFields(logrus.Fields{
 			"Repository": repo.GetName(),
@@ -60,5 +98,25 @@ func OperateOnRepos(GithubClient *github.Client, GithubOrg string, allowedRepos
 
 	// Now that we've gathered up the repos we're going to operate on, do the actual processing by running the
 	// user-defined scripts against each repo and handling the resulting git operations that follow
-	processRepos(DryRun, GithubClient, reposToIterate, scripts, stats)
+	processRepos(ctx, DryRun, host, reposToIterate, scripts, stats)
+}
+
+// skipCompletedRepos filters out any repos that the run-state journal shows were already completed by a previous run,
+// tracking each of them as skipped
+func skipCompletedRepos(repos []*github.Repository, stats *RunStats) []*github.Repository {
+	journal := stats.Journal()
+
+	var remaining []*github.Repository
+	for _, repo := range repos {
+		if journal.IsCompleted(repo) {
+			log.WithFields(logrus.Fields{
+				"Repository": repo.GetName(),
+			}).Debug("Repo was already completed by the run being resumed, skipping it")
+
+			stats.TrackSingle(RepoSkippedAlreadyCompleted, repo)
+			continue
+		}
+		remaining = append(remaining, repo)
+	}
+	return remaining
 }
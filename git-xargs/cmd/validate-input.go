@@ -1,6 +1,12 @@
 package cmd
 
-// Sanity check that user has provided one valid method for selecting repos to operate on
-func ensureValidOptionsPassed(allowedReposFile, GithubOrg string) bool {
-	return allowedReposFile != "" || GithubOrg != ""
+// Sanity check that user has provided at least one valid method for selecting repos to operate on, such as an allowed
+// repos file, a Github org, a Github user or a search query
+func ensureValidOptionsPassed(repoSelectors ...string) bool {
+	for _, selector := range repoSelectors {
+		if selector != "" {
+			return true
+		}
+	}
+	return false
 }
@@ -19,3 +19,10 @@ func TestEnsureValidOptionsPassedAcceptsValidGithubOrg(t *testing.T) {
 
 	assert.True(t, ok)
 }
+
+func TestEnsureValidOptionsPassedAcceptsAnySingleSelector(t *testing.T) {
+
+	ok := ensureValidOptionsPassed("", "", "", "org:gruntwork-io topic:terraform")
+
+	assert.True(t, ok)
+}